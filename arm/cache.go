@@ -12,6 +12,11 @@ package arm
 // ARM cache register constants
 const (
 	ACTLR_SMP = 6
+
+	CTR_FORMAT     = 29
+	CTR_FORMAT_V7  = 0b100
+	CTR_DMINLINE   = 16
+	CACHE_LINE_MIN = 32
 )
 
 // defined in cache.s
@@ -21,6 +26,13 @@ func cache_enable()
 func cache_disable()
 func cache_flush_data()
 func cache_flush_instruction()
+func read_ctr() uint32
+func dcache_clean_range(start uint32, end uint32, line uint32)
+func dcache_clean_invalidate_range(start uint32, end uint32, line uint32)
+func dcache_invalidate_range(start uint32, end uint32, line uint32)
+
+// data cache line size
+var dcacheLine uint32
 
 // EnableSMP sets the SMP bit in Cortex-A7 Auxiliary Control Register, to
 // enable coherent requests to the processor. This must be ensured before
@@ -51,3 +63,72 @@ func (cpu *CPU) FlushDataCache() {
 func (cpu *CPU) FlushInstructionCache() {
 	cache_flush_instruction()
 }
+
+// dcacheLineSize returns the smallest data cache line size, falling back to
+// CACHE_LINE_MIN on processors without an ARMv7 format Cache Type Register
+// (B4.1.42 CTR, Cache Type Register, ARM Architecture Reference Manual ARMv7-A
+// and ARMv7-R edition).
+func dcacheLineSize() uint32 {
+	if dcacheLine != 0 {
+		return dcacheLine
+	}
+
+	ctr := read_ctr()
+
+	if ctr>>CTR_FORMAT == CTR_FORMAT_V7 {
+		// log2 of the number of words
+		dcacheLine = 4 << ((ctr >> CTR_DMINLINE) & 0xf)
+	} else {
+		dcacheLine = CACHE_LINE_MIN
+	}
+
+	return dcacheLine
+}
+
+// FlushDCache cleans the data cache lines covering the argument memory range
+// to the point of coherency, ensuring that a DMA master reads the data
+// written by the processor. It must be invoked on cacheable DMA buffers
+// before a DMA transfer is started.
+//
+// Cache lines only partially covered by the range are also invalidated, so
+// that they cannot be later written back over data written by a DMA master.
+func FlushDCache(addr uint, size int) {
+	if size <= 0 {
+		return
+	}
+
+	line := dcacheLineSize()
+	start := uint32(addr) &^ (line - 1)
+	end := (uint32(addr) + uint32(size) + line - 1) &^ (line - 1)
+
+	dcache_clean_range(start, end, line)
+
+	if start != uint32(addr) {
+		dcache_clean_invalidate_range(start, start+line, line)
+	}
+
+	if end != uint32(addr)+uint32(size) {
+		dcache_clean_invalidate_range(end-line, end, line)
+	}
+}
+
+// InvalidateDCache invalidates the data cache lines covering the argument
+// memory range, ensuring that the processor reads the data written by a DMA
+// master. It must be invoked on cacheable DMA buffers after a DMA transfer is
+// completed.
+//
+// Cache lines only partially covered by the range are invalidated as well,
+// FlushDCache() must therefore be invoked on the same range before the
+// transfer is started and adjacent data must not be written by the processor
+// until it is completed, buffers should therefore be cache line aligned.
+func InvalidateDCache(addr uint, size int) {
+	if size <= 0 {
+		return
+	}
+
+	line := dcacheLineSize()
+	start := uint32(addr) &^ (line - 1)
+	end := (uint32(addr) + uint32(size) + line - 1) &^ (line - 1)
+
+	dcache_invalidate_range(start, end, line)
+}
@@ -111,3 +111,60 @@ TEXT ·cache_flush_instruction(SB),$0
 	MOVW	$0, R0
 	MCR	15, 0, R0, C7, C5, 0
 	RET
+
+// func read_ctr() uint32
+TEXT ·read_ctr(SB),$0-4
+	// ARM Architecture Reference Manual - ARMv7-A and ARMv7-R edition
+	// B4.1.42 CTR, Cache Type Register, VMSA
+	MRC	15, 0, R0, C0, C0, 1
+	MOVW	R0, ret+0(FP)
+
+	RET
+
+// func dcache_clean_range(start uint32, end uint32, line uint32)
+TEXT ·dcache_clean_range(SB),$0-12
+	MOVW	start+0(FP), R0
+	MOVW	end+4(FP), R1
+	MOVW	line+8(FP), R2
+	WORD	$0xf57ff05f			// DMB SY
+clean_line:
+	CMP	R1, R0
+	BHS	clean_done
+	MCR	15, 0, R0, C7, C10, 1		// DCCMVAC, clean line by MVA to PoC
+	ADD	R2, R0
+	B	clean_line
+clean_done:
+	WORD	$0xf57ff04f			// DSB SY
+	RET
+
+// func dcache_clean_invalidate_range(start uint32, end uint32, line uint32)
+TEXT ·dcache_clean_invalidate_range(SB),$0-12
+	MOVW	start+0(FP), R0
+	MOVW	end+4(FP), R1
+	MOVW	line+8(FP), R2
+	WORD	$0xf57ff05f			// DMB SY
+clean_invalidate_line:
+	CMP	R1, R0
+	BHS	clean_invalidate_done
+	MCR	15, 0, R0, C7, C14, 1		// DCCIMVAC, clean and invalidate line by MVA to PoC
+	ADD	R2, R0
+	B	clean_invalidate_line
+clean_invalidate_done:
+	WORD	$0xf57ff04f			// DSB SY
+	RET
+
+// func dcache_invalidate_range(start uint32, end uint32, line uint32)
+TEXT ·dcache_invalidate_range(SB),$0-12
+	MOVW	start+0(FP), R0
+	MOVW	end+4(FP), R1
+	MOVW	line+8(FP), R2
+	WORD	$0xf57ff05f			// DMB SY
+invalidate_line:
+	CMP	R1, R0
+	BHS	invalidate_done
+	MCR	15, 0, R0, C7, C6, 1		// DCIMVAC, invalidate line by MVA to PoC
+	ADD	R2, R0
+	B	invalidate_line
+invalidate_done:
+	WORD	$0xf57ff04f			// DSB SY
+	RET
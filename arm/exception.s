@@ -61,6 +61,9 @@ TEXT ·set_mvbar(SB),NOSPLIT,$0-4
 	/* save caller registers */					\
 	MOVM.DB.W	[R0-RN, R14], (R13)	/* push {r0-rN, r14} */	\
 									\
+	/* expose saved registers to the exception handler */		\
+	MOVW	R13, ·exceptionFrame(SB)				\
+									\
 	/* call exception handler on g0 */				\
 	MOVW	$OFFSET, R0						\
 	MOVW	$FN(SB), R1						\
@@ -0,0 +1,150 @@
+// ARM processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package arm
+
+import (
+	"runtime"
+
+	"github.com/usbarmory/tamago/internal/hex"
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// defined in fault.s
+func read_dfsr() uint32
+func read_dfar() uint32
+func read_ifsr() uint32
+func read_ifar() uint32
+
+// number of stack words reported by FaultHandler()
+const faultStackWords = 16
+
+// exceptionFrame holds the address of the registers saved on the stack by
+// the exception vectors (r0-r12 and the exception return address), it is
+// set on each exception entry (see exception.s).
+var exceptionFrame uint32
+
+// FaultStatus returns a description of the argument Data or Instruction Fault
+// Status Register value, Short-descriptor format
+// (B3.13.3 Fault Status and Fault Address registers in a VMSA implementation,
+// ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition).
+func FaultStatus(fsr uint32) string {
+	switch (fsr>>6)&0b10000 | fsr&0b1111 {
+	case 0b00001:
+		return "alignment fault"
+	case 0b00010:
+		return "debug event"
+	case 0b00011, 0b00110:
+		return "access flag fault"
+	case 0b00100:
+		return "instruction cache maintenance fault"
+	case 0b00101, 0b00111:
+		return "translation fault"
+	case 0b01000:
+		return "synchronous external abort"
+	case 0b01001, 0b01011:
+		return "domain fault"
+	case 0b01100, 0b01110:
+		return "synchronous external abort on translation table walk"
+	case 0b01101, 0b01111:
+		return "permission fault"
+	case 0b10110:
+		return "asynchronous external abort"
+	case 0b11001:
+		return "synchronous parity error on memory access"
+	}
+
+	return "unknown fault"
+}
+
+// FaultHandler handles an exception by printing, over the board console, a
+// crash report before panicking. The report includes the faulting
+// instruction address, the general purpose registers, the relevant fault
+// status and address registers and a dump of the faulting context stack.
+//
+// The handler can be installed as SystemExceptionHandler or invoked by a
+// custom one, it is meant for undefined instruction, prefetch abort and data
+// abort exceptions and it defers to DefaultExceptionHandler() for any other
+// vector.
+func FaultHandler(off int) {
+	if off != UNDEFINED && off != PREFETCH_ABORT && off != DATA_ABORT {
+		DefaultExceptionHandler(off)
+	}
+
+	// saved r0-r12 and exception return address
+	frame := exceptionFrame
+	pc := reg.Read(frame + 13*4)
+	sp := frame + 14*4
+
+	print("exception: ", VectorName(off), " mode ", int(read_cpsr()&0x1f), "\n")
+	print("pc   ")
+	hex.Print32(pc)
+	print("\n")
+
+	switch off {
+	case DATA_ABORT:
+		dfsr := read_dfsr()
+
+		print("dfsr ")
+		hex.Print32(dfsr)
+		print(" (", FaultStatus(dfsr), ")\n")
+		print("dfar ")
+		hex.Print32(read_dfar())
+		print("\n")
+	case PREFETCH_ABORT:
+		ifsr := read_ifsr()
+
+		print("ifsr ")
+		hex.Print32(ifsr)
+		print(" (", FaultStatus(ifsr), ")\n")
+		print("ifar ")
+		hex.Print32(read_ifar())
+		print("\n")
+	}
+
+	for i := uint32(0); i < 13; i++ {
+		if i < 10 {
+			print("r", i, "   ")
+		} else {
+			print("r", i, "  ")
+		}
+
+		hex.Print32(reg.Read(frame + 4*i))
+
+		if i%4 == 3 {
+			print("\n")
+		} else {
+			print(" ")
+		}
+	}
+
+	print("\nsp   ")
+	hex.Print32(sp)
+	print("\n")
+
+	// avoid faulting again on an invalid stack pointer
+	if start, end := runtime.MemRegion(); sp >= start && sp+faultStackWords*4 <= end {
+		for i := uint32(0); i < faultStackWords; i++ {
+			if i%4 == 0 {
+				hex.Print32(sp + 4*i)
+				print(": ")
+			}
+
+			hex.Print32(reg.Read(sp + 4*i))
+
+			if i%4 == 3 {
+				print("\n")
+			} else {
+				print(" ")
+			}
+		}
+	}
+
+	panic("unhandled exception")
+}
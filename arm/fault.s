@@ -0,0 +1,44 @@
+// ARM processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// func read_dfsr() uint32
+TEXT ·read_dfsr(SB),$0-4
+	// ARM Architecture Reference Manual - ARMv7-A and ARMv7-R edition
+	// B4.1.52 DFSR, Data Fault Status Register, VMSA
+	MRC	15, 0, R0, C5, C0, 0
+	MOVW	R0, ret+0(FP)
+
+	RET
+
+// func read_dfar() uint32
+TEXT ·read_dfar(SB),$0-4
+	// ARM Architecture Reference Manual - ARMv7-A and ARMv7-R edition
+	// B4.1.51 DFAR, Data Fault Address Register, VMSA
+	MRC	15, 0, R0, C6, C0, 0
+	MOVW	R0, ret+0(FP)
+
+	RET
+
+// func read_ifsr() uint32
+TEXT ·read_ifsr(SB),$0-4
+	// ARM Architecture Reference Manual - ARMv7-A and ARMv7-R edition
+	// B4.1.96 IFSR, Instruction Fault Status Register, VMSA
+	MRC	15, 0, R0, C5, C0, 1
+	MOVW	R0, ret+0(FP)
+
+	RET
+
+// func read_ifar() uint32
+TEXT ·read_ifar(SB),$0-4
+	// ARM Architecture Reference Manual - ARMv7-A and ARMv7-R edition
+	// B4.1.95 IFAR, Instruction Fault Address Register, VMSA
+	MRC	15, 0, R0, C6, C0, 2
+	MOVW	R0, ret+0(FP)
+
+	RET
@@ -12,6 +12,7 @@ package arm
 // defined in irq.s
 func irq_enable()
 func irq_disable()
+func wait_interrupt()
 
 // EnableInterrupts enables IRQ and FIQ interrupts.
 func (cpu *CPU) EnableInterrupts() {
@@ -22,3 +23,18 @@ func (cpu *CPU) EnableInterrupts() {
 func (cpu *CPU) DisableInterrupts() {
 	irq_disable()
 }
+
+// WaitForInterrupt suspends execution, placing the core in a low-power state,
+// until an interrupt or debug event occurs (ARM Architecture Reference Manual
+// ARMv7-A and ARMv7-R edition, B1.8.13 Wait For Interrupt).
+//
+// The core is woken up by asserted interrupts even when masked by
+// DisableInterrupts(), in which case execution resumes without the exception
+// being taken.
+//
+// The runtime provides no idle hook, it is therefore up to the application to
+// invoke WaitForInterrupt() when no work is pending and interrupt sources
+// (e.g. peripheral or timer interrupts) are enabled to wake up the core.
+func WaitForInterrupt() {
+	wait_interrupt()
+}
@@ -16,3 +16,9 @@ TEXT ·irq_enable(SB),$0
 TEXT ·irq_disable(SB),$0
 	WORD	$0xf10c01c0 // cpsid aif
 	RET
+
+// func wait_interrupt()
+TEXT ·wait_interrupt(SB),$0
+	WORD	$0xf57ff04f // dsb sy
+	WORD	$0xe320f003 // wfi
+	RET
@@ -0,0 +1,39 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bits
+
+import (
+	"math/bits"
+)
+
+// ReverseBits returns the argument value with its bit order reversed.
+func ReverseBits(v uint32) uint32 {
+	return bits.Reverse32(v)
+}
+
+// ReverseBytes16 returns the argument value with its byte order reversed.
+func ReverseBytes16(v uint16) uint16 {
+	return bits.ReverseBytes16(v)
+}
+
+// ReverseBytes32 returns the argument value with its byte order reversed.
+func ReverseBytes32(v uint32) uint32 {
+	return bits.ReverseBytes32(v)
+}
+
+// SetBytes modifies the pointed value by setting the argument bytes, in
+// little-endian order, starting at a specific bit position. Bytes exceeding
+// the value width are ignored.
+func SetBytes(addr *uint32, pos int, b []byte) {
+	for i, c := range b {
+		if p := pos + 8*i; p < 32 {
+			SetN(addr, p, 0xff, uint32(c))
+		}
+	}
+}
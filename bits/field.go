@@ -0,0 +1,41 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bits
+
+import (
+	"fmt"
+)
+
+// Field represents a register bitfield, allowing drivers to define bit
+// position and mask pairs once, rather than passing them separately to
+// Get() and SetN().
+type Field struct {
+	// Bit position
+	Pos uint32
+	// Bitmask (applied after shifting to Pos)
+	Mask uint32
+}
+
+// Get returns the field value within the argument register value.
+func (f Field) Get(val uint32) uint32 {
+	return (val >> f.Pos) & f.Mask
+}
+
+// Set modifies the pointed register value by setting the field to the
+// argument value, an error is returned if the value does not fit within the
+// field mask.
+func (f Field) Set(addr *uint32, val uint32) (err error) {
+	if val&^f.Mask != 0 {
+		return fmt.Errorf("value %#x exceeds field mask %#x", val, f.Mask)
+	}
+
+	SetN(addr, int(f.Pos), int(f.Mask), val)
+
+	return
+}
@@ -16,8 +16,10 @@
 package mx6ullevk
 
 import (
+	"io"
 	_ "unsafe"
 
+	"github.com/usbarmory/tamago/serial"
 	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
 )
 
@@ -40,6 +42,11 @@ var (
 	USDHC2 = imx6ul.USDHC2
 )
 
+// Console returns the serial console (UART1).
+func Console() io.ReadWriter {
+	return serial.Blocking(UART1)
+}
+
 // Init takes care of the lower level SoC initialization triggered early in
 // runtime setup.
 //
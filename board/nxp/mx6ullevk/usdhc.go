@@ -39,6 +39,13 @@ const (
 	USDHC2_WP_MODE   = 1
 	DAISY_CSI_PIXCLK = 0b10
 
+	IOMUXC_SW_MUX_CTL_PAD_UART1_RTS_B = 0x020e0090
+	IOMUXC_SW_PAD_CTL_PAD_UART1_RTS_B = 0x020e031c
+	IOMUXC_USDHC1_CD_B_SELECT_INPUT   = 0x020e0668
+
+	USDHC1_CD_MODE    = 2
+	DAISY_UART1_RTS_B = 0b01
+
 	SD1_BUS_WIDTH = 4
 	SD2_BUS_WIDTH = 4
 )
@@ -72,6 +79,22 @@ func init() {
 	wpSD2.Select(DAISY_CSI_PIXCLK)
 	wpSD2.Ctl(ctl)
 
+	// The full size slot card detect switch shorts the line to ground on
+	// card insertion, the CPU board microSD slot has no card detect line.
+	ctl |= 1 << iomuxc.SW_PAD_CTL_HYS
+	ctl |= iomuxc.SW_PAD_CTL_PUS_PULL_UP_47K << iomuxc.SW_PAD_CTL_PUS
+
+	// SD1 card detect (USDHC1_CD_B)
+	cdSD1 := &iomuxc.Pad{
+		Mux:   IOMUXC_SW_MUX_CTL_PAD_UART1_RTS_B,
+		Pad:   IOMUXC_SW_PAD_CTL_PAD_UART1_RTS_B,
+		Daisy: IOMUXC_USDHC1_CD_B_SELECT_INPUT,
+	}
+
+	cdSD1.Mode(USDHC1_CD_MODE)
+	cdSD1.Select(DAISY_UART1_RTS_B)
+	cdSD1.Ctl(ctl)
+
 	SD1.Init(SD1_BUS_WIDTH)
 	SD2.Init(SD2_BUS_WIDTH)
 
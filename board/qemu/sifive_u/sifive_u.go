@@ -17,8 +17,10 @@
 package sifive_u
 
 import (
+	"io"
 	_ "unsafe"
 
+	"github.com/usbarmory/tamago/serial"
 	"github.com/usbarmory/tamago/soc/sifive/fu540"
 )
 
@@ -27,6 +29,11 @@ var (
 	UART0 = fu540.UART0
 )
 
+// Console returns the serial console (UART0).
+func Console() io.ReadWriter {
+	return serial.Blocking(UART0)
+}
+
 // Init takes care of the lower level SoC initialization triggered early in
 // runtime setup.
 //
@@ -20,4 +20,10 @@ import (
 //go:linkname printk runtime.printk
 func printk(c byte) {
 	bcm2835.MiniUART.Tx(c)
+
+	// Drain the transmit FIFO at each line end, so that console output
+	// (e.g. a panic trace) is not lost on a subsequent reset.
+	if c == '\n' {
+		bcm2835.MiniUART.Flush()
+	}
 }
@@ -14,7 +14,20 @@
 // https://github.com/usbarmory/tamago.
 package pi
 
+import (
+	"io"
+
+	"github.com/usbarmory/tamago/soc/bcm2835"
+)
+
 // Board provides a basic abstraction over the different models of Pi.
 type Board interface {
 	LED(name string, on bool) (err error)
+	Console() io.ReadWriter
+}
+
+// Console returns the serial console, which on all Pi models is the
+// mini-UART.
+func Console() io.ReadWriter {
+	return bcm2835.MiniUART
 }
@@ -15,6 +15,7 @@
 package pi2
 
 import (
+	"io"
 	_ "unsafe"
 
 	"github.com/usbarmory/tamago/board/raspberrypi"
@@ -39,3 +40,8 @@ func Init() {
 	// peripheral base address.
 	bcm2835.Init(peripheralBase)
 }
+
+// Console returns the serial console.
+func (b *board) Console() io.ReadWriter {
+	return pi.Console()
+}
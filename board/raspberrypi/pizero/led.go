@@ -10,6 +10,9 @@ package pizero
 
 import (
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/usbarmory/tamago/soc/bcm2835"
 )
@@ -20,29 +23,55 @@ const (
 	ACTIVITY = 0x2f
 )
 
-var activity *bcm2835.GPIO
+var (
+	ledMux sync.Mutex
+	leds   = make(map[string]*bcm2835.GPIO)
+)
 
 func init() {
-	var err error
+	if err := AddLED("activity", ACTIVITY); err != nil {
+		panic(err)
+	}
+}
 
-	activity, err = bcm2835.NewGPIO(ACTIVITY)
+func lookupLED(name string) (led *bcm2835.GPIO, err error) {
+	ledMux.Lock()
+	defer ledMux.Unlock()
+
+	led, ok := leds[strings.ToLower(name)]
+
+	if !ok {
+		return nil, errors.New("invalid LED")
+	}
+
+	return
+}
+
+// AddLED registers an additional LED, connected to the argument GPIO line,
+// for use with Board.LED() and Blink().
+func AddLED(name string, num int) (err error) {
+	led, err := bcm2835.NewGPIO(num)
 
 	if err != nil {
-		panic(err)
+		return
 	}
 
-	activity.Out()
+	led.Out()
+
+	ledMux.Lock()
+	defer ledMux.Unlock()
+
+	leds[strings.ToLower(name)] = led
+
+	return
 }
 
 // LED turns on/off an LED by name.
 func (b *board) LED(name string, on bool) (err error) {
-	var led *bcm2835.GPIO
+	led, err := lookupLED(name)
 
-	switch name {
-	case "activity", "Activity", "ACTIVITY":
-		led = activity
-	default:
-		return errors.New("invalid LED")
+	if err != nil {
+		return
 	}
 
 	if on {
@@ -53,3 +82,42 @@ func (b *board) LED(name string, on bool) (err error) {
 
 	return
 }
+
+// Blink toggles an LED by name, at the argument interval, within a dedicated
+// goroutine until the returned cancel function is invoked.
+func Blink(name string, interval time.Duration) (cancel func(), err error) {
+	led, err := lookupLED(name)
+
+	if err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	once := sync.Once{}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for on := true; ; on = !on {
+			if on {
+				led.High()
+			} else {
+				led.Low()
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				led.Low()
+				return
+			}
+		}
+	}()
+
+	cancel = func() {
+		once.Do(func() { close(done) })
+	}
+
+	return
+}
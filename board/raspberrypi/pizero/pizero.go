@@ -15,6 +15,7 @@
 package pizero
 
 import (
+	"io"
 	_ "unsafe"
 
 	"github.com/usbarmory/tamago/board/raspberrypi"
@@ -37,3 +38,8 @@ func Init() {
 	// peripheral base address.
 	bcm2835.Init(peripheralBase)
 }
+
+// Console returns the serial console.
+func (b *board) Console() io.ReadWriter {
+	return pi.Console()
+}
@@ -14,11 +14,10 @@ import (
 	"sync"
 	"time"
 
-	"github.com/usbarmory/tamago/bits"
+	"github.com/usbarmory/tamago/serial"
 	"github.com/usbarmory/tamago/soc/nxp/gpio"
 	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
 	"github.com/usbarmory/tamago/soc/nxp/iomuxc"
-	"github.com/usbarmory/tamago/soc/nxp/uart"
 )
 
 // BLE module configuration constants.
@@ -53,10 +52,12 @@ const (
 	DAISY_GPIO1_IO07                 = 0b01
 
 	// BT_UART_DSR (GPIO1_IO24)
+	BT_UART_DSR                         = 24
 	IOMUXC_SW_MUX_CTL_PAD_UART3_TX_DATA = 0x020e00a4
 	IOMUXC_SW_PAD_CTL_PAD_UART3_TX_DATA = 0x020e0330
 
 	// BT_UART_DTR (GPIO1_IO25)
+	BT_UART_DTR                         = 25
 	IOMUXC_SW_MUX_CTL_PAD_UART3_RX_DATA = 0x020e00a8
 	IOMUXC_SW_PAD_CTL_PAD_UART3_RX_DATA = 0x020e0334
 
@@ -89,7 +90,7 @@ const (
 	RESET_GRACE_TIME = 1 * time.Second
 )
 
-func configureBLEPad(mux uint32, pad uint32, daisy uint32, mode uint32, ctl uint32) (p *iomuxc.Pad) {
+func configureBLEPad(mux uint32, pad uint32, daisy uint32, mode uint32, ctl iomuxc.PadCtl) (p *iomuxc.Pad) {
 	p = &iomuxc.Pad{
 		Mux:   mux,
 		Pad:   pad,
@@ -97,12 +98,12 @@ func configureBLEPad(mux uint32, pad uint32, daisy uint32, mode uint32, ctl uint
 	}
 
 	p.Mode(mode)
-	p.Ctl(ctl)
+	p.Ctl(uint32(ctl))
 
 	return
 }
 
-func configureBLEGPIO(num int, gpio *gpio.GPIO, mux uint32, pad uint32, ctl uint32) (pin *gpio.Pin) {
+func configureBLEGPIO(num int, gpio *gpio.GPIO, mux uint32, pad uint32, ctl iomuxc.PadCtl) (pin *gpio.Pin) {
 	var err error
 
 	if pin, err = gpio.Init(num); err != nil {
@@ -112,7 +113,7 @@ func configureBLEGPIO(num int, gpio *gpio.GPIO, mux uint32, pad uint32, ctl uint
 	pin.Out()
 
 	p := iomuxc.Init(mux, pad, GPIO_MODE)
-	p.Ctl(ctl)
+	p.Ctl(uint32(ctl))
 
 	return
 }
@@ -122,7 +123,21 @@ func configureBLEGPIO(num int, gpio *gpio.GPIO, mux uint32, pad uint32, ctl uint
 type ANNA struct {
 	sync.Mutex
 
-	UART *uart.UART
+	// UART is the serial port connected to the module, AT commands are
+	// issued through any serial.UART implementation.
+	UART serial.UART
+
+	// ResetGrace is the time RESET_N is held low during a reset cycle
+	// (default: RESET_GRACE_TIME).
+	ResetGrace time.Duration
+
+	// URC is the optional handler for unsolicited result codes received
+	// while waiting for Command() responses.
+	URC func(line string)
+
+	// Progress is the optional handler for FlashFirmware() progress
+	// reporting.
+	Progress func(sent int, total int)
 
 	reset   *gpio.Pin
 	switch1 *gpio.Pin
@@ -132,6 +147,10 @@ type ANNA struct {
 	errata bool
 	rts    *gpio.Pin
 	cts    *gpio.Pin
+
+	// module DSR (input) and DTR (output) lines
+	dsr *gpio.Pin
+	dtr *gpio.Pin
 }
 
 // BLE module instance
@@ -144,14 +163,15 @@ func (ble *ANNA) Init() (err error) {
 
 	BLE.UART = UART1
 
-	ctl := uint32(0)
-	bits.Set(&ctl, iomuxc.SW_PAD_CTL_HYS)
-	bits.Set(&ctl, iomuxc.SW_PAD_CTL_PUE)
-	bits.Set(&ctl, iomuxc.SW_PAD_CTL_PKE)
+	if ble.ResetGrace == 0 {
+		ble.ResetGrace = RESET_GRACE_TIME
+	}
 
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_PUS, 0b11, iomuxc.SW_PAD_CTL_PUS_PULL_UP_100K)
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_SPEED, 0b11, iomuxc.SW_PAD_CTL_SPEED_100MHZ)
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_DSE, 0b111, iomuxc.SW_PAD_CTL_DSE_2_R0_6)
+	var ctl iomuxc.PadCtl
+	ctl.Hysteresis(true)
+	ctl.PullUp(iomuxc.SW_PAD_CTL_PUS_PULL_UP_100K)
+	ctl.Speed(iomuxc.SW_PAD_CTL_SPEED_100MHZ)
+	ctl.DriveStrength(iomuxc.SW_PAD_CTL_DSE_2_R0_6)
 
 	// BT_UART_TX
 	configureBLEPad(IOMUXC_SW_MUX_CTL_PAD_UART1_TX_DATA,
@@ -177,7 +197,7 @@ func (ble *ANNA) Init() (err error) {
 			ctl)
 		BLE.cts.Out()
 
-		bits.SetN(&ctl, iomuxc.SW_PAD_CTL_PUS, 0b11, iomuxc.SW_PAD_CTL_PUS_PULL_DOWN_100K)
+		ctl.PullDown(iomuxc.SW_PAD_CTL_PUS_PULL_DOWN_100K)
 
 		// On β BT_UART_CTS is set to GPIO for RTS due to errata.
 		BLE.rts = configureBLEGPIO(18, imx6ul.GPIO1,
@@ -186,7 +206,7 @@ func (ble *ANNA) Init() (err error) {
 			ctl)
 		BLE.rts.In()
 
-		BLE.UART.Flow = false
+		UART1.Flow = false
 	default:
 		// BT_UART_CTS
 		pad = configureBLEPad(
@@ -194,7 +214,7 @@ func (ble *ANNA) Init() (err error) {
 			IOMUXC_SW_PAD_CTL_PAD_UART1_CTS_B,
 			0, DEFAULT_MODE, ctl)
 
-		bits.SetN(&ctl, iomuxc.SW_PAD_CTL_PUS, 0b11, iomuxc.SW_PAD_CTL_PUS_PULL_DOWN_100K)
+		ctl.PullDown(iomuxc.SW_PAD_CTL_PUS_PULL_DOWN_100K)
 
 		// BT_UART_RTS
 		pad = configureBLEPad(
@@ -204,18 +224,20 @@ func (ble *ANNA) Init() (err error) {
 			UART1_RTS_B_MODE, ctl)
 		pad.Select(DAISY_GPIO1_IO07)
 
-		BLE.UART.Flow = true
+		UART1.Flow = true
 	}
 
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_PUS, 0b11, iomuxc.SW_PAD_CTL_PUS_PULL_UP_22K)
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_SPEED, 0b11, iomuxc.SW_PAD_CTL_SPEED_50MHZ)
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_DSE, 0b111, iomuxc.SW_PAD_CTL_DSE_2_R0_4)
+	ctl.PullUp(iomuxc.SW_PAD_CTL_PUS_PULL_UP_22K)
+	ctl.Speed(iomuxc.SW_PAD_CTL_SPEED_50MHZ)
+	ctl.DriveStrength(iomuxc.SW_PAD_CTL_DSE_2_R0_4)
 
 	// BT_UART_DSR
-	configureBLEPad(
+	BLE.dsr = configureBLEGPIO(BT_UART_DSR, imx6ul.GPIO1,
 		IOMUXC_SW_MUX_CTL_PAD_UART3_TX_DATA,
 		IOMUXC_SW_PAD_CTL_PAD_UART3_TX_DATA,
-		0, ctl, GPIO_MODE)
+		ctl)
+	// deasserted (active low)
+	BLE.dsr.High()
 
 	// BT_SWDCLK
 	configureBLEPad(
@@ -248,16 +270,17 @@ func (ble *ANNA) Init() (err error) {
 		ctl)
 
 	ctl = 0
-	bits.SetN(&ctl, iomuxc.SW_PAD_CTL_DSE, 0b111, iomuxc.SW_PAD_CTL_DSE_2_R0_4)
-	bits.Set(&ctl, iomuxc.SW_PAD_CTL_HYS)
+	ctl.DriveStrength(iomuxc.SW_PAD_CTL_DSE_2_R0_4)
+	ctl.Hysteresis(true)
 
 	// BT_UART_DTR
-	configureBLEPad(
+	BLE.dtr = configureBLEGPIO(BT_UART_DTR, imx6ul.GPIO1,
 		IOMUXC_SW_MUX_CTL_PAD_UART3_RX_DATA,
 		IOMUXC_SW_PAD_CTL_PAD_UART3_RX_DATA,
-		0, GPIO_MODE, ctl)
+		ctl)
+	BLE.dtr.In()
 
-	BLE.UART.Init()
+	UART1.Init()
 
 	// reset in normal mode
 	BLE.switch1.High()
@@ -292,11 +315,39 @@ func (ble *ANNA) CTS(clear bool) {
 	}
 }
 
+// DTR signals the BLE module Data Terminal Ready state, by driving its DSR
+// input line (active low).
+func (ble *ANNA) DTR(assert bool) {
+	if ble.dsr == nil {
+		return
+	}
+
+	if assert {
+		ble.dsr.Low()
+	} else {
+		ble.dsr.High()
+	}
+}
+
+// DSR returns the BLE module Data Set Ready state, by sampling its DTR output
+// line (active low).
+func (ble *ANNA) DSR() bool {
+	if ble.dtr == nil {
+		return false
+	}
+
+	return !ble.dtr.Value()
+}
+
 // Reset the BLE module by toggling the RESET_N pin.
 func (ble *ANNA) Reset() (err error) {
 	ble.Lock()
 	defer ble.Unlock()
 
+	return ble.doReset()
+}
+
+func (ble *ANNA) doReset() (err error) {
 	if ble.reset == nil {
 		return errors.New("module is not initialized")
 	}
@@ -304,17 +355,58 @@ func (ble *ANNA) Reset() (err error) {
 	ble.reset.Low()
 	defer ble.reset.High()
 
-	time.Sleep(RESET_GRACE_TIME)
+	time.Sleep(ble.grace())
 
 	return
 }
 
+func (ble *ANNA) grace() time.Duration {
+	if ble.ResetGrace == 0 {
+		return RESET_GRACE_TIME
+	}
+
+	return ble.ResetGrace
+}
+
+// ResetAsync resets the BLE module by toggling the RESET_N pin, without
+// holding the module lock during the reset grace time. The returned channel
+// receives the reset result once the cycle is complete.
+func (ble *ANNA) ResetAsync() <-chan error {
+	done := make(chan error, 1)
+
+	ble.Lock()
+	defer ble.Unlock()
+
+	if ble.reset == nil {
+		done <- errors.New("module is not initialized")
+		return done
+	}
+
+	ble.reset.Low()
+
+	go func(grace time.Duration) {
+		time.Sleep(grace)
+
+		ble.Lock()
+		ble.reset.High()
+		ble.Unlock()
+
+		done <- nil
+	}(ble.grace())
+
+	return done
+}
+
 // Enter normal mode by driving high SWITCH_1 and SWITCH_2 during a module
 // reset cycle.
 func (ble *ANNA) NormalMode() (err error) {
 	ble.Lock()
 	defer ble.Unlock()
 
+	return ble.normalMode()
+}
+
+func (ble *ANNA) normalMode() (err error) {
 	if ble.switch1 == nil || ble.switch2 == nil {
 		return errors.New("module is not initialized")
 	}
@@ -322,7 +414,7 @@ func (ble *ANNA) NormalMode() (err error) {
 	ble.switch1.High()
 	ble.switch2.High()
 
-	return ble.Reset()
+	return ble.doReset()
 }
 
 // Enter bootloader mode by driving low SWITCH_1 and SWITCH_2 during a module
@@ -338,5 +430,5 @@ func (ble *ANNA) BootloaderMode() (err error) {
 	ble.switch1.Low()
 	ble.switch2.Low()
 
-	return ble.Reset()
+	return ble.doReset()
 }
@@ -0,0 +1,131 @@
+// USB armory Mk II support for tamago/arm
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mk2
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// AT command constants
+const (
+	AT_PREFIX     = "AT"
+	AT_TERMINATOR = "\r"
+
+	AT_OK    = "OK"
+	AT_ERROR = "ERROR"
+
+	// unsolicited result codes prefix (u-blox Short Range AT commands
+	// manual)
+	AT_URC_PREFIX = "+UU"
+)
+
+// write transmits a buffer to the BLE module, honoring the RTS/CTS errata
+// workaround on β boards.
+func (ble *ANNA) write(buf []byte, deadline time.Time) (err error) {
+	for _, c := range buf {
+		for ble.errata && !ble.RTS() {
+			if time.Now().After(deadline) {
+				return errors.New("timeout waiting for RTS")
+			}
+
+			runtime.Gosched()
+		}
+
+		ble.UART.Tx(c)
+	}
+
+	return
+}
+
+// readLine receives a non-empty line from the BLE module, stripped from its
+// line terminators.
+func (ble *ANNA) readLine(deadline time.Time) (line string, err error) {
+	var buf []byte
+
+	for {
+		c, valid := ble.UART.Rx()
+
+		if !valid {
+			if time.Now().After(deadline) {
+				return "", errors.New("timeout waiting for response")
+			}
+
+			runtime.Gosched()
+			continue
+		}
+
+		switch c {
+		case '\r', '\n':
+			if len(buf) > 0 {
+				return string(buf), nil
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+}
+
+// Command sends an AT command to the BLE module and returns its response
+// lines, excluding the command echo and final result code. The "AT" prefix is
+// added if not already present in the argument command.
+//
+// Unsolicited result codes received while waiting for the command response
+// are passed to the URC handler, when set, and otherwise discarded.
+//
+// An error is returned if the module replies with "ERROR" or if no final
+// result code is received within the argument timeout.
+func (ble *ANNA) Command(cmd string, timeout time.Duration) (resp []string, err error) {
+	ble.Lock()
+	defer ble.Unlock()
+
+	if ble.UART == nil {
+		return nil, errors.New("module is not initialized")
+	}
+
+	if !strings.HasPrefix(strings.ToUpper(cmd), AT_PREFIX) {
+		cmd = AT_PREFIX + cmd
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	if err = ble.write([]byte(cmd+AT_TERMINATOR), deadline); err != nil {
+		return
+	}
+
+	// allow the module to send its response
+	ble.CTS(true)
+
+	for {
+		line, err := ble.readLine(deadline)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case line == cmd:
+			// command echo
+		case line == AT_OK:
+			return resp, nil
+		case line == AT_ERROR:
+			return nil, fmt.Errorf("%s: command error", cmd)
+		case strings.HasPrefix(line, AT_URC_PREFIX):
+			if ble.URC != nil {
+				ble.URC(line)
+			}
+		default:
+			resp = append(resp, line)
+		}
+	}
+}
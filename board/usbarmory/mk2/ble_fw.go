@@ -0,0 +1,178 @@
+// USB armory Mk II support for tamago/arm
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mk2
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// XMODEM-1K protocol constants
+const (
+	XMODEM_STX = 0x02
+	XMODEM_EOT = 0x04
+	XMODEM_ACK = 0x06
+	XMODEM_NAK = 0x15
+	XMODEM_CAN = 0x18
+	XMODEM_CRC = 'C'
+	XMODEM_PAD = 0x1a
+
+	XMODEM_BLOCK_SIZE  = 1024
+	XMODEM_MAX_RETRIES = 10
+
+	// receiver start timeout
+	XMODEM_START_TIMEOUT = 10 * time.Second
+	// block acknowledgment timeout
+	XMODEM_ACK_TIMEOUT = 5 * time.Second
+)
+
+// crc16 computes the XMODEM CRC-16 (CCITT polynomial, zero initial value).
+func crc16(buf []byte) (crc uint16) {
+	for _, b := range buf {
+		crc ^= uint16(b) << 8
+
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return
+}
+
+// rx waits for a single character from the BLE module.
+func (ble *ANNA) rx(timeout time.Duration) (c byte, err error) {
+	var valid bool
+
+	start := time.Now()
+
+	for {
+		if c, valid = ble.UART.Rx(); valid {
+			return
+		}
+
+		if time.Since(start) > timeout {
+			return 0, errors.New("timeout waiting for bootloader")
+		}
+
+		runtime.Gosched()
+	}
+}
+
+// sendBlock transmits a single XMODEM-1K block, retrying until acknowledged.
+func (ble *ANNA) sendBlock(num byte, data []byte) (err error) {
+	crc := crc16(data)
+
+	pkt := []byte{XMODEM_STX, num, ^num}
+	pkt = append(pkt, data...)
+	pkt = append(pkt, byte(crc>>8), byte(crc))
+
+	for i := 0; i < XMODEM_MAX_RETRIES; i++ {
+		if err = ble.write(pkt, time.Now().Add(XMODEM_ACK_TIMEOUT)); err != nil {
+			return
+		}
+
+		c, err := ble.rx(XMODEM_ACK_TIMEOUT)
+
+		if err != nil {
+			continue
+		}
+
+		switch c {
+		case XMODEM_ACK:
+			return nil
+		case XMODEM_CAN:
+			return errors.New("transfer cancelled by bootloader")
+		}
+	}
+
+	return fmt.Errorf("block %d not acknowledged", num)
+}
+
+// FlashFirmware updates the BLE module firmware by entering bootloader mode,
+// transferring the argument image with the XMODEM-1K (CRC) protocol accepted
+// by the u-blox serial bootloader, and returning to normal mode.
+//
+// The Progress function, when set, is invoked after each transferred block.
+func (ble *ANNA) FlashFirmware(image []byte) (err error) {
+	if len(image) == 0 {
+		return errors.New("invalid firmware image")
+	}
+
+	if err = ble.BootloaderMode(); err != nil {
+		return
+	}
+
+	ble.Lock()
+	defer ble.Unlock()
+
+	defer func() {
+		if err != nil {
+			// abort transfer
+			ble.write([]byte{XMODEM_CAN, XMODEM_CAN}, time.Now().Add(XMODEM_ACK_TIMEOUT))
+		}
+
+		if e := ble.normalMode(); err == nil {
+			err = e
+		}
+	}()
+
+	ble.CTS(true)
+
+	// wait for the receiver to request a CRC transfer
+	for c := byte(0); c != XMODEM_CRC; {
+		if c, err = ble.rx(XMODEM_START_TIMEOUT); err != nil {
+			return
+		}
+	}
+
+	num := byte(1)
+
+	for off := 0; off < len(image); off += XMODEM_BLOCK_SIZE {
+		block := make([]byte, XMODEM_BLOCK_SIZE)
+
+		for i := copy(block, image[off:]); i < len(block); i++ {
+			block[i] = XMODEM_PAD
+		}
+
+		if err = ble.sendBlock(num, block); err != nil {
+			return
+		}
+
+		num++
+
+		if ble.Progress != nil {
+			sent := off + XMODEM_BLOCK_SIZE
+
+			if sent > len(image) {
+				sent = len(image)
+			}
+
+			ble.Progress(sent, len(image))
+		}
+	}
+
+	for i := 0; i < XMODEM_MAX_RETRIES; i++ {
+		if err = ble.write([]byte{XMODEM_EOT}, time.Now().Add(XMODEM_ACK_TIMEOUT)); err != nil {
+			return
+		}
+
+		if c, _ := ble.rx(XMODEM_ACK_TIMEOUT); c == XMODEM_ACK {
+			return nil
+		}
+	}
+
+	return errors.New("end of transmission not acknowledged")
+}
@@ -0,0 +1,208 @@
+// USB armory Mk II support for tamago/arm
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mk2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// HCI UART transport (H4) packet indicators
+// (Bluetooth Core Specification v5.3, Vol 4, Part A, 2 Protocol).
+const (
+	HCI_COMMAND_PKT = 0x01
+	HCI_ACLDATA_PKT = 0x02
+	HCI_SCODATA_PKT = 0x03
+	HCI_EVENT_PKT   = 0x04
+
+	// HCI_TIMEOUT is the timeout for HCI packet transmission.
+	HCI_TIMEOUT = 1 * time.Second
+)
+
+// hci implements an HCI UART transport (H4) over the BLE module serial port.
+type hci struct {
+	ble *ANNA
+
+	rx sync.Mutex
+
+	sync.Mutex
+	closed bool
+}
+
+// HCI returns an HCI UART transport (H4) to the BLE module controller, the
+// module must run firmware exposing its controller through the HCI
+// interface.
+//
+// Each Write() must consist of a single complete HCI packet, prefixed by its
+// packet indicator. Each Read() blocks until a complete HCI packet, prefixed
+// by its packet indicator, is received, io.ErrShortBuffer is returned if the
+// packet does not fit within the argument buffer.
+//
+// The RTS/CTS errata workaround on β boards is handled transparently. AT
+// commands (see Command()) must not be issued while the transport is in use.
+func (ble *ANNA) HCI() io.ReadWriteCloser {
+	return &hci{ble: ble}
+}
+
+func (h *hci) isClosed() bool {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.closed
+}
+
+// hciHeader returns the header length for the argument packet indicator.
+func hciHeader(kind byte) (n int, err error) {
+	switch kind {
+	case HCI_COMMAND_PKT:
+		// opcode (2), parameter length (1)
+		return 3, nil
+	case HCI_ACLDATA_PKT:
+		// handle (2), data length (2)
+		return 4, nil
+	case HCI_SCODATA_PKT:
+		// handle (2), data length (1)
+		return 3, nil
+	case HCI_EVENT_PKT:
+		// event code (1), parameter length (1)
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid HCI packet indicator %#x", kind)
+	}
+}
+
+// hciLength returns the payload length encoded in the argument packet header.
+func hciLength(kind byte, hdr []byte) int {
+	switch kind {
+	case HCI_ACLDATA_PKT:
+		return int(hdr[2]) | int(hdr[3])<<8
+	default:
+		return int(hdr[len(hdr)-1])
+	}
+}
+
+func (h *hci) readByte() (c byte, err error) {
+	for {
+		if h.isClosed() {
+			return 0, io.ErrClosedPipe
+		}
+
+		if c, valid := h.ble.UART.Rx(); valid {
+			return c, nil
+		}
+
+		runtime.Gosched()
+	}
+}
+
+func (h *hci) readFull(buf []byte) (err error) {
+	for i := range buf {
+		if buf[i], err = h.readByte(); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Read receives a single HCI packet.
+func (h *hci) Read(p []byte) (n int, err error) {
+	h.rx.Lock()
+	defer h.rx.Unlock()
+
+	if h.ble.UART == nil {
+		return 0, errors.New("module is not initialized")
+	}
+
+	// allow the module to send data
+	h.ble.CTS(true)
+
+	kind, err := h.readByte()
+
+	if err != nil {
+		return
+	}
+
+	size, err := hciHeader(kind)
+
+	if err != nil {
+		return
+	}
+
+	pkt := make([]byte, 1+size)
+	pkt[0] = kind
+
+	if err = h.readFull(pkt[1:]); err != nil {
+		return
+	}
+
+	payload := make([]byte, hciLength(kind, pkt[1:]))
+
+	if err = h.readFull(payload); err != nil {
+		return
+	}
+
+	pkt = append(pkt, payload...)
+
+	if len(p) < len(pkt) {
+		return 0, io.ErrShortBuffer
+	}
+
+	return copy(p, pkt), nil
+}
+
+// Write transmits a single HCI packet.
+func (h *hci) Write(p []byte) (n int, err error) {
+	if h.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+
+	if len(p) == 0 {
+		return 0, errors.New("empty HCI packet")
+	}
+
+	size, err := hciHeader(p[0])
+
+	if err != nil {
+		return
+	}
+
+	if len(p) < 1+size || len(p) != 1+size+hciLength(p[0], p[1:1+size]) {
+		return 0, errors.New("invalid HCI packet length")
+	}
+
+	h.ble.Lock()
+	defer h.ble.Unlock()
+
+	if h.ble.UART == nil {
+		return 0, errors.New("module is not initialized")
+	}
+
+	if err = h.ble.write(p, time.Now().Add(HCI_TIMEOUT)); err != nil {
+		return
+	}
+
+	return len(p), nil
+}
+
+// Close closes the transport, pending and subsequent reads and writes return
+// io.ErrClosedPipe.
+func (h *hci) Close() error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.closed = true
+
+	return nil
+}
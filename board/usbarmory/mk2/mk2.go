@@ -16,6 +16,9 @@
 package mk2
 
 import (
+	"io"
+
+	"github.com/usbarmory/tamago/serial"
 	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
 
 	_ "unsafe"
@@ -41,6 +44,12 @@ var (
 	USDHC2 = imx6ul.USDHC2
 )
 
+// Console returns the serial console (UART2), available only in debug
+// accessory mode (see EnableDebugAccessory()).
+func Console() io.ReadWriter {
+	return serial.Blocking(UART2)
+}
+
 // Model returns the USB armory model name, to further detect SoC variants
 // imx6ul.Model() can be used.
 func Model() (model string) {
@@ -21,6 +21,8 @@ type block struct {
 	// distinguish regular (`Alloc`/`Free`) and reserved
 	// (`Reserve`/`Release`) blocks.
 	res bool
+	// allocation caller location (when tracking is enabled)
+	caller string
 }
 
 func (b *block) read(off uint, buf []byte) {
@@ -40,3 +42,14 @@ func (b *block) write(off uint, buf []byte) {
 
 	copy(mem, buf)
 }
+
+func (b *block) zero() {
+	var ptr unsafe.Pointer
+
+	ptr = unsafe.Add(ptr, b.addr)
+	mem := unsafe.Slice((*byte)(ptr), b.size)
+
+	for i := range mem {
+		mem[i] = 0
+	}
+}
@@ -89,6 +89,12 @@ func Alloc(buf []byte, align int) (addr uint) {
 	return dma.Alloc(buf, align)
 }
 
+// AllocZeroed is the equivalent of Region.AllocZeroed() on the global DMA
+// region.
+func AllocZeroed(size int, align int) (addr uint, err error) {
+	return dma.AllocZeroed(size, align)
+}
+
 // Read is the equivalent of Region.Read() on the global DMA region.
 func Read(addr uint, off int, buf []byte) {
 	dma.Read(addr, off, buf)
@@ -104,6 +110,11 @@ func Free(addr uint) {
 	dma.Free(addr)
 }
 
+// FreeZero is the equivalent of Region.FreeZero() on the global DMA region.
+func FreeZero(addr uint) {
+	dma.FreeZero(addr)
+}
+
 // Release is the equivalent of Region.Release() on the global DMA region.
 func Release(addr uint) {
 	dma.Release(addr)
@@ -11,6 +11,7 @@ package dma
 
 import (
 	"container/list"
+	"errors"
 	"reflect"
 	"sync"
 	"unsafe"
@@ -25,6 +26,9 @@ type Region struct {
 
 	freeBlocks *list.List
 	usedBlocks map[uint]*block
+
+	// allocation tracking
+	track bool
 }
 
 var dma *Region
@@ -76,6 +80,10 @@ func (dma *Region) Reserve(size int, align int) (addr uint, buf []byte) {
 	b := dma.alloc(uint(size), uint(align))
 	b.res = true
 
+	if dma.track {
+		b.caller = caller()
+	}
+
 	dma.usedBlocks[b.addr] = b
 
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buf))
@@ -112,7 +120,7 @@ func (dma *Region) Alloc(buf []byte, align int) (addr uint) {
 		return 0
 	}
 
-	if res, addr := Reserved(buf); res {
+	if res, addr := dma.Reserved(buf); res {
 		return addr
 	}
 
@@ -122,11 +130,45 @@ func (dma *Region) Alloc(buf []byte, align int) (addr uint) {
 	b := dma.alloc(uint(size), uint(align))
 	b.write(0, buf)
 
+	if dma.track {
+		b.caller = caller()
+	}
+
 	dma.usedBlocks[b.addr] = b
 
 	return b.addr
 }
 
+// AllocZeroed reserves a zeroed memory region for DMA purposes, returning its
+// allocation address, with optional alignment. The region can be freed up with
+// Free().
+//
+// Unlike Alloc(), an error is returned if the alignment is not a power of 2,
+// word alignment is always enforced (0 == 4).
+func (dma *Region) AllocZeroed(size int, align int) (addr uint, err error) {
+	if size <= 0 {
+		return 0, errors.New("invalid size")
+	}
+
+	if align < 0 || align&(align-1) != 0 {
+		return 0, errors.New("alignment must be a power of 2")
+	}
+
+	dma.Lock()
+	defer dma.Unlock()
+
+	b := dma.alloc(uint(size), uint(align))
+	b.zero()
+
+	if dma.track {
+		b.caller = caller()
+	}
+
+	dma.usedBlocks[b.addr] = b
+
+	return b.addr, nil
+}
+
 // Read reads exactly len(buf) bytes from a memory region address into a
 // buffer, the region must have been previously allocated with Alloc().
 //
@@ -144,7 +186,7 @@ func (dma *Region) Read(addr uint, off int, buf []byte) {
 		return
 	}
 
-	if res, _ := Reserved(buf); res {
+	if res, _ := dma.Reserved(buf); res {
 		return
 	}
 
@@ -196,13 +238,23 @@ func (dma *Region) Write(addr uint, off int, buf []byte) {
 // Free frees the memory region stored at the passed address, the region must
 // have been previously allocated with Alloc().
 func (dma *Region) Free(addr uint) {
-	dma.freeBlock(addr, false)
+	dma.freeBlock(addr, false, false)
+}
+
+// FreeZero frees the memory region stored at the passed address, after
+// overwriting its contents with zeros, the region must have been previously
+// allocated with Alloc().
+//
+// It should be used in place of Free() for buffers that held sensitive data
+// (e.g. key material), to prevent its disclosure to subsequent allocations.
+func (dma *Region) FreeZero(addr uint) {
+	dma.freeBlock(addr, false, true)
 }
 
 // Release frees the memory region stored at the passed address, the region
 // must have been previously allocated with Reserve().
 func (dma *Region) Release(addr uint) {
-	dma.freeBlock(addr, true)
+	dma.freeBlock(addr, true, false)
 }
 
 func (dma *Region) defrag() {
@@ -282,6 +334,8 @@ func (dma *Region) alloc(size uint, align uint) *block {
 }
 
 func (dma *Region) free(usedBlock *block) {
+	usedBlock.caller = ""
+
 	for e := dma.freeBlocks.Front(); e != nil; e = e.Next() {
 		b := e.Value.(*block)
 
@@ -295,7 +349,7 @@ func (dma *Region) free(usedBlock *block) {
 	dma.freeBlocks.PushBack(usedBlock)
 }
 
-func (dma *Region) freeBlock(addr uint, res bool) {
+func (dma *Region) freeBlock(addr uint, res bool, zero bool) {
 	if addr == 0 {
 		return
 	}
@@ -313,6 +367,10 @@ func (dma *Region) freeBlock(addr uint, res bool) {
 		return
 	}
 
+	if zero {
+		b.zero()
+	}
+
 	dma.free(b)
 	delete(dma.usedBlocks, addr)
 }
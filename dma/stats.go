@@ -0,0 +1,120 @@
+// First-fit memory allocator for DMA buffers
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package dma
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Allocation represents an outstanding DMA region allocation, as reported
+// when allocation tracking is enabled (see Region.Track()).
+type Allocation struct {
+	// Allocation address
+	Addr uint
+	// Allocation size (alignment padding is returned to the free blocks)
+	Size uint
+	// Reserve() (true) or Alloc() (false) allocation
+	Reserved bool
+	// Caller location (file:line)
+	Caller string
+}
+
+// caller returns the location of the first caller outside of this package.
+func caller() string {
+	pc := make([]uintptr, 8)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		f, more := frames.Next()
+
+		if !strings.HasPrefix(f.Function, "github.com/usbarmory/tamago/dma.") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// Used returns the number of bytes currently allocated within the DMA region.
+func (dma *Region) Used() (n int) {
+	dma.Lock()
+	defer dma.Unlock()
+
+	for _, b := range dma.usedBlocks {
+		n += int(b.size)
+	}
+
+	return
+}
+
+// Available returns the number of bytes currently free within the DMA region,
+// regardless of fragmentation (see LargestFreeBlock()).
+func (dma *Region) Available() (n int) {
+	dma.Lock()
+	defer dma.Unlock()
+
+	for e := dma.freeBlocks.Front(); e != nil; e = e.Next() {
+		n += int(e.Value.(*block).size)
+	}
+
+	return
+}
+
+// LargestFreeBlock returns the size of the largest contiguous free block
+// within the DMA region, which bounds the size of the next allocation.
+func (dma *Region) LargestFreeBlock() (n int) {
+	dma.Lock()
+	defer dma.Unlock()
+
+	for e := dma.freeBlocks.Front(); e != nil; e = e.Next() {
+		if size := int(e.Value.(*block).size); size > n {
+			n = size
+		}
+	}
+
+	return
+}
+
+// Track enables or disables allocation tracking, when enabled the caller
+// location of each subsequent Alloc() and Reserve() is recorded and reported
+// by Allocations(), to aid in finding leaked allocations.
+//
+// Tracking incurs a performance penalty on each allocation and should only
+// be enabled for debugging purposes.
+func (dma *Region) Track(enable bool) {
+	dma.Lock()
+	defer dma.Unlock()
+
+	dma.track = enable
+}
+
+// Allocations returns all outstanding allocations within the DMA region, the
+// caller location is only reported for allocations performed while tracking
+// is enabled (see Track()).
+func (dma *Region) Allocations() (allocs []Allocation) {
+	dma.Lock()
+	defer dma.Unlock()
+
+	for _, b := range dma.usedBlocks {
+		allocs = append(allocs, Allocation{
+			Addr:     b.addr,
+			Size:     b.size,
+			Reserved: b.res,
+			Caller:   b.caller,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,87 @@
+// First-fit memory allocator for DMA buffers
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package dma
+
+import (
+	"testing"
+	"unsafe"
+)
+
+const testRegionSize = 64 * 1024
+
+// backing memory for test DMA regions
+var testRegionMem [testRegionSize]byte
+
+func newTestRegion(t *testing.T) *Region {
+	region, err := NewRegion(uint(uintptr(unsafe.Pointer(&testRegionMem[0]))), testRegionSize, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	region.Track(true)
+
+	return region
+}
+
+func TestAllocationLeak(t *testing.T) {
+	region := newTestRegion(t)
+
+	// mimic a transfer, with aligned buffer and descriptor allocations
+	buf := region.Alloc(make([]byte, 4096), 4096)
+	desc := region.Alloc(make([]byte, 28), 32)
+	res, _ := region.Reserve(512, 0)
+
+	if n := len(region.Allocations()); n != 3 {
+		t.Fatalf("expected 3 outstanding allocations, got %d", n)
+	}
+
+	region.Free(desc)
+	region.FreeZero(buf)
+	region.Release(res)
+
+	if allocs := region.Allocations(); len(allocs) != 0 {
+		t.Errorf("leaked allocations: %+v", allocs)
+	}
+
+	if n := region.Used(); n != 0 {
+		t.Errorf("expected no used bytes, got %d", n)
+	}
+
+	if n := region.Available(); n != testRegionSize {
+		t.Errorf("expected %d available bytes, got %d", testRegionSize, n)
+	}
+
+	if n := region.LargestFreeBlock(); n != testRegionSize {
+		t.Errorf("expected %d bytes free block, got %d", testRegionSize, n)
+	}
+}
+
+func TestAllocationTracking(t *testing.T) {
+	region := newTestRegion(t)
+
+	addr := region.Alloc(make([]byte, 100), 0)
+	defer region.Free(addr)
+
+	allocs := region.Allocations()
+
+	if len(allocs) != 1 {
+		t.Fatalf("expected 1 outstanding allocation, got %d", len(allocs))
+	}
+
+	if a := allocs[0]; a.Addr != addr || a.Size != 100 || a.Reserved {
+		t.Errorf("unexpected allocation %+v", a)
+	}
+
+	// callers within this package are skipped
+	if c := allocs[0].Caller; c == "" || c == "unknown" {
+		t.Errorf("missing caller location")
+	}
+}
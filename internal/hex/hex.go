@@ -0,0 +1,32 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package hex implements hexadecimal printing of register values, without
+// allocating, to be safely used within exception handlers.
+package hex
+
+const digits = "0123456789abcdef"
+
+// Print32 prints a 32-bit value in hexadecimal format.
+func Print32(v uint32) {
+	printN(uint64(v), 32)
+}
+
+// Print64 prints a 64-bit value in hexadecimal format.
+func Print64(v uint64) {
+	printN(v, 64)
+}
+
+func printN(v uint64, size int) {
+	print("0x")
+
+	for i := size - 4; i >= 0; i -= 4 {
+		n := (v >> i) & 0xf
+		print(digits[n : n+1])
+	}
+}
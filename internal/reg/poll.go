@@ -0,0 +1,71 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package reg
+
+import (
+	"runtime"
+	"time"
+)
+
+// Register polling configuration, used by all wait functions.
+//
+// When MaxPollInterval is zero (default) registers are polled continuously,
+// only yielding to other goroutines between polls.
+//
+// When MaxPollInterval is non-zero, waits which last longer than
+// PollThreshold sleep between polls with an exponential backoff capped at
+// MaxPollInterval, reducing register accesses and leaving the processor to
+// other goroutines during long waits at the expense of detection latency.
+// Short waits (e.g. USB transfers) are not affected.
+//
+// The backoff does not reduce power consumption, as the runtime scheduler
+// spins while all goroutines are sleeping.
+var (
+	PollThreshold   = 10 * time.Millisecond
+	MaxPollInterval time.Duration
+)
+
+// minimum backoff interval
+const minPollInterval = 10 * time.Microsecond
+
+type poll struct {
+	start time.Time
+	delay time.Duration
+}
+
+// wait yields the processor between register polls.
+func (p *poll) wait() {
+	if MaxPollInterval == 0 {
+		// tamago is single-threaded, give other goroutines a chance
+		runtime.Gosched()
+		return
+	}
+
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+
+	if time.Since(p.start) < PollThreshold {
+		runtime.Gosched()
+		return
+	}
+
+	switch {
+	case p.delay == 0:
+		p.delay = minPollInterval
+	case p.delay < MaxPollInterval:
+		p.delay *= 2
+	}
+
+	if p.delay > MaxPollInterval {
+		p.delay = MaxPollInterval
+	}
+
+	time.Sleep(p.delay)
+}
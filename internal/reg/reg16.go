@@ -9,7 +9,6 @@
 package reg
 
 import (
-	"runtime"
 	"time"
 	"unsafe"
 )
@@ -65,9 +64,10 @@ func Or16(addr uint32, val uint16) {
 // Wait16 waits for a specific register bit to match a value. This function
 // cannot be used before runtime initialization with `GOOS=tamago`.
 func Wait16(addr uint32, pos int, mask int, val uint16) {
+	var p poll
+
 	for Get16(addr, pos, mask) != val {
-		// tamago is single-threaded, give other goroutines a chance
-		runtime.Gosched()
+		p.wait()
 	}
 }
 
@@ -76,11 +76,11 @@ func Wait16(addr uint32, pos int, mask int, val uint16) {
 // (true) or if it timed out (false). This function cannot be used before
 // runtime initialization with `GOOS=tamago`.
 func WaitFor16(timeout time.Duration, addr uint32, pos int, mask int, val uint16) bool {
+	var p poll
 	start := time.Now()
 
 	for Get16(addr, pos, mask) != val {
-		// tamago is single-threaded, give other goroutines a chance
-		runtime.Gosched()
+		p.wait()
 
 		if time.Since(start) >= timeout {
 			return false
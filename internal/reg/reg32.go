@@ -15,7 +15,7 @@
 package reg
 
 import (
-	"runtime"
+	"fmt"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -72,9 +72,64 @@ func ClearN(addr uint32, pos int, mask int) {
 	atomic.StoreUint32(reg, r)
 }
 
+// Modify performs a read-modify-write of a register, with interrupts masked,
+// setting it to the value returned by the argument function for its current
+// value. The function must not block as it is invoked with interrupts masked.
+//
+// Interrupt masking, rather than exclusive load/store instructions which are
+// not guaranteed to be supported on device memory, ensures that the operation
+// is not interleaved with register accesses performed by interrupt handlers
+// on the same core.
+func Modify(addr uint32, fn func(r uint32) uint32) {
+	reg := (*uint32)(unsafe.Pointer(uintptr(addr)))
+
+	state := irq_save()
+	atomic.StoreUint32(reg, fn(atomic.LoadUint32(reg)))
+	irq_restore(state)
+}
+
+// RMW performs a read-modify-write of the register bits selected by the mask
+// argument, setting them to the corresponding value bits, with interrupts
+// masked (see Modify()).
+func RMW(addr uint32, mask uint32, val uint32) {
+	Modify(addr, func(r uint32) uint32 {
+		return (r & ^mask) | (val & mask)
+	})
+}
+
+// irq_save masks interrupts returning the previous interrupt state.
+//
+// defined in reg32_*.s
+func irq_save() (state uintptr)
+
+// irq_restore restores the interrupt state returned by irq_save().
+//
+// defined in reg32_*.s
+func irq_restore(state uintptr)
+
 // defined in reg32_*.s
 func Move(dst uint32, src uint32)
 
+// MoveN copies a register field from src to dst, leaving other dst bits
+// unchanged, and zeroes it out in src.
+//
+// defined in reg32_*.s
+func MoveN(dst uint32, src uint32, pos int, mask uint32)
+
+// ReadBarrier reads a register, the access is preceded and followed by a data
+// synchronization barrier (e.g. DSB on ARM, FENCE on RISC-V) to enforce its
+// ordering with respect to any other memory access.
+//
+// defined in reg32_*.s
+func ReadBarrier(addr uint32) uint32
+
+// WriteBarrier writes a register, the access is preceded and followed by a
+// data synchronization barrier (e.g. DSB on ARM, FENCE on RISC-V) to ensure its
+// completion before any subsequent memory access.
+//
+// defined in reg32_*.s
+func WriteBarrier(addr uint32, val uint32)
+
 func Read(addr uint32) uint32 {
 	reg := (*uint32)(unsafe.Pointer(uintptr(addr)))
 	return atomic.LoadUint32(reg)
@@ -85,6 +140,27 @@ func Write(addr uint32, val uint32) {
 	atomic.StoreUint32(reg, val)
 }
 
+// WriteConfirm writes a register and reads it back to confirm that the value
+// has been retained, retrying up to the argument number of times. An error is
+// returned if the value is not retained, which typically indicates that the
+// peripheral clock is gated. The function is only meant for registers which
+// read back as written and cannot be used before runtime initialization.
+func WriteConfirm(addr uint32, val uint32, retries int) (err error) {
+	var p poll
+
+	for i := 0; i <= retries; i++ {
+		Write(addr, val)
+
+		if Read(addr) == val {
+			return
+		}
+
+		p.wait()
+	}
+
+	return fmt.Errorf("register %#x write not retained (%#x != %#x)", addr, Read(addr), val)
+}
+
 func WriteBack(addr uint32) {
 	reg := (*uint32)(unsafe.Pointer(uintptr(addr)))
 
@@ -106,9 +182,10 @@ func Or(addr uint32, val uint32) {
 // Wait waits for a specific register bit to match a value. This function
 // cannot be used before runtime initialization with `GOOS=tamago`.
 func Wait(addr uint32, pos int, mask int, val uint32) {
+	var p poll
+
 	for Get(addr, pos, mask) != val {
-		// tamago is single-threaded, give other goroutines a chance
-		runtime.Gosched()
+		p.wait()
 	}
 }
 
@@ -117,18 +194,28 @@ func Wait(addr uint32, pos int, mask int, val uint32) {
 // (true) or if it timed out (false). This function cannot be used before
 // runtime initialization.
 func WaitFor(timeout time.Duration, addr uint32, pos int, mask int, val uint32) bool {
+	_, ok := WaitForValue(timeout, addr, pos, mask, val)
+	return ok
+}
+
+// WaitForValue waits, until a timeout expires, for a specific register bit to
+// match a value. The return boolean indicates whether the wait condition was
+// checked (true) or if it timed out (false), the last observed register bit
+// value is also returned. This function cannot be used before runtime
+// initialization.
+func WaitForValue(timeout time.Duration, addr uint32, pos int, mask int, val uint32) (got uint32, ok bool) {
+	var p poll
 	start := time.Now()
 
-	for Get(addr, pos, mask) != val {
-		// tamago is single-threaded, give other goroutines a chance
-		runtime.Gosched()
+	for got = Get(addr, pos, mask); got != val; got = Get(addr, pos, mask) {
+		p.wait()
 
 		if time.Since(start) >= timeout {
-			return false
+			return
 		}
 	}
 
-	return true
+	return got, true
 }
 
 // WaitSignal waits, until a channel is closed, for a specific register bit to
@@ -136,9 +223,10 @@ func WaitFor(timeout time.Duration, addr uint32, pos int, mask int, val uint32)
 // checked (true) or cancelled (false). This function cannot be used before
 // runtime initialization.
 func WaitSignal(done chan bool, addr uint32, pos int, mask int, val uint32) bool {
+	var p poll
+
 	for Get(addr, pos, mask) != val {
-		// tamago is single-threaded, give other goroutines a chance
-		runtime.Gosched()
+		p.wait()
 
 		select {
 		case <-done:
@@ -149,3 +237,29 @@ func WaitSignal(done chan bool, addr uint32, pos int, mask int, val uint32) bool
 
 	return true
 }
+
+// WaitSignalFor waits, until a channel is closed or a timeout expires, for a
+// specific register bit to match a value. The return boolean indicates
+// whether the wait condition was checked (true) or if it was cancelled or
+// timed out (false). A zero timeout waits indefinitely, as WaitSignal(). This
+// function cannot be used before runtime initialization.
+func WaitSignalFor(timeout time.Duration, done chan bool, addr uint32, pos int, mask int, val uint32) bool {
+	var p poll
+	start := time.Now()
+
+	for Get(addr, pos, mask) != val {
+		p.wait()
+
+		select {
+		case <-done:
+			return false
+		default:
+		}
+
+		if timeout != 0 && time.Since(start) >= timeout {
+			return false
+		}
+	}
+
+	return true
+}
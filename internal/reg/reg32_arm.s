@@ -20,3 +20,65 @@ TEXT ·Move(SB),$0-8
 	MOVW	R3, (R1)
 
 	RET
+
+// func ReadBarrier(addr uint32) uint32
+TEXT ·ReadBarrier(SB),$0-8
+	MOVW	addr+0(FP), R0
+
+	WORD	$0xf57ff04f	// DSB SY
+	MOVW	(R0), R1
+	WORD	$0xf57ff04f	// DSB SY
+
+	MOVW	R1, ret+4(FP)
+
+	RET
+
+// func WriteBarrier(addr uint32, val uint32)
+TEXT ·WriteBarrier(SB),$0-8
+	MOVW	addr+0(FP), R0
+	MOVW	val+4(FP), R1
+
+	WORD	$0xf57ff04f	// DSB SY
+	MOVW	R1, (R0)
+	WORD	$0xf57ff04f	// DSB SY
+
+	RET
+
+// func MoveN(dst uint32, src uint32, pos int, mask uint32)
+TEXT ·MoveN(SB),$0-16
+	MOVW	dst+0(FP), R0
+	MOVW	src+4(FP), R1
+	MOVW	pos+8(FP), R2
+	MOVW	mask+12(FP), R3
+
+	// shift mask to field position
+	MOVW	R3<<R2, R3
+
+	// copy src field to dst
+	MOVW	(R1), R4
+	AND	R3, R4, R5
+	MOVW	(R0), R6
+	BIC	R3, R6, R6
+	ORR	R5, R6, R6
+	MOVW	R6, (R0)
+
+	// zero out src field
+	BIC	R3, R4, R4
+	MOVW	R4, (R1)
+
+	RET
+
+// func irq_save() (state uintptr)
+TEXT ·irq_save(SB),$0-4
+	WORD	$0xe10f0000	// mrs r0, cpsr
+	WORD	$0xf10c00c0	// cpsid if
+	MOVW	R0, state+0(FP)
+
+	RET
+
+// func irq_restore(state uintptr)
+TEXT ·irq_restore(SB),$0-4
+	MOVW	state+0(FP), R0
+	WORD	$0xe121f000	// msr cpsr_c, r0
+
+	RET
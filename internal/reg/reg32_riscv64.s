@@ -20,3 +20,66 @@ TEXT ·Move(SB),$0-8
 	MOV	T3, (T1)
 
 	RET
+
+// func ReadBarrier(addr uint32) uint32
+TEXT ·ReadBarrier(SB),$0-12
+	MOVWU	addr+0(FP), T0
+
+	FENCE
+	MOVWU	(T0), T1
+	FENCE
+
+	MOVW	T1, ret+8(FP)
+
+	RET
+
+// func WriteBarrier(addr uint32, val uint32)
+TEXT ·WriteBarrier(SB),$0-8
+	MOVWU	addr+0(FP), T0
+	MOVWU	val+4(FP), T1
+
+	FENCE
+	MOVW	T1, (T0)
+	FENCE
+
+	RET
+
+// func MoveN(dst uint32, src uint32, pos int, mask uint32)
+TEXT ·MoveN(SB),$0-20
+	MOVWU	dst+0(FP), T0
+	MOVWU	src+4(FP), T1
+	MOV	pos+8(FP), T2
+	MOVWU	mask+16(FP), T3
+
+	// shift mask to field position
+	SLL	T2, T3, T3
+	NOT	T3, A0
+
+	// copy src field to dst
+	MOVWU	(T1), T4
+	AND	T3, T4, T5
+	MOVWU	(T0), T6
+	AND	A0, T6, T6
+	OR	T5, T6, T6
+	MOVW	T6, (T0)
+
+	// zero out src field
+	AND	A0, T4, T4
+	MOVW	T4, (T1)
+
+	RET
+
+// func irq_save() (state uintptr)
+TEXT ·irq_save(SB),$0-8
+	WORD	$0x300472f3	// csrrci t0, mstatus, 0b1000 (MIE)
+	MOV	T0, state+0(FP)
+
+	RET
+
+// func irq_restore(state uintptr)
+TEXT ·irq_restore(SB),$0-8
+	MOV	state+0(FP), T0
+	AND	$0b1000, T0
+	WORD	$0x3002a073	// csrs mstatus, t0
+
+	RET
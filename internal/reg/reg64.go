@@ -13,6 +13,60 @@ import (
 	"unsafe"
 )
 
+func GetN64(addr uint64, pos int, mask int) uint64 {
+	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
+	r := atomic.LoadUint64(reg)
+
+	return (r >> pos) & uint64(mask)
+}
+
+func Set64(addr uint64, pos int) {
+	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
+
+	r := atomic.LoadUint64(reg)
+	r |= (1 << pos)
+
+	atomic.StoreUint64(reg, r)
+}
+
+func Clear64(addr uint64, pos int) {
+	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
+
+	r := atomic.LoadUint64(reg)
+	r &= ^(1 << pos)
+
+	atomic.StoreUint64(reg, r)
+}
+
+func SetTo64(addr uint64, pos int, val bool) {
+	if val {
+		Set64(addr, pos)
+	} else {
+		Clear64(addr, pos)
+	}
+}
+
+func SetN64(addr uint64, pos int, mask int, val uint64) {
+	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
+
+	r := atomic.LoadUint64(reg)
+	r = (r & (^(uint64(mask) << pos))) | (val << pos)
+
+	atomic.StoreUint64(reg, r)
+}
+
+func ClearN64(addr uint64, pos int, mask int) {
+	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
+
+	r := atomic.LoadUint64(reg)
+	r &= ^(uint64(mask) << pos)
+
+	atomic.StoreUint64(reg, r)
+}
+
+// defined in reg64_*.s
+func Move64(dst uint64, src uint64)
+
 func Read64(addr uint64) uint64 {
 	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
 	return atomic.LoadUint64(reg)
@@ -22,3 +76,25 @@ func Write64(addr uint64, val uint64) {
 	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
 	atomic.StoreUint64(reg, val)
 }
+
+// Wait64 waits for a specific register bit to match a value. This function
+// cannot be used before runtime initialization with `GOOS=tamago`.
+func Wait64(addr uint64, pos int, mask int, val uint64) {
+	var p poll
+
+	for GetN64(addr, pos, mask) != val {
+		p.wait()
+	}
+}
+
+// RMW64 performs a read-modify-write of the register bits selected by the
+// mask argument, setting them to the corresponding value bits, with
+// interrupts masked (see Modify()).
+func RMW64(addr uint64, mask uint64, val uint64) {
+	reg := (*uint64)(unsafe.Pointer(uintptr(addr)))
+
+	state := irq_save()
+	r := atomic.LoadUint64(reg)
+	atomic.StoreUint64(reg, (r & ^mask)|(val&mask))
+	irq_restore(state)
+}
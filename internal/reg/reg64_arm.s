@@ -0,0 +1,26 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+
+// func Move64(dst uint64, src uint64)
+TEXT ·Move64(SB),$0-16
+	MOVW	dst_lo+0(FP), R0
+	MOVW	src_lo+8(FP), R1
+
+	// copy src to dst
+	MOVW	(R1), R3
+	MOVW	R3, (R0)
+	MOVW	4(R1), R3
+	MOVW	R3, 4(R0)
+
+	// zero out src
+	MOVW	$0, R3
+	MOVW	R3, (R1)
+	MOVW	R3, 4(R1)
+
+	RET
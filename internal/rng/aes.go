@@ -20,6 +20,52 @@ type DRBG struct {
 	// Seed represents the initial key for the AES-CTR cipher instance, it
 	// will be overwritten during use to implement key erasure.
 	Seed [32]byte
+
+	// Source is the optional entropy source used for reseeding.
+	Source func([]byte)
+	// ReseedInterval is the number of GetRandomData requests after which
+	// the DRBG is automatically reseeded from Source (0 disables automatic
+	// reseeding).
+	ReseedInterval uint64
+
+	// requests since last reseed
+	reseedCounter uint64
+}
+
+// reseed mixes fresh entropy from the DRBG source into its key, the caller
+// must hold the DRBG lock.
+func (r *DRBG) reseed() {
+	var entropy [32]byte
+
+	if r.Source == nil {
+		return
+	}
+
+	r.Source(entropy[:])
+
+	for i := range r.Seed {
+		r.Seed[i] ^= entropy[i]
+		entropy[i] = 0
+	}
+
+	r.reseedCounter = 0
+}
+
+// Reseed mixes fresh entropy from the DRBG source into its key.
+func (r *DRBG) Reseed() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.reseed()
+}
+
+// SetReseedInterval sets the number of GetRandomData requests after which
+// the DRBG is automatically reseeded (0 disables automatic reseeding).
+func (r *DRBG) SetReseedInterval(requests uint64) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.ReseedInterval = requests
 }
 
 // GetRandomData returns len(b) random bytes.
@@ -28,6 +74,13 @@ func (r *DRBG) GetRandomData(b []byte) {
 	var block [aes.BlockSize]byte
 
 	r.Lock()
+
+	r.reseedCounter++
+
+	if r.ReseedInterval > 0 && r.reseedCounter >= r.ReseedInterval {
+		r.reseed()
+	}
+
 	blockCipher, err := aes.NewCipher(r.Seed[:])
 
 	if err != nil {
@@ -0,0 +1,71 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package rng
+
+import (
+	"fmt"
+)
+
+// Health test parameters, for byte sized samples with a conservatively
+// assumed min-entropy of 1 bit per sample and a false positive probability of
+// 2^-20 (NIST SP 800-90B, 4.4 Approved Continuous Health Tests).
+const (
+	// HEALTH_SAMPLE_SIZE is the recommended sample size for HealthTest,
+	// covering a single adaptive proportion test window to limit the
+	// startup latency of slow entropy sources.
+	HEALTH_SAMPLE_SIZE = APT_WINDOW
+
+	// 4.4.1 Repetition Count Test, C = 1 + ⌈20/H⌉
+	RCT_CUTOFF = 21
+
+	// 4.4.2 Adaptive Proportion Test, non-binary window and cutoff
+	APT_WINDOW = 512
+	APT_CUTOFF = 410
+)
+
+// HealthTest runs the repetition count and adaptive proportion tests on an
+// entropy source sample, an error is returned if the source appears to be
+// failing (e.g. stuck at a fixed value).
+func HealthTest(sample []byte) (err error) {
+	if len(sample) < APT_WINDOW {
+		return fmt.Errorf("sample size must be at least %d bytes", APT_WINDOW)
+	}
+
+	// repetition count test
+	for i, n := 1, 1; i < len(sample); i++ {
+		if sample[i] != sample[i-1] {
+			n = 1
+			continue
+		}
+
+		n++
+
+		if n >= RCT_CUTOFF {
+			return fmt.Errorf("repetition count test failure (%#x repeated %d times)", sample[i], n)
+		}
+	}
+
+	// adaptive proportion test
+	for off := 0; off+APT_WINDOW <= len(sample); off += APT_WINDOW {
+		window := sample[off : off+APT_WINDOW]
+		n := 0
+
+		for _, b := range window {
+			if b == window[0] {
+				n++
+			}
+		}
+
+		if n >= APT_CUTOFF {
+			return fmt.Errorf("adaptive proportion test failure (%#x occurs %d times)", window[0], n)
+		}
+	}
+
+	return
+}
@@ -12,8 +12,25 @@ import (
 	_ "unsafe"
 )
 
+// GetRandomDataFn is the random number generator function, set by the SoC
+// package at runtime initialization, backing the Go runtime `getRandomData`
+// function and therefore `crypto/rand` (see rng.Reader).
 var GetRandomDataFn func([]byte)
 
+// Insecure is set by the SoC package when GetRandomDataFn is not backed by a
+// hardware entropy source (e.g. emulated targets or SoCs lacking a TRNG),
+// making it unsuitable for cryptographic use.
+var Insecure bool
+
+// RequireSecure panics if the random number generator is not backed by a
+// hardware entropy source, it is meant to be used by board or application
+// packages to refuse operation before any key material is generated.
+func RequireSecure() {
+	if Insecure {
+		panic("insecure random number generator in use")
+	}
+}
+
 //go:linkname getRandomData runtime.getRandomData
 func getRandomData(b []byte) {
 	GetRandomDataFn(b)
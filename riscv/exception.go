@@ -11,6 +11,8 @@ package riscv
 
 import (
 	"unsafe"
+
+	"github.com/usbarmory/tamago/internal/hex"
 )
 
 // RISC-V exception codes (non-interrupt)
@@ -38,10 +40,53 @@ func set_stvec(addr uint64)
 func read_mepc() uint64
 func read_sepc() uint64
 func read_mcause() uint64
+func read_mtval() uint64
 func read_scause() uint64
+func handleTrap()
+
+// exceptionHandler holds the address of the machine mode exception handler
+// invoked by handleTrap().
+var exceptionHandler uint64
 
 type ExceptionHandler func()
 
+// ExceptionName returns the name of the argument exception code
+// (Table 3.6 - Volume II: RISC-V Privileged Architectures V20211203).
+func ExceptionName(code int) string {
+	switch code {
+	case InstructionAddressMisaligned:
+		return "Instruction address misaligned"
+	case InstructionAccessFault:
+		return "Instruction access fault"
+	case IllegalInstruction:
+		return "Illegal instruction"
+	case Breakpoint:
+		return "Breakpoint"
+	case LoadAddressMisaligned:
+		return "Load address misaligned"
+	case LoadAccessFault:
+		return "Load access fault"
+	case StoreAddressMisaligned:
+		return "Store/AMO address misaligned"
+	case StoreAccessFault:
+		return "Store/AMO access fault"
+	case EnvironmentCallFromU:
+		return "Environment call from U-mode"
+	case EnvironmentCallFromS:
+		return "Environment call from S-mode"
+	case EnvironmentCallFromM:
+		return "Environment call from M-mode"
+	case InstructionPageFault:
+		return "Instruction page fault"
+	case LoadPageFault:
+		return "Load page fault"
+	case StorePageFault:
+		return "Store/AMO page fault"
+	}
+
+	return "Unknown exception"
+}
+
 func vector(fn ExceptionHandler) uint64 {
 	return **((**uint64)(unsafe.Pointer(&fn)))
 }
@@ -72,15 +117,64 @@ func DefaultSupervisorExceptionHandler() {
 	panic("unhandled exception")
 }
 
+// FaultHandler handles a machine mode trap by printing, over the board
+// console, a decoded trap report (e.g. "Load access fault at 0x...")
+// including the trap cause (mcause), the exception program counter (mepc)
+// and the trap value (mtval) before panicking.
+//
+// The handler can be installed with CPU.SetExceptionHandler().
+func FaultHandler() {
+	mcause := read_mcause()
+	mepc := read_mepc()
+	mtval := read_mtval()
+	size := XLEN - 1
+
+	irq := int(mcause >> size)
+	code := int(mcause) & ^(1 << size)
+
+	if irq == 1 {
+		print("machine exception: interrupt ", code, "\n")
+	} else {
+		addr := mtval
+
+		// mtval does not hold a faulting address for illegal
+		// instructions and environment calls
+		switch code {
+		case IllegalInstruction, EnvironmentCallFromU, EnvironmentCallFromS, EnvironmentCallFromM:
+			addr = mepc
+		}
+
+		print("machine exception: ", ExceptionName(code), " at ")
+		hex.Print64(addr)
+		print("\n")
+	}
+
+	print("mcause ")
+	hex.Print64(mcause)
+	print("\nmepc   ")
+	hex.Print64(mepc)
+	print("\nmtval  ")
+	hex.Print64(mtval)
+	print("\n")
+
+	panic("unhandled exception")
+}
+
 //go:nosplit
 func (cpu *CPU) initExceptionHandler() {
-	set_mtvec(vector(DefaultExceptionHandler))
+	exceptionHandler = vector(DefaultExceptionHandler)
+	set_mtvec(vector(handleTrap))
 }
 
-// SetExceptionHandler updates the CPU machine trap vector vector with the
-// address of the argument function.
+// SetExceptionHandler updates the CPU machine trap vector vector to invoke
+// the argument function on any exception or interrupt other than machine
+// external interrupts, which are instead serviced by ServiceInterrupts().
+//
+// The handler is entered with all registers, except TP (X4), holding their
+// value at the time of the trap.
 func (cpu *CPU) SetExceptionHandler(fn ExceptionHandler) {
-	set_mtvec(vector(fn))
+	exceptionHandler = vector(fn)
+	set_mtvec(vector(handleTrap))
 }
 
 // SetSupervisorExceptionHandler updates the CPU supervisor trap vector vector
@@ -17,6 +17,7 @@
 #define mtvec  0x305
 #define mepc   0x341
 #define mcause 0x342
+#define mtval  0x343
 
 // func set_stvec(addr uint64)
 TEXT ·set_stvec(SB),NOSPLIT,$0-8
@@ -53,3 +54,43 @@ TEXT ·read_mcause(SB),NOSPLIT,$0-8
 	CSRR	(mcause, t0)
 	MOV	T0, ret+0(FP)
 	RET
+
+// func read_mtval() uint64
+TEXT ·read_mtval(SB),NOSPLIT,$0-8
+	CSRR	(mtval, t0)
+	MOV	T0, ret+0(FP)
+	RET
+
+// func handleTrap()
+TEXT ·handleTrap(SB),NOSPLIT|NOFRAME,$0
+	// only T0 is used, save it in mscratch
+	WORD	$0x340292f3	// csrrw t0, mscratch, t0
+
+	CSRR	(mcause, t0)
+
+	// machine external interrupt (mcause interrupt bit and code 11)
+	BGEZ	T0, exception
+	SLL	$1, T0
+	ADD	$-22, T0
+	BNEZ	T0, exception
+
+	// mask machine external interrupts (mie.MEIE), the interrupt is
+	// serviced by CPU.ServiceInterrupts() outside of the trap context
+	MOV	$1, T0
+	SLL	$11, T0
+	WORD	$0x3042b073	// csrc mie, t0
+
+	// restore T0
+	WORD	$0x340292f3	// csrrw t0, mscratch, t0
+	WORD	$0x30200073	// mret
+
+exception:
+	// restore T0
+	WORD	$0x340292f3	// csrrw t0, mscratch, t0
+
+	// TP is never used by Go code, it is therefore used to branch to the
+	// exception handler with the interrupted register state otherwise
+	// preserved
+	MOV	$·exceptionHandler(SB), TP
+	MOV	(TP), TP
+	JMP	(TP)
@@ -0,0 +1,63 @@
+// RISC-V processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package riscv
+
+import (
+	"runtime"
+)
+
+// RISC-V interrupt codes
+// (Table 3.6 - Volume II: RISC-V Privileged Architectures V20211203).
+const (
+	MachineSoftwareInterrupt = 3
+	MachineTimerInterrupt    = 7
+	MachineExternalInterrupt = 11
+)
+
+// defined in irq.s
+func irq_enable()
+func irq_disable()
+func read_mie() uint64
+func set_meie()
+
+// EnableInterrupts enables machine external interrupts (mie.MEIE) and the
+// machine global interrupt enable (mstatus.MIE).
+func (cpu *CPU) EnableInterrupts() {
+	irq_enable()
+}
+
+// DisableInterrupts disables the machine global interrupt enable
+// (mstatus.MIE).
+func (cpu *CPU) DisableInterrupts() {
+	irq_disable()
+}
+
+// ServiceInterrupts handles machine external interrupts by invoking the
+// argument function whenever one is received, it never returns and should be
+// run in its own goroutine.
+//
+// The machine trap vector, installed by Init() and SetExceptionHandler(),
+// masks machine external interrupts (mie.MEIE) on reception and returns,
+// the argument function is therefore invoked outside of the trap context
+// and is responsible for clearing the interrupt condition (e.g. through
+// plic.PLIC.ServiceInterrupt()), before external interrupts are unmasked
+// again.
+func (cpu *CPU) ServiceInterrupts(isr func()) {
+	irq_enable()
+
+	for {
+		if read_mie()&(1<<MachineExternalInterrupt) == 0 {
+			isr()
+			set_meie()
+		}
+
+		runtime.Gosched()
+	}
+}
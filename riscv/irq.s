@@ -0,0 +1,42 @@
+// RISC-V processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "csr.h"
+#include "textflag.h"
+
+#define mie 0x304
+
+// func irq_enable()
+TEXT ·irq_enable(SB),NOSPLIT,$0
+	// set mie.MEIE
+	MOV	$1, T0
+	SLL	$11, T0
+	WORD	$0x3042a073	// csrs mie, t0
+	// set mstatus.MIE
+	WORD	$0x30046073	// csrsi mstatus, 8
+	RET
+
+// func irq_disable()
+TEXT ·irq_disable(SB),NOSPLIT,$0
+	// clear mstatus.MIE
+	WORD	$0x30047073	// csrci mstatus, 8
+	RET
+
+// func read_mie() uint64
+TEXT ·read_mie(SB),NOSPLIT,$0-8
+	CSRR	(mie, t0)
+	MOV	T0, ret+0(FP)
+	RET
+
+// func set_meie()
+TEXT ·set_meie(SB),NOSPLIT,$0
+	MOV	$1, T0
+	SLL	$11, T0
+	WORD	$0x3042a073	// csrs mie, t0
+	RET
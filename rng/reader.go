@@ -0,0 +1,63 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package rng provides access to the random number generator configured by
+// the SoC package at runtime initialization.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by
+// the TamaGo framework for bare metal Go, see
+// https://github.com/usbarmory/tamago.
+package rng
+
+import (
+	"errors"
+
+	"github.com/usbarmory/tamago/internal/rng"
+)
+
+// maximum amount of data fetched from the SoC generator at once
+const readChunkSize = 4096
+
+// Reader is a global, shared instance of a random number generator backed by
+// the SoC random number generator function.
+//
+// On GOOS=tamago the Go runtime `getRandomData` function, backing
+// `crypto/rand.Reader`, is linked to the same function through go:linkname,
+// therefore both readers draw from the same source configured by the SoC
+// package at `initRNG` time.
+var Reader = &reader{}
+
+type reader struct{}
+
+// Read fills the argument buffer with random data, large requests are served
+// in chunks to avoid holding the underlying generator for extended periods.
+func (r *reader) Read(b []byte) (n int, err error) {
+	if rng.GetRandomDataFn == nil {
+		return 0, errors.New("random number generator not initialized")
+	}
+
+	for n < len(b) {
+		end := n + readChunkSize
+
+		if end > len(b) {
+			end = len(b)
+		}
+
+		rng.GetRandomDataFn(b[n:end])
+		n = end
+	}
+
+	return
+}
+
+// RequireSecure panics when Reader is not backed by a hardware entropy source
+// (e.g. on emulated targets), applications can invoke it before generating
+// any key material.
+func RequireSecure() {
+	rng.RequireSecure()
+}
@@ -0,0 +1,65 @@
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package serial provides an interface to serial ports, shared across the
+// UART drivers of all supported SoCs, allowing serial device drivers to be
+// written independently from the underlying controller.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by
+// the TamaGo framework for bare metal Go, see
+// https://github.com/usbarmory/tamago.
+package serial
+
+import (
+	"io"
+	"runtime"
+)
+
+// UART represents a serial port controller, as implemented by the following
+// drivers:
+//   - soc/bcm2835 (mini UART)
+//   - soc/nxp/uart
+//   - soc/sifive/uart
+type UART interface {
+	// Read available data to buffer from the serial port, whether Read
+	// blocks when no data is available is driver specific (see Blocking()).
+	io.Reader
+	// Write data from buffer to the serial port.
+	io.Writer
+
+	// Tx transmits a single character to the serial port.
+	Tx(c byte)
+	// Rx receives a single character from the serial port, the returned
+	// boolean indicates whether a character was available.
+	Rx() (c byte, valid bool)
+}
+
+type blocking struct {
+	UART
+}
+
+// Blocking returns an io.ReadWriter for the argument serial port which,
+// regardless of the underlying driver, reads blocking until at least one
+// character is available, as required by line oriented consoles.
+func Blocking(uart UART) io.ReadWriter {
+	return &blocking{uart}
+}
+
+func (b *blocking) Read(buf []byte) (n int, err error) {
+	if len(buf) == 0 {
+		return
+	}
+
+	for {
+		if n, err = b.UART.Read(buf); n > 0 || err != nil {
+			return
+		}
+
+		runtime.Gosched()
+	}
+}
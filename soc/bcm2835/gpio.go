@@ -10,7 +10,10 @@ package bcm2835
 
 import (
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/usbarmory/tamago/arm"
 	"github.com/usbarmory/tamago/internal/reg"
@@ -25,6 +28,9 @@ const (
 	GPSET0    = GPIO_BASE + 0x1c
 	GPCLR0    = GPIO_BASE + 0x28
 	GPLEV0    = GPIO_BASE + 0x34
+	GPEDS0    = GPIO_BASE + 0x40
+	GPREN0    = GPIO_BASE + 0x4c
+	GPFEN0    = GPIO_BASE + 0x58
 	GPPUD     = GPIO_BASE + 0x94
 	GPPUDCLK0 = GPIO_BASE + 0x98
 )
@@ -50,9 +56,12 @@ type GPIOFunction uint32
 
 var gpmux = sync.Mutex{}
 
+// GPIO event handlers
+var gpioHandlers [54]func()
+
 // NewGPIO gets access to a single GPIO line
 func NewGPIO(num int) (*GPIO, error) {
-	if num > 54 || num < 0 {
+	if num > 53 || num < 0 {
 		return nil, fmt.Errorf("invalid GPIO number %d", num)
 	}
 
@@ -153,3 +162,156 @@ func (gpio *GPIO) PullUpDown(val uint32) {
 	reg.Write(PeripheralAddress(GPPUD), 0)
 	reg.Write(clkRegister, 0)
 }
+
+// WaitStable samples the GPIO line until its level is stable for the argument
+// duration, the stable level is returned.
+func (gpio *GPIO) WaitStable(d time.Duration) (high bool) {
+	high = gpio.Value()
+	start := time.Now()
+
+	for time.Since(start) < d {
+		if v := gpio.Value(); v != high {
+			high = v
+			start = time.Now()
+		}
+
+		runtime.Gosched()
+	}
+
+	return
+}
+
+// OnPress invokes the argument function, within a dedicated goroutine, on each
+// debounced button press. A press is detected as a falling edge, followed by a
+// low level stable for the debounce duration, which matches an active low
+// button with a pull-up (see PullUpDown()).
+//
+// The function configures falling edge detection (see DetectEdge()), events
+// are latched by the hardware and therefore not missed while debouncing. An
+// edge handler is also registered (see OnEdge()) to latch events consumed by
+// ServiceGPIOInterrupt(), when the GPIO interrupt is enabled.
+//
+// The returned function stops press detection, disables edge detection and
+// unregisters the edge handler.
+func (gpio *GPIO) OnPress(debounce time.Duration, fn func()) (stop func()) {
+	var once sync.Once
+	var pending uint32
+
+	done := make(chan bool)
+
+	gpio.DetectEdge(false, true)
+	gpio.OnEdge(func() { atomic.StoreUint32(&pending, 1) })
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(debounce):
+			}
+
+			if !gpio.Event() && atomic.LoadUint32(&pending) == 0 {
+				continue
+			}
+
+			low := !gpio.WaitStable(debounce)
+
+			// discard events caused by bouncing
+			gpio.ClearEvent()
+			atomic.StoreUint32(&pending, 0)
+
+			if low {
+				fn()
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			close(done)
+			gpio.DetectEdge(false, false)
+			gpio.OnEdge(nil)
+		})
+	}
+}
+
+// DetectEdge configures rising and/or falling edge event detection for the
+// GPIO line, any pending event is cleared.
+func (gpio *GPIO) DetectEdge(rising bool, falling bool) {
+	gpmux.Lock()
+	defer gpmux.Unlock()
+
+	bank := 4 * uint32(gpio.num/32)
+	shift := gpio.num % 32
+
+	reg.SetTo(PeripheralAddress(GPREN0+bank), shift, rising)
+	reg.SetTo(PeripheralAddress(GPFEN0+bank), shift, falling)
+
+	gpio.ClearEvent()
+}
+
+// Event returns whether an edge event has been detected on the GPIO line.
+func (gpio *GPIO) Event() bool {
+	register := PeripheralAddress(GPEDS0 + 4*uint32(gpio.num/32))
+	shift := uint32(gpio.num % 32)
+
+	return (reg.Read(register)>>shift)&0x1 != 0
+}
+
+// ClearEvent clears a detected edge event on the GPIO line.
+func (gpio *GPIO) ClearEvent() {
+	register := PeripheralAddress(GPEDS0 + 4*uint32(gpio.num/32))
+	shift := uint32(gpio.num % 32)
+
+	// write 1 to clear, other lines are unaffected
+	reg.Write(register, 1<<shift)
+}
+
+// OnEdge registers a handler, invoked by ServiceGPIOInterrupt() on detected
+// edge events for the GPIO line. A nil handler unregisters the existing one.
+func (gpio *GPIO) OnEdge(fn func()) {
+	gpioHandlers[gpio.num] = fn
+}
+
+// EnableGPIOInterrupt routes GPIO edge events to the ARM core IRQ line.
+//
+// The application is responsible for invoking ServiceGPIOInterrupt() from its
+// IRQ exception handler (see arm.SystemExceptionHandler) and for enabling IRQ
+// exceptions (see arm.CPU.EnableInterrupts()).
+func EnableGPIOInterrupt() {
+	EnableInterrupt(GPIO_INT_IRQ)
+}
+
+// ServiceGPIOInterrupt clears all detected edge events, deasserting the level
+// triggered GPIO interrupt, and invokes the handlers registered for the
+// corresponding GPIO lines (see OnEdge()). Only events observed by this
+// function are cleared, so that events detected meanwhile are serviced on the
+// next invocation.
+//
+// Events on lines without a registered handler are discarded, therefore such
+// lines cannot be polled with Event() while the GPIO interrupt is enabled.
+func ServiceGPIOInterrupt() {
+	for bank := 0; bank < 2; bank++ {
+		register := PeripheralAddress(GPEDS0 + 4*uint32(bank))
+		events := reg.Read(register)
+
+		if events == 0 {
+			continue
+		}
+
+		// write 1 to clear, other lines are unaffected
+		reg.Write(register, events)
+
+		for shift := 0; shift < 32; shift++ {
+			num := bank*32 + shift
+
+			if events&(1<<shift) == 0 || num >= len(gpioHandlers) {
+				continue
+			}
+
+			if fn := gpioHandlers[num]; fn != nil {
+				fn()
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// BCM2835 SoC I2C (BSC) support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bcm2835
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// BSC registers (p28, 3.2 Register View, BCM2835 ARM Peripherals)
+const (
+	BSC1_BASE = 0x804000
+
+	BSC_C   = 0x00
+	C_I2CEN = 15
+	C_ST    = 7
+	C_CLEAR = 4
+	C_READ  = 0
+
+	BSC_S  = 0x04
+	S_CLKT = 9
+	S_ERR  = 8
+	S_RXD  = 5
+	S_TXD  = 4
+	S_DONE = 1
+
+	BSC_DLEN = 0x08
+	BSC_A    = 0x0c
+	BSC_FIFO = 0x10
+	BSC_DIV  = 0x14
+	BSC_CLKT = 0x1c
+)
+
+// I2C configuration constants
+const (
+	// I2C_DEFAULT_DIV sets 100 kHz operation with a 250 MHz core clock
+	I2C_DEFAULT_DIV = 2500
+	// I2C_DEFAULT_CLKT sets the clock stretch timeout in SCL cycles
+	I2C_DEFAULT_CLKT = 0x40
+	// I2C_TIMEOUT is the default timeout for I2C operations
+	I2C_TIMEOUT = 100 * time.Millisecond
+)
+
+// I2C represents an I2C (Broadcom Serial Controller) master instance.
+type I2C struct {
+	sync.Mutex
+
+	// Base register
+	Base uint32
+	// SDA, SCL GPIO lines
+	Pins []int
+	// Pin alternate function
+	Function GPIOFunction
+	// Timeout for I2C operations
+	Timeout time.Duration
+	// Div sets the clock divider to control the I2C clock rate
+	Div uint16
+	// ClockStretchTimeout sets the clock stretching timeout in SCL cycles
+	ClockStretchTimeout uint16
+
+	// control registers
+	c    uint32
+	s    uint32
+	dlen uint32
+	a    uint32
+	fifo uint32
+	div  uint32
+	clkt uint32
+}
+
+// I2C1 is the BSC1 instance, available on GPIO2 (SDA) and GPIO3 (SCL).
+var I2C1 = &I2C{
+	Base:     BSC1_BASE,
+	Pins:     []int{2, 3},
+	Function: GPIO_FN0,
+}
+
+// Init initializes the I2C controller instance. At this time only master mode
+// is supported by this driver.
+func (hw *I2C) Init() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 {
+		panic("invalid I2C controller instance")
+	}
+
+	if hw.Timeout == 0 {
+		hw.Timeout = I2C_TIMEOUT
+	}
+
+	if hw.Div == 0 {
+		hw.Div = I2C_DEFAULT_DIV
+	}
+
+	if hw.ClockStretchTimeout == 0 {
+		hw.ClockStretchTimeout = I2C_DEFAULT_CLKT
+	}
+
+	for _, num := range hw.Pins {
+		gpio, err := NewGPIO(num)
+
+		if err != nil {
+			panic(err)
+		}
+
+		gpio.SelectFunction(hw.Function)
+	}
+
+	base := PeripheralAddress(hw.Base)
+
+	hw.c = base + BSC_C
+	hw.s = base + BSC_S
+	hw.dlen = base + BSC_DLEN
+	hw.a = base + BSC_A
+	hw.fifo = base + BSC_FIFO
+	hw.div = base + BSC_DIV
+	hw.clkt = base + BSC_CLKT
+
+	reg.Write(hw.div, uint32(hw.Div))
+	reg.Write(hw.clkt, uint32(hw.ClockStretchTimeout))
+
+	reg.Write(hw.c, 1<<C_I2CEN)
+}
+
+// Read reads a sequence of bytes from a target device.
+//
+// The return data buffer always matches the requested size, otherwise an error
+// is returned.
+//
+// The address length (`alen`) parameter should be set greater then 0 for
+// ordinary I2C reads (`SLAVE W|ADDR|SLAVE R|DATA`), less or equal than 0 when
+// not sending a register address (`SLAVE R|DATA`).
+//
+// As the BSC controller does not support repeated start conditions, the
+// register address write and data read are issued as separate transfers.
+func (hw *I2C) Read(target uint8, addr uint32, alen int, size int) (buf []byte, err error) {
+	if target > 0x7f {
+		return nil, errors.New("invalid target address")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if alen > 0 {
+		if err = hw.transfer(target, address(addr, alen), false); err != nil {
+			return
+		}
+	}
+
+	buf = make([]byte, size)
+	err = hw.transfer(target, buf, true)
+
+	return
+}
+
+// Write writes a sequence of bytes to a target device.
+//
+// The address length (`alen`) parameter should be set greater then 0 for
+// ordinary I2C writes (`SLAVE W|ADDR|DATA`), equal to 0 when not sending a
+// register address (`SLAVE W|DATA`), values less than 0 are not valid.
+func (hw *I2C) Write(buf []byte, target uint8, addr uint32, alen int) (err error) {
+	if alen < 0 {
+		return errors.New("invalid address length")
+	}
+
+	if target > 0x7f {
+		return errors.New("invalid target address")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.transfer(target, append(address(addr, alen), buf...), false)
+}
+
+func address(addr uint32, alen int) (buf []byte) {
+	for alen > 0 {
+		alen--
+		buf = append(buf, byte(addr>>(alen*8)&0xff))
+	}
+
+	return
+}
+
+func (hw *I2C) transfer(target uint8, buf []byte, read bool) (err error) {
+	if len(buf) > 0xffff {
+		return errors.New("invalid transfer size")
+	}
+
+	// clear status
+	reg.Write(hw.s, 1<<S_CLKT|1<<S_ERR|1<<S_DONE)
+
+	reg.Write(hw.a, uint32(target))
+	reg.Write(hw.dlen, uint32(len(buf)))
+
+	c := uint32(1<<C_I2CEN | 0b11<<C_CLEAR | 1<<C_ST)
+
+	if read {
+		c |= 1 << C_READ
+	}
+
+	reg.Write(hw.c, c)
+
+	n := 0
+	start := time.Now()
+
+	for reg.Get(hw.s, S_DONE, 1) == 0 {
+		switch {
+		case reg.Get(hw.s, S_ERR, 1) == 1:
+			return errors.New("I2C target not acknowledged")
+		case reg.Get(hw.s, S_CLKT, 1) == 1:
+			return errors.New("I2C clock stretch timeout")
+		case time.Since(start) > hw.Timeout:
+			return errors.New("I2C timeout")
+		}
+
+		if read {
+			for n < len(buf) && reg.Get(hw.s, S_RXD, 1) == 1 {
+				buf[n] = byte(reg.Read(hw.fifo))
+				n++
+			}
+		} else {
+			for n < len(buf) && reg.Get(hw.s, S_TXD, 1) == 1 {
+				reg.Write(hw.fifo, uint32(buf[n]))
+				n++
+			}
+		}
+
+		runtime.Gosched()
+	}
+
+	if read {
+		// drain remaining data
+		for n < len(buf) && reg.Get(hw.s, S_RXD, 1) == 1 {
+			buf[n] = byte(reg.Read(hw.fifo))
+			n++
+		}
+
+		if n != len(buf) {
+			return errors.New("I2C short read")
+		}
+	}
+
+	if reg.Get(hw.s, S_ERR, 1) == 1 {
+		return errors.New("I2C target not acknowledged")
+	}
+
+	return
+}
@@ -0,0 +1,52 @@
+// BCM2835 SoC interrupt controller support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bcm2835
+
+import (
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// Interrupt controller registers
+// (p112, 7.5 Registers, BCM2835 ARM Peripherals)
+const (
+	IRQ_BASE = 0xb000
+
+	IRQ_PENDING_1 = IRQ_BASE + 0x204
+	IRQ_PENDING_2 = IRQ_BASE + 0x208
+	IRQ_ENABLE_1  = IRQ_BASE + 0x210
+	IRQ_ENABLE_2  = IRQ_BASE + 0x214
+	IRQ_DISABLE_1 = IRQ_BASE + 0x21c
+	IRQ_DISABLE_2 = IRQ_BASE + 0x220
+)
+
+// Peripheral interrupts
+// (p113, 7.5 ARM peripherals interrupts table, BCM2835 ARM Peripherals)
+const (
+	AUX_IRQ      = 29
+	GPIO_INT_IRQ = 52
+)
+
+// EnableInterrupt routes a peripheral interrupt to the ARM core IRQ line.
+func EnableInterrupt(irq int) {
+	if irq < 32 {
+		reg.Write(PeripheralAddress(IRQ_ENABLE_1), 1<<irq)
+	} else {
+		reg.Write(PeripheralAddress(IRQ_ENABLE_2), 1<<(irq-32))
+	}
+}
+
+// DisableInterrupt stops routing a peripheral interrupt to the ARM core IRQ
+// line.
+func DisableInterrupt(irq int) {
+	if irq < 32 {
+		reg.Write(PeripheralAddress(IRQ_DISABLE_1), 1<<irq)
+	} else {
+		reg.Write(PeripheralAddress(IRQ_DISABLE_2), 1<<(irq-32))
+	}
+}
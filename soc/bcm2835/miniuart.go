@@ -14,28 +14,87 @@
 package bcm2835
 
 import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+
 	"github.com/usbarmory/tamago/arm"
 	"github.com/usbarmory/tamago/internal/reg"
 )
 
 const (
-	AUX_ENABLES     = 0x215004
-	AUX_MU_IO_REG   = 0x215040
-	AUX_MU_IER_REG  = 0x215044
-	AUX_MU_IIR_REG  = 0x215048
-	AUX_MU_LCR_REG  = 0x21504C
-	AUX_MU_MCR_REG  = 0x215050
-	AUX_MU_LSR_REG  = 0x215054
-	AUX_MU_MSR_REG  = 0x215058
-	AUX_MU_SCRATCH  = 0x21505C
-	AUX_MU_CNTL_REG = 0x215060
+	AUX_ENABLES    = 0x215004
+	AUX_MU_IO_REG  = 0x215040
+	AUX_MU_IER_REG = 0x215044
+	AUX_MU_IIR_REG = 0x215048
+	AUX_MU_LCR_REG = 0x21504C
+	AUX_MU_MCR_REG = 0x215050
+	AUX_MU_LSR_REG = 0x215054
+	AUX_MU_MSR_REG = 0x215058
+	AUX_MU_SCRATCH = 0x21505C
+
+	LCR_BREAK     = 6
+	LCR_DATA_SIZE = 0
+
+	AUX_MU_CNTL_REG   = 0x215060
+	CNTL_TX_AUTO_FLOW = 3
+	CNTL_RX_AUTO_FLOW = 2
+	CNTL_TX_ENABLE    = 1
+	CNTL_RX_ENABLE    = 0
+
 	AUX_MU_STAT_REG = 0x215064
 	AUX_MU_BAUD_REG = 0x215068
 )
 
+// Mini-UART default configuration
+const (
+	// default baud rate
+	MINIUART_BAUD = 115200
+	// default system (core) clock
+	MINIUART_CLOCK = 250000000
+)
+
 type miniUART struct {
 	lsr uint32
 	io  uint32
+
+	// receive ring buffer
+	ring *ringBuffer
+}
+
+// ringBuffer implements a single producer (interrupt handler), single
+// consumer lock-free ring buffer.
+type ringBuffer struct {
+	buf  []byte
+	head uint32
+	tail uint32
+}
+
+func (r *ringBuffer) push(c byte) bool {
+	head := atomic.LoadUint32(&r.head)
+	next := (head + 1) % uint32(len(r.buf))
+
+	if next == atomic.LoadUint32(&r.tail) {
+		return false
+	}
+
+	r.buf[head] = c
+	atomic.StoreUint32(&r.head, next)
+
+	return true
+}
+
+func (r *ringBuffer) pop() (c byte, valid bool) {
+	tail := atomic.LoadUint32(&r.tail)
+
+	if tail == atomic.LoadUint32(&r.head) {
+		return
+	}
+
+	c = r.buf[tail]
+	atomic.StoreUint32(&r.tail, (tail+1)%uint32(len(r.buf)))
+
+	return c, true
 }
 
 // MiniUART is a secondary low throughput UART intended to be
@@ -51,7 +110,7 @@ func (hw *miniUART) Init() {
 	reg.Write(PeripheralAddress(AUX_MU_MCR_REG), 0)
 	reg.Write(PeripheralAddress(AUX_MU_IER_REG), 0)
 	reg.Write(PeripheralAddress(AUX_MU_IIR_REG), 0xc6)
-	reg.Write(PeripheralAddress(AUX_MU_BAUD_REG), 270)
+	hw.SetBaudRate(MINIUART_BAUD, MINIUART_CLOCK)
 
 	// Not using GPIO abstraction here because at the point
 	// we initialize mini-UART during initialization, to
@@ -76,6 +135,77 @@ func (hw *miniUART) Init() {
 	hw.io = PeripheralAddress(AUX_MU_IO_REG)
 }
 
+// SetBaudRate configures the mini-UART baud rate, the clock argument must
+// reflect the current system (core) clock frequency in Hz, as the baud rate is
+// derived from it.
+func (hw *miniUART) SetBaudRate(baud uint32, clock uint32) (err error) {
+	if baud == 0 {
+		return errors.New("invalid baud rate")
+	}
+
+	div := clock / (8 * baud)
+
+	if div == 0 || div-1 > 0xffff {
+		return errors.New("baud rate out of range")
+	}
+
+	reg.Write(PeripheralAddress(AUX_MU_BAUD_REG), div-1)
+
+	return
+}
+
+// SetLineControl configures the data size, either 7 or 8 bits, and the break
+// condition (TX line held low).
+//
+// The mini-UART hardware does not support parity bits and always uses a
+// single stop bit.
+func (hw *miniUART) SetLineControl(bits int, breakCond bool) (err error) {
+	var lcr uint32
+
+	switch bits {
+	case 7:
+		lcr = 0b00 << LCR_DATA_SIZE
+	case 8:
+		// BCM2835 ARM Peripherals errata, both bits must be set
+		lcr = 0b11 << LCR_DATA_SIZE
+	default:
+		return errors.New("invalid data size")
+	}
+
+	if breakCond {
+		lcr |= 1 << LCR_BREAK
+	}
+
+	reg.Write(PeripheralAddress(AUX_MU_LCR_REG), lcr)
+
+	return
+}
+
+// SetFlowControl enables or disables hardware flow control, when enabled
+// GPIO16 and GPIO17 are configured as CTS and RTS lines.
+func (hw *miniUART) SetFlowControl(enable bool) (err error) {
+	if enable {
+		for _, num := range []int{16, 17} {
+			gpio, err := NewGPIO(num)
+
+			if err != nil {
+				return err
+			}
+
+			if err = gpio.SelectFunction(GPIO_FN5); err != nil {
+				return err
+			}
+		}
+	}
+
+	cntl := PeripheralAddress(AUX_MU_CNTL_REG)
+
+	reg.SetTo(cntl, CNTL_TX_AUTO_FLOW, enable)
+	reg.SetTo(cntl, CNTL_RX_AUTO_FLOW, enable)
+
+	return
+}
+
 // TX transmits a single character to the serial port.
 func (hw *miniUART) Tx(c byte) {
 	for {
@@ -87,9 +217,105 @@ func (hw *miniUART) Tx(c byte) {
 	reg.Write(hw.io, uint32(c))
 }
 
+// Flush waits for the transmit FIFO to be drained and the transmitter to be
+// idle, ensuring that all written data has been sent (e.g. before a reset).
+func (hw *miniUART) Flush() {
+	for {
+		if reg.Read(hw.lsr)&0x40 != 0 {
+			break
+		}
+	}
+}
+
 // Write data from buffer to serial port.
-func (hw *miniUART) Write(buf []byte) {
-	for i := 0; i < len(buf); i++ {
-		hw.Tx(buf[i])
+func (hw *miniUART) Write(buf []byte) (n int, _ error) {
+	for n = 0; n < len(buf); n++ {
+		hw.Tx(buf[n])
+	}
+
+	return
+}
+
+// Rx receives a single character from the serial port.
+func (hw *miniUART) Rx() (c byte, valid bool) {
+	if reg.Read(hw.lsr)&0x01 == 0 {
+		return
+	}
+
+	return byte(reg.Read(hw.io) & 0xff), true
+}
+
+// EnableRxInterrupt enables the receive interrupt, received characters are
+// buffered, up to the size argument, by ServiceInterrupt() and consumed by
+// Read().
+//
+// The application is responsible for invoking ServiceInterrupt() from its IRQ
+// exception handler (see arm.SystemExceptionHandler) and for enabling IRQ
+// exceptions (see arm.CPU.EnableInterrupts()).
+func (hw *miniUART) EnableRxInterrupt(size int) {
+	if size < 2 {
+		panic("invalid receive buffer size")
+	}
+
+	hw.ring = &ringBuffer{
+		buf: make([]byte, size),
+	}
+
+	// Enable receive interrupt, BCM2835 ARM Peripherals errata: bit 0
+	// (not bit 1) enables receive interrupts and bits 3:2 must be set.
+	reg.Write(PeripheralAddress(AUX_MU_IER_REG), 0xd)
+	// route auxiliary peripherals interrupt
+	EnableInterrupt(AUX_IRQ)
+}
+
+// ServiceInterrupt drains the receive FIFO into the receive buffer, characters
+// are discarded if the buffer is full. This function is meant to be invoked
+// from the IRQ exception handler and does not allocate memory.
+func (hw *miniUART) ServiceInterrupt() {
+	for {
+		c, valid := hw.Rx()
+
+		if !valid {
+			return
+		}
+
+		if hw.ring != nil {
+			hw.ring.push(c)
+		}
+	}
+}
+
+// Read data from serial port to buffer, blocking until at least one character
+// is available. When the receive interrupt is enabled (see
+// EnableRxInterrupt()) data is read from the receive buffer.
+func (hw *miniUART) Read(buf []byte) (n int, _ error) {
+	var valid bool
+
+	if len(buf) == 0 {
+		return
+	}
+
+	rx := hw.Rx
+
+	if hw.ring != nil {
+		rx = hw.ring.pop
 	}
+
+	for {
+		if buf[0], valid = rx(); valid {
+			break
+		}
+
+		runtime.Gosched()
+	}
+
+	for n = 1; n < len(buf); n++ {
+		buf[n], valid = rx()
+
+		if !valid {
+			break
+		}
+	}
+
+	return
 }
@@ -0,0 +1,189 @@
+// BCM2835 SoC PWM support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bcm2835
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// PWM registers (p141, 9.6 Control and Status Registers, BCM2835 ARM
+// Peripherals)
+const (
+	PWM_BASE = 0x20c000
+
+	PWM_CTL   = PWM_BASE + 0x00
+	CTL_MSEN2 = 15
+	CTL_PWEN2 = 8
+	CTL_MSEN1 = 7
+	CTL_PWEN1 = 0
+
+	PWM_RNG1 = PWM_BASE + 0x10
+	PWM_DAT1 = PWM_BASE + 0x14
+	PWM_RNG2 = PWM_BASE + 0x20
+	PWM_DAT2 = PWM_BASE + 0x24
+)
+
+// PWM clock manager registers (p107, 6.3 General Purpose GPIO Clocks,
+// BCM2835 ARM Peripherals)
+const (
+	CM_PWMCTL = 0x1010a0
+	CM_PWMDIV = 0x1010a4
+
+	CM_PASSWORD = 0x5a000000
+
+	CM_CTL_BUSY = 7
+	CM_CTL_ENAB = 4
+	CM_CTL_SRC  = 0
+	CM_SRC_OSC  = 1
+
+	CM_DIV_DIVI = 12
+)
+
+// PWM configuration constants
+const (
+	// oscillator frequency
+	PWM_OSC_FREQ = 19200000
+	// PWM clock divisor
+	PWM_CLOCK_DIV = 2
+	// PWM clock frequency
+	PWM_CLOCK_FREQ = PWM_OSC_FREQ / PWM_CLOCK_DIV
+)
+
+var pwmMux = sync.Mutex{}
+var pwmClock bool
+
+// PWM represents a PWM output channel.
+type PWM struct {
+	hz   uint32
+	duty float64
+
+	enable int
+	ms     int
+	rng    uint32
+	dat    uint32
+}
+
+// NewPWM configures a GPIO line, among GPIO12, GPIO13, GPIO18 and GPIO19, for
+// PWM output and returns the corresponding PWM channel instance.
+func NewPWM(num int) (pwm *PWM, err error) {
+	var fn GPIOFunction
+
+	pwm = &PWM{}
+
+	switch num {
+	case 12, 13:
+		fn = GPIO_FN0
+	case 18, 19:
+		fn = GPIO_FN5
+	default:
+		return nil, fmt.Errorf("GPIO%d does not support PWM", num)
+	}
+
+	if num%2 == 0 {
+		pwm.enable = CTL_PWEN1
+		pwm.ms = CTL_MSEN1
+		pwm.rng = PeripheralAddress(PWM_RNG1)
+		pwm.dat = PeripheralAddress(PWM_DAT1)
+	} else {
+		pwm.enable = CTL_PWEN2
+		pwm.ms = CTL_MSEN2
+		pwm.rng = PeripheralAddress(PWM_RNG2)
+		pwm.dat = PeripheralAddress(PWM_DAT2)
+	}
+
+	gpio, err := NewGPIO(num)
+
+	if err != nil {
+		return
+	}
+
+	if err = gpio.SelectFunction(fn); err != nil {
+		return
+	}
+
+	pwmMux.Lock()
+	defer pwmMux.Unlock()
+
+	if !pwmClock {
+		initPWMClock()
+		pwmClock = true
+	}
+
+	// use mark-space mode
+	reg.Set(PeripheralAddress(PWM_CTL), pwm.ms)
+
+	return
+}
+
+func initPWMClock() {
+	ctl := PeripheralAddress(CM_PWMCTL)
+	div := PeripheralAddress(CM_PWMDIV)
+
+	// stop clock
+	reg.Write(ctl, CM_PASSWORD|(reg.Read(ctl) & ^uint32(1<<CM_CTL_ENAB)))
+	reg.Wait(ctl, CM_CTL_BUSY, 1, 0)
+
+	reg.Write(div, CM_PASSWORD|PWM_CLOCK_DIV<<CM_DIV_DIVI)
+	reg.Write(ctl, CM_PASSWORD|CM_SRC_OSC<<CM_CTL_SRC)
+
+	// start clock
+	reg.Write(ctl, CM_PASSWORD|CM_SRC_OSC<<CM_CTL_SRC|1<<CM_CTL_ENAB)
+	reg.Wait(ctl, CM_CTL_BUSY, 1, 1)
+}
+
+// SetFrequency configures the PWM output frequency.
+func (pwm *PWM) SetFrequency(hz uint32) (err error) {
+	if hz == 0 || hz > PWM_CLOCK_FREQ {
+		return errors.New("invalid frequency")
+	}
+
+	pwm.hz = hz
+	reg.Write(pwm.rng, PWM_CLOCK_FREQ/hz)
+
+	return pwm.SetDutyCycle(pwm.duty)
+}
+
+// SetDutyCycle configures the PWM output duty cycle, as percentage of the
+// output period.
+func (pwm *PWM) SetDutyCycle(percent float64) (err error) {
+	if percent < 0 || percent > 100 {
+		return errors.New("invalid duty cycle")
+	}
+
+	pwm.duty = percent
+
+	if pwm.hz == 0 {
+		return
+	}
+
+	period := float64(PWM_CLOCK_FREQ / pwm.hz)
+	reg.Write(pwm.dat, uint32(period*percent/100))
+
+	return
+}
+
+// Enable enables the PWM output.
+func (pwm *PWM) Enable() {
+	pwmMux.Lock()
+	defer pwmMux.Unlock()
+
+	reg.Set(PeripheralAddress(PWM_CTL), pwm.enable)
+}
+
+// Disable disables the PWM output.
+func (pwm *PWM) Disable() {
+	pwmMux.Lock()
+	defer pwmMux.Unlock()
+
+	reg.Clear(PeripheralAddress(PWM_CTL), pwm.enable)
+}
@@ -0,0 +1,168 @@
+// BCM2835 SoC SPI support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bcm2835
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// SPI0 registers (p152, 10.5 SPI Register Map, BCM2835 ARM Peripherals)
+const (
+	SPI0_BASE = 0x204000
+
+	SPI0_CS  = SPI0_BASE + 0x00
+	CS_TXD   = 18
+	CS_RXD   = 17
+	CS_DONE  = 16
+	CS_TA    = 7
+	CS_CLEAR = 4
+	CS_CPOL  = 3
+	CS_CPHA  = 2
+	CS_CS    = 0
+
+	SPI0_FIFO = SPI0_BASE + 0x04
+	SPI0_CLK  = SPI0_BASE + 0x08
+)
+
+// SPI configuration constants
+const (
+	// system (core) clock
+	SPI_CLOCK = 250000000
+	// default transfer timeout
+	SPI_TIMEOUT = 100 * time.Millisecond
+)
+
+// SPI0 pins (alternate function 0)
+var spiPins = []int{
+	7,  // CE1
+	8,  // CE0
+	9,  // MISO
+	10, // MOSI
+	11, // SCLK
+}
+
+var spiMux = sync.Mutex{}
+
+// SPI represents an SPI0 master instance, on a given chip select.
+type SPI struct {
+	// Timeout for SPI transfers
+	Timeout time.Duration
+
+	channel int
+	cdiv    uint32
+	mode    int
+}
+
+// NewSPI configures the SPI0 pins and returns an SPI master instance for the
+// argument chip select (0 or 1).
+func NewSPI(channel int) (spi *SPI, err error) {
+	if channel < 0 || channel > 1 {
+		return nil, fmt.Errorf("invalid SPI channel %d", channel)
+	}
+
+	for _, num := range spiPins {
+		gpio, err := NewGPIO(num)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = gpio.SelectFunction(GPIO_FN0); err != nil {
+			return nil, err
+		}
+	}
+
+	spi = &SPI{
+		Timeout: SPI_TIMEOUT,
+		channel: channel,
+	}
+
+	return spi, spi.Configure(1000000, 0)
+}
+
+// Configure sets the SPI clock speed, rounded down to the nearest supported
+// value, and mode (0-3, CPOL and CPHA as bits 1 and 0).
+func (spi *SPI) Configure(speed uint32, mode int) (err error) {
+	if speed == 0 {
+		return errors.New("invalid speed")
+	}
+
+	if mode < 0 || mode > 3 {
+		return errors.New("invalid mode")
+	}
+
+	// the clock divisor must be a power of 2, rounded up to obtain a
+	// speed not greater than requested
+	cdiv := uint32(2)
+
+	for SPI_CLOCK/cdiv > speed && cdiv < 0x8000 {
+		cdiv <<= 1
+	}
+
+	spi.cdiv = cdiv
+	spi.mode = mode
+
+	return
+}
+
+// Transfer performs a full duplex transfer, returning the data received while
+// transmitting the argument buffer.
+func (spi *SPI) Transfer(tx []byte) (rx []byte, err error) {
+	spiMux.Lock()
+	defer spiMux.Unlock()
+
+	cs := PeripheralAddress(SPI0_CS)
+	fifo := PeripheralAddress(SPI0_FIFO)
+
+	reg.Write(PeripheralAddress(SPI0_CLK), spi.cdiv)
+
+	ctrl := uint32(spi.channel) << CS_CS
+	ctrl |= uint32(spi.mode&0b10>>1) << CS_CPOL
+	ctrl |= uint32(spi.mode&0b01) << CS_CPHA
+
+	// clear FIFOs
+	reg.Write(cs, ctrl|0b11<<CS_CLEAR)
+	// start transfer
+	reg.Write(cs, ctrl|1<<CS_TA)
+
+	defer reg.Clear(cs, CS_TA)
+
+	rx = make([]byte, len(tx))
+	start := time.Now()
+
+	for w, r := 0, 0; r < len(rx); {
+		for w < len(tx) && reg.Get(cs, CS_TXD, 1) == 1 {
+			reg.Write(fifo, uint32(tx[w]))
+			w++
+		}
+
+		for r < len(rx) && reg.Get(cs, CS_RXD, 1) == 1 {
+			rx[r] = byte(reg.Read(fifo))
+			r++
+		}
+
+		if time.Since(start) > spi.Timeout {
+			return nil, errors.New("SPI transfer timeout")
+		}
+
+		runtime.Gosched()
+	}
+
+	if !reg.WaitFor(spi.Timeout, cs, CS_DONE, 1, 1) {
+		return nil, errors.New("SPI transfer timeout")
+	}
+
+	return
+}
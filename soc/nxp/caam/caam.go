@@ -28,6 +28,7 @@ import (
 const (
 	CAAM_RTMCTL     = 0x600
 	RTMCTL_PRGM     = 16
+	RTMCTL_ERR      = 12
 	RTMCTL_ENT_VAL  = 10
 	RTMCTL_RST_DEF  = 6
 	RTMCTL_TRNG_ACC = 5
@@ -10,12 +10,22 @@
 package caam
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/usbarmory/tamago/internal/reg"
 	"github.com/usbarmory/tamago/internal/rng"
 )
 
-// GetRandomData returns len(b) random bytes gathered from the CAAM TRNG.
-func (hw *CAAM) GetRandomData(b []byte) {
+// ENTROPY_TIMEOUT is the maximum time FillEntropy waits for each TRNG entropy
+// generation cycle.
+const ENTROPY_TIMEOUT = 1 * time.Second
+
+// fill reads TRNG entropy into the argument buffer, a zero timeout waits
+// indefinitely for each generation cycle and ignores TRNG errors, as required
+// by the runtime before timers are available.
+func (hw *CAAM) fill(b []byte, timeout time.Duration) (err error) {
 	hw.Lock()
 	defer hw.Unlock()
 
@@ -24,8 +34,20 @@ func (hw *CAAM) GetRandomData(b []byte) {
 
 	for read < need {
 		if hw.rtenta == hw.rtent0 {
-			for reg.Get(hw.rtmctl, RTMCTL_ENT_VAL, 1) == 0 {
-				// wait for valid entropy
+			if timeout == 0 {
+				for reg.Get(hw.rtmctl, RTMCTL_ENT_VAL, 1) == 0 {
+					// wait for valid entropy
+				}
+			} else if !reg.WaitFor(timeout, hw.rtmctl, RTMCTL_ENT_VAL, 1, 1) {
+				err = errors.New("timeout waiting for valid entropy")
+			}
+
+			if timeout != 0 && reg.Get(hw.rtmctl, RTMCTL_ERR, 1) == 1 {
+				err = fmt.Errorf("TRNG error, rtmctl:%#x", reg.Read(hw.rtmctl))
+			}
+
+			if err != nil {
+				return
 			}
 		}
 
@@ -37,4 +59,40 @@ func (hw *CAAM) GetRandomData(b []byte) {
 			hw.rtenta += 4
 		}
 	}
+
+	return
+}
+
+// GetRandomData returns len(b) random bytes gathered from the CAAM TRNG.
+func (hw *CAAM) GetRandomData(b []byte) {
+	hw.fill(b, 0)
+}
+
+// FillEntropy fills the argument buffer with raw entropy read directly from
+// the CAAM TRNG, without any DRBG post-processing.
+//
+// The TRNG is slow (each 512-bit entropy sample requires a full generation
+// cycle), therefore this function is meant only for occasional high assurance
+// seed generation. Unlike GetRandomData, which is used by the runtime before
+// timers are available, it returns an error if entropy generation times out
+// or if the TRNG reports an error.
+func (hw *CAAM) FillEntropy(buf []byte) (err error) {
+	if hw.rtmctl == 0 {
+		return errors.New("CAAM is not initialized")
+	}
+
+	return hw.fill(buf, ENTROPY_TIMEOUT)
+}
+
+// SelfTest runs the NIST SP 800-90B continuous health tests on a sample of
+// the raw CAAM TRNG entropy (see FillEntropy()), returning an error if the
+// entropy source appears to be failing.
+func (hw *CAAM) SelfTest() (err error) {
+	sample := make([]byte, rng.HEALTH_SAMPLE_SIZE)
+
+	if err = hw.FillEntropy(sample); err != nil {
+		return
+	}
+
+	return rng.HealthTest(sample)
 }
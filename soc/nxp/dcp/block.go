@@ -0,0 +1,159 @@
+// NXP Data Co-Processor (DCP) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package dcp
+
+import (
+	"crypto/aes"
+	"errors"
+	"sync"
+
+	"github.com/usbarmory/tamago/bits"
+	"github.com/usbarmory/tamago/dma"
+)
+
+// Cipher implements cipher.Block using single block AES-128-ECB DCP
+// operations on a key RAM slot.
+type Cipher struct {
+	sync.Mutex
+
+	dcp   *DCP
+	index int
+
+	// first hardware error
+	err error
+}
+
+// NewCipher returns a cipher.Block instance, backed by the DCP, which uses the
+// AES-128 key previously set with SetKey() or DeriveKey() on the key RAM slot
+// selected by the index argument.
+//
+// The returned instance can be used with the crypto/cipher block modes (e.g.
+// cipher.NewCBCEncrypter()), each block operation is performed through a DMA
+// buffer which is reserved once per DCP instance, and zeroed after each
+// operation.
+//
+// As the cipher.Block interface does not allow to return errors, in case of
+// hardware errors the Encrypt and Decrypt functions zero the output block and
+// the error is retained, to be checked with Err(). The EncryptBlock and
+// DecryptBlock functions can be used to directly check errors on each
+// operation.
+func (hw *DCP) NewCipher(index int) (*Cipher, error) {
+	if index < 0 || index > 3 {
+		return nil, errors.New("key index must be between 0 and 3")
+	}
+
+	c := &Cipher{
+		dcp:   hw,
+		index: index,
+	}
+
+	return c, nil
+}
+
+// BlockSize returns the AES block size.
+func (c *Cipher) BlockSize() int {
+	return aes.BlockSize
+}
+
+// Encrypt encrypts the first block in src into dst.
+func (c *Cipher) Encrypt(dst, src []byte) {
+	c.check(dst, c.EncryptBlock(dst, src))
+}
+
+// Decrypt decrypts the first block in src into dst.
+func (c *Cipher) Decrypt(dst, src []byte) {
+	c.check(dst, c.DecryptBlock(dst, src))
+}
+
+// EncryptBlock encrypts the first block in src into dst.
+func (c *Cipher) EncryptBlock(dst, src []byte) error {
+	return c.crypt(dst, src, true)
+}
+
+// DecryptBlock decrypts the first block in src into dst.
+func (c *Cipher) DecryptBlock(dst, src []byte) error {
+	return c.crypt(dst, src, false)
+}
+
+// Err returns the first hardware error encountered by Encrypt or Decrypt.
+func (c *Cipher) Err() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.err
+}
+
+func (c *Cipher) check(dst []byte, err error) {
+	if err == nil {
+		return
+	}
+
+	for i := 0; i < aes.BlockSize && i < len(dst); i++ {
+		dst[i] = 0
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *Cipher) crypt(dst, src []byte, enc bool) (err error) {
+	if len(src) < aes.BlockSize {
+		return errors.New("input not full block")
+	}
+
+	if len(dst) < aes.BlockSize {
+		return errors.New("output not full block")
+	}
+
+	pkt := &WorkPacket{}
+	pkt.SetCipherDefaults()
+
+	bits.Clear(&pkt.Control0, DCP_CTRL0_CIPHER_INIT)
+	bits.SetN(&pkt.Control1, DCP_CTRL1_CIPHER_MODE, 0xf, CIPHER_MODE_ECB)
+
+	if enc {
+		pkt.Control0 |= 1 << DCP_CTRL0_CIPHER_ENCRYPT
+	}
+
+	hw := c.dcp
+
+	hw.blockMutex.Lock()
+	defer hw.blockMutex.Unlock()
+
+	if hw.blockBuf == nil {
+		hw.blockAddr, hw.blockBuf = dma.Reserve(aes.BlockSize+WorkPacketLength, aes.BlockSize)
+	}
+
+	block := hw.blockBuf[:aes.BlockSize]
+	defer zero(block)
+
+	copy(block, src[:aes.BlockSize])
+
+	// use key RAM slot
+	pkt.Control1 |= (uint32(c.index) & 0xff) << DCP_CTRL1_KEY_SELECT
+	pkt.SourceBufferAddress = uint32(hw.blockAddr)
+	pkt.DestinationBufferAddress = pkt.SourceBufferAddress
+	pkt.BufferSize = aes.BlockSize
+
+	ptr := hw.blockAddr + aes.BlockSize
+	copy(hw.blockBuf[aes.BlockSize:], pkt.Bytes())
+
+	if err = hw.cmd(ptr, 1); err != nil {
+		return
+	}
+
+	copy(dst[:aes.BlockSize], block)
+
+	return
+}
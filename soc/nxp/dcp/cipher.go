@@ -30,7 +30,7 @@ func (pkt *WorkPacket) SetCipherDefaults() {
 	pkt.Control1 |= CIPHER_MODE_CBC << DCP_CTRL1_CIPHER_MODE
 }
 
-func (hw *DCP) cipher(buf []byte, index int, iv []byte, enc bool) (err error) {
+func (hw *DCP) cipher(buf []byte, index int, iv []byte, mode uint32, enc bool) (err error) {
 	if len(buf)%aes.BlockSize != 0 {
 		return errors.New("invalid input size")
 	}
@@ -39,19 +39,26 @@ func (hw *DCP) cipher(buf []byte, index int, iv []byte, enc bool) (err error) {
 		return errors.New("key index must be between 0 and 3")
 	}
 
-	if len(iv) != aes.BlockSize {
+	if mode == CIPHER_MODE_CBC && len(iv) != aes.BlockSize {
 		return errors.New("invalid IV size")
 	}
 
 	sourceBufferAddress := dma.Alloc(buf, aes.BlockSize)
 	defer dma.Free(sourceBufferAddress)
 
-	payloadPointer := dma.Alloc(iv, 4)
-	defer dma.Free(payloadPointer)
-
 	pkt := &WorkPacket{}
 	pkt.SetCipherDefaults()
 
+	if mode == CIPHER_MODE_CBC {
+		payloadPointer := dma.Alloc(iv, 4)
+		defer dma.Free(payloadPointer)
+
+		pkt.PayloadPointer = uint32(payloadPointer)
+	} else {
+		bits.Clear(&pkt.Control0, DCP_CTRL0_CIPHER_INIT)
+		bits.SetN(&pkt.Control1, DCP_CTRL1_CIPHER_MODE, 0xf, mode)
+	}
+
 	if enc {
 		pkt.Control0 |= 1 << DCP_CTRL0_CIPHER_ENCRYPT
 	}
@@ -61,7 +68,6 @@ func (hw *DCP) cipher(buf []byte, index int, iv []byte, enc bool) (err error) {
 	pkt.SourceBufferAddress = uint32(sourceBufferAddress)
 	pkt.DestinationBufferAddress = pkt.SourceBufferAddress
 	pkt.BufferSize = uint32(len(buf))
-	pkt.PayloadPointer = uint32(payloadPointer)
 
 	ptr := dma.Alloc(pkt.Bytes(), 4)
 	defer dma.Free(ptr)
@@ -76,15 +82,41 @@ func (hw *DCP) cipher(buf []byte, index int, iv []byte, enc bool) (err error) {
 }
 
 // Encrypt performs in-place buffer encryption using AES-128-CBC, the key can
-// be selected with the index argument from one previously set with SetKey().
+// be selected with the index argument from one previously set with SetKey()
+// or DeriveKey().
+//
+// The buffer size must be a multiple of the AES block size.
 func (hw *DCP) Encrypt(buf []byte, index int, iv []byte) (err error) {
-	return hw.cipher(buf, index, iv, true)
+	return hw.cipher(buf, index, iv, CIPHER_MODE_CBC, true)
 }
 
 // Decrypt performs in-place buffer decryption using AES-128-CBC, the key can
-// be selected with the index argument from one previously set with SetKey().
+// be selected with the index argument from one previously set with SetKey()
+// or DeriveKey().
+//
+// The buffer size must be a multiple of the AES block size.
 func (hw *DCP) Decrypt(buf []byte, index int, iv []byte) (err error) {
-	return hw.cipher(buf, index, iv, false)
+	return hw.cipher(buf, index, iv, CIPHER_MODE_CBC, false)
+}
+
+// EncryptECB performs in-place buffer encryption using AES-128-ECB, the key
+// can be selected with the index argument from one previously set with
+// SetKey() or DeriveKey().
+//
+// The buffer size must be a multiple of the AES block size, each block is
+// ciphered independently.
+func (hw *DCP) EncryptECB(buf []byte, index int) (err error) {
+	return hw.cipher(buf, index, nil, CIPHER_MODE_ECB, true)
+}
+
+// DecryptECB performs in-place buffer decryption using AES-128-ECB, the key
+// can be selected with the index argument from one previously set with
+// SetKey() or DeriveKey().
+//
+// The buffer size must be a multiple of the AES block size, each block is
+// ciphered independently.
+func (hw *DCP) DecryptECB(buf []byte, index int) (err error) {
+	return hw.cipher(buf, index, nil, CIPHER_MODE_ECB, false)
 }
 
 // CipherChain performs chained in-place buffer encryption/decryption using
@@ -155,6 +187,90 @@ func (hw *DCP) CipherChain(buf []byte, ivs []byte, count int, size int, index in
 	return
 }
 
+// EncryptChain performs in-place encryption of multiple buffers using
+// AES-128-CBC, the key can be selected with the index argument from one
+// previously set with SetKey() or DeriveKey().
+//
+// The buffers are ciphered as a single CBC stream, initialized with the
+// argument IV, through a chain of work packets submitted at once, each buffer
+// size must be a multiple of the AES block size.
+func (hw *DCP) EncryptChain(bufs [][]byte, index int, iv []byte) (err error) {
+	count := len(bufs)
+
+	if count == 0 {
+		return errors.New("invalid input size")
+	}
+
+	for _, buf := range bufs {
+		if len(buf) == 0 || len(buf)%aes.BlockSize != 0 {
+			return errors.New("invalid input size")
+		}
+	}
+
+	if index < 0 || index > 3 {
+		return errors.New("key index must be between 0 and 3")
+	}
+
+	if len(iv) != aes.BlockSize {
+		return errors.New("invalid IV size")
+	}
+
+	srcs := make([]uint, count)
+
+	for i, buf := range bufs {
+		srcs[i] = dma.Alloc(buf, aes.BlockSize)
+		defer dma.Free(srcs[i])
+	}
+
+	payloadPointer := dma.Alloc(iv, 4)
+	defer dma.Free(payloadPointer)
+
+	pkts, pktBuf := dma.Reserve(WorkPacketLength*count, 4)
+	defer dma.Release(pkts)
+
+	pkt := &WorkPacket{}
+	pkt.SetCipherDefaults()
+	pkt.Control0 |= 1 << DCP_CTRL0_CHAIN
+	pkt.Control0 |= 1 << DCP_CTRL0_CIPHER_ENCRYPT
+	pkt.PayloadPointer = uint32(payloadPointer)
+
+	bits.Clear(&pkt.Control0, DCP_CTRL0_INTERRUPT_ENABL)
+
+	// use key RAM slot
+	pkt.Control1 |= (uint32(index) & 0xff) << DCP_CTRL1_KEY_SELECT
+
+	for i := 0; i < count; i++ {
+		pkt.SourceBufferAddress = uint32(srcs[i])
+		pkt.DestinationBufferAddress = pkt.SourceBufferAddress
+		pkt.BufferSize = uint32(len(bufs[i]))
+
+		if i > 0 {
+			// continue CBC from the previous packet context
+			bits.Clear(&pkt.Control0, DCP_CTRL0_CIPHER_INIT)
+		}
+
+		if i < count-1 {
+			pkt.NextCmdAddr = uint32(pkts) + uint32((i+1)*WorkPacketLength)
+		} else {
+			pkt.NextCmdAddr = 0
+			bits.Clear(&pkt.Control0, DCP_CTRL0_CHAIN)
+			bits.Set(&pkt.Control0, DCP_CTRL0_INTERRUPT_ENABL)
+		}
+
+		copy(pktBuf[i*WorkPacketLength:], pkt.Bytes())
+	}
+
+	if err = hw.cmd(pkts, count); err != nil {
+		return
+	}
+
+	for i, buf := range bufs {
+		dma.Read(srcs[i], 0, buf)
+	}
+
+	return
+}
+
 func pad(buf []byte, extraBlock bool) []byte {
 	padLen := 0
 	r := len(buf) % aes.BlockSize
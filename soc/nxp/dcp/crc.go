@@ -0,0 +1,226 @@
+// NXP Data Co-Processor (DCP) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package dcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// CRC32 polynomials
+const (
+	// p1064, 13.2.5.3 Hashing, MCIMX28RM
+	CRC32_POLY = 0x04c11db7
+)
+
+// CRC32 work packet buffer size
+const CRC32_CHUNK_SIZE = 64 * 1024
+
+// crc32Init is the DCP CRC32 initial value.
+const crc32Init = 0xffffffff
+
+// CRC32 returns the CRC32 checksum of the data, computed by the DCP with an
+// initial value of 0xffffffff and without input or output reflection.
+//
+// The DCP only supports the CRC32_POLY polynomial, any other polynomial
+// results in an error.
+//
+// The input data is processed in CRC32_CHUNK_SIZE buffers, each submitted
+// with its own work packet chained to the previous one through the DCP hash
+// initialization and termination flags, therefore DMA memory is only
+// required for a single chunk.
+//
+// As the DCP hash state is shared with SHA256 digests, an error is returned if
+// a digest instance is in use (see New256()).
+func (hw *DCP) CRC32(data []byte, poly uint32) (crc uint32, err error) {
+	if poly != CRC32_POLY {
+		return 0, errors.New("unsupported polynomial")
+	}
+
+	if len(data) == 0 {
+		return 0, errors.New("invalid input size")
+	}
+
+	d := &crcDigest{
+		dcp: hw,
+	}
+
+	if _, err = d.Write(data); err != nil {
+		return
+	}
+
+	return d.sum32()
+}
+
+// crcDigest implements hash.Hash32 for DCP CRC32 checksums.
+type crcDigest struct {
+	dcp *DCP
+	// the digest instance holds the DCP hash state
+	active bool
+	// the next work packet initializes the hash
+	init bool
+	// partial, or last, chunk
+	buf []byte
+
+	// terminated digest checksum and error
+	done bool
+	crc  uint32
+	err  error
+}
+
+// NewCRC32 returns a new hash.Hash32 computing the CRC32 checksum, with the
+// CRC32_POLY polynomial (see CRC32()).
+//
+// Written data is streamed to the DCP in CRC32_CHUNK_SIZE buffers, chained
+// through the DCP hash initialization and termination flags. As the DCP hash
+// state is shared with SHA256 digests, the digest instance is acquired on the
+// first Write and terminated on the first Sum or on Reset, in between no other
+// digest instance can be used (see New256()). After Sum is invoked the
+// checksum is retained and Write returns errors until Reset is invoked.
+//
+// As hash.Hash32 does not allow to return errors, Sum and Sum32 panic in case
+// of hardware errors.
+func (hw *DCP) NewCRC32() hash.Hash32 {
+	return &crcDigest{
+		dcp: hw,
+	}
+}
+
+func (d *crcDigest) release() {
+	if d.active {
+		d.active = false
+		sem.Release(1)
+	}
+}
+
+func (d *crcDigest) update(term bool) (err error) {
+	sum, err := d.dcp.hash(d.buf, HASH_SELECT_CRC32, d.init, term, false)
+
+	if err != nil {
+		return
+	}
+
+	d.init = false
+	d.buf = d.buf[:0]
+
+	if term {
+		// the output is reversed by hash()
+		d.crc = binary.BigEndian.Uint32(sum[len(sum)-4:])
+	}
+
+	return
+}
+
+func (d *crcDigest) terminate(crc uint32, err error) {
+	d.release()
+
+	d.done = true
+	d.crc = crc
+	d.err = err
+}
+
+// Write adds more data to the running hash. It returns an error if another
+// digest instance is in use, if Sum has been already invoked or in case of
+// hardware errors.
+//
+// A full chunk is only submitted once more data is written, so that the last
+// work packet, submitted by Sum, is never empty.
+func (d *crcDigest) Write(p []byte) (n int, err error) {
+	if d.done {
+		return 0, errors.New("digest instance can no longer be used")
+	}
+
+	if !d.active && len(p) > 0 {
+		if !sem.TryAcquire(1) {
+			return 0, errors.New("another digest instance is already in use")
+		}
+
+		d.active = true
+		d.init = true
+	}
+
+	for len(p) > 0 {
+		if len(d.buf) == CRC32_CHUNK_SIZE {
+			if err = d.update(false); err != nil {
+				d.terminate(0, err)
+				return
+			}
+		}
+
+		c := CRC32_CHUNK_SIZE - len(d.buf)
+
+		if c > len(p) {
+			c = len(p)
+		}
+
+		d.buf = append(d.buf, p[0:c]...)
+		p = p[c:]
+		n += c
+	}
+
+	return
+}
+
+func (d *crcDigest) sum32() (uint32, error) {
+	if d.done {
+		return d.crc, d.err
+	}
+
+	if !d.active {
+		// no data has been written
+		d.terminate(crc32Init, nil)
+	} else {
+		err := d.update(true)
+		d.terminate(d.crc, err)
+	}
+
+	return d.crc, d.err
+}
+
+// Sum32 returns the current hash, its invocation terminates the digest
+// instance.
+func (d *crcDigest) Sum32() uint32 {
+	crc, err := d.sum32()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return crc
+}
+
+// Sum appends the current hash, in big-endian byte order, to b and returns
+// the resulting slice, its invocation terminates the digest instance.
+func (d *crcDigest) Sum(b []byte) []byte {
+	s := d.Sum32()
+	return append(b, byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+// Reset resets the hash to its initial state, terminating any running digest
+// instance.
+func (d *crcDigest) Reset() {
+	d.release()
+
+	d.buf = d.buf[:0]
+	d.done = false
+	d.crc = 0
+	d.err = nil
+}
+
+// Size returns the number of bytes Sum will return.
+func (d *crcDigest) Size() int {
+	return 4
+}
+
+// BlockSize returns the hash's underlying block size.
+func (d *crcDigest) BlockSize() int {
+	return 1
+}
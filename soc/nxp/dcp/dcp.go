@@ -22,10 +22,12 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/usbarmory/tamago/bits"
 	"github.com/usbarmory/tamago/dma"
 	"github.com/usbarmory/tamago/internal/reg"
+	"github.com/usbarmory/tamago/soc/nxp/snvs"
 )
 
 // DCP registers
@@ -90,12 +92,20 @@ const (
 	KEY_SELECT_UNIQUE_KEY = 0xfe
 
 	DCP_CTRL1_CIPHER_MODE = 4
+	CIPHER_MODE_ECB       = 0x00
 	CIPHER_MODE_CBC       = 0x01
 
 	DCP_CTRL1_CIPHER_SELECT = 0
 	CIPHER_SELECT_AES128    = 0x00
 )
 
+// Configuration constants
+const (
+	// CommandTimeout is the default timeout for DCP work packet
+	// completion.
+	CommandTimeout = 10 * time.Second
+)
+
 const WorkPacketLength = 32
 
 // WorkPacket represents a DCP work packet
@@ -121,6 +131,17 @@ type DCP struct {
 	CCGR uint32
 	// Clock gate
 	CG int
+	// Timeout for DCP work packet completion, on expiration the DCP is
+	// reset which also clears keys previously set with SetKey()
+	CommandTimeout time.Duration
+
+	// SNVS instance
+	SNVS *snvs.SNVS
+	// AllowTestKey permits DeriveKey() when the SNVS is not available (see
+	// snvs.Available()), by default derivation fails in such case to
+	// prevent use of the non-unique test key. Applications that knowingly
+	// want to use the test key can set it to true.
+	AllowTestKey bool
 
 	// DeriveKeyMemory represents the DMA memory region used for exchanging DCP
 	// derived keys when the derivation index points to an internal DCP key RAM
@@ -136,6 +157,12 @@ type DCP struct {
 	// needs to be avoided or is already used as non-default DMA region.
 	DeriveKeyMemory *dma.Region
 
+	// single block operations DMA buffer (see Cipher), holding the data
+	// block followed by its work packet
+	blockMutex sync.Mutex
+	blockAddr  uint
+	blockBuf   []byte
+
 	// control registers
 	ctrl        uint32
 	stat        uint32
@@ -176,9 +203,19 @@ func (hw *DCP) Init() {
 	hw.ch0stat = hw.Base + DCP_CH0STAT
 	hw.ch0stat_clr = hw.Base + DCP_CH0STAT_CLR
 
+	if hw.CommandTimeout == 0 {
+		hw.CommandTimeout = CommandTimeout
+	}
+
 	// enable clock
 	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
 
+	hw.reset()
+}
+
+// reset performs a DCP soft reset, aborting any pending work packet, and
+// re-enables channel 0.
+func (hw *DCP) reset() {
 	// soft reset DCP
 	reg.Set(hw.ctrl, CTRL_SFTRST)
 	reg.Clear(hw.ctrl, CTRL_SFTRST)
@@ -186,6 +223,10 @@ func (hw *DCP) Init() {
 	// enable DCP
 	reg.Clear(hw.ctrl, CTRL_CLKGATE)
 
+	// the soft reset clears the channel semaphore, clear any status
+	reg.Write(hw.ch0stat_clr, 0xffffffff)
+	reg.Write(hw.stat_clr, 0xffffffff)
+
 	// enable channel 0
 	reg.Write(hw.chctrl, DCP_CHANNEL_0)
 }
@@ -206,7 +247,16 @@ func (hw *DCP) cmd(ptr uint, count int) (err error) {
 	// activate channel
 	reg.SetN(hw.ch0sema, 0, 0xff, uint32(count))
 	// wait for completion
-	reg.Wait(hw.stat, DCP_STAT_IRQ, DCP_CHANNEL_0, 1)
+	if !reg.WaitFor(hw.CommandTimeout, hw.stat, DCP_STAT_IRQ, DCP_CHANNEL_0, 1) {
+		sema := reg.Read(hw.ch0sema)
+
+		// Abort the pending work packets before returning, as callers
+		// release the DMA buffers the channel might still be using.
+		hw.reset()
+
+		return fmt.Errorf("DCP channel 0 timeout, sema:%#x", sema)
+	}
+
 	// clear interrupt register
 	reg.Set(hw.stat_clr, DCP_CHANNEL_0)
 
@@ -10,6 +10,7 @@
 package dcp
 
 import (
+	"github.com/usbarmory/tamago/bits"
 	"github.com/usbarmory/tamago/dma"
 )
 
@@ -23,9 +24,17 @@ func (pkt *WorkPacket) SetHashDefaults() {
 	pkt.Control1 |= HASH_SELECT_SHA256 << DCP_CTRL1_HASH_SELECT
 }
 
-func (hw *DCP) hash(buf []byte, mode uint32, init bool, term bool) (sum []byte, err error) {
+// hash submits a hash work packet, when wipe is true the DMA buffers holding
+// the data and the checksum are zeroed before being released.
+func (hw *DCP) hash(buf []byte, mode uint32, init bool, term bool, wipe bool) (sum []byte, err error) {
+	free := dma.Free
+
+	if wipe {
+		free = dma.FreeZero
+	}
+
 	sourceBufferAddress := dma.Alloc(buf, 4)
-	defer dma.Free(sourceBufferAddress)
+	defer free(sourceBufferAddress)
 
 	pkt := &WorkPacket{}
 	pkt.SetHashDefaults()
@@ -41,7 +50,7 @@ func (hw *DCP) hash(buf []byte, mode uint32, init bool, term bool) (sum []byte,
 		sum = make([]byte, 32)
 
 		payloadPointer := dma.Alloc(sum, 4)
-		defer dma.Free(payloadPointer)
+		defer free(payloadPointer)
 
 		pkt.Control0 |= 1 << DCP_CTRL0_HASH_TERM
 		pkt.PayloadPointer = uint32(payloadPointer)
@@ -55,7 +64,7 @@ func (hw *DCP) hash(buf []byte, mode uint32, init bool, term bool) (sum []byte,
 		}()
 	}
 
-	pkt.Control1 |= mode << DCP_CTRL1_HASH_SELECT
+	bits.SetN(&pkt.Control1, DCP_CTRL1_HASH_SELECT, 0xf, mode)
 
 	ptr := dma.Alloc(pkt.Bytes(), 4)
 	defer dma.Free(ptr)
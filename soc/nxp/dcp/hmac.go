@@ -0,0 +1,75 @@
+// NXP Data Co-Processor (DCP) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package dcp
+
+// HMAC256 returns the HMAC-SHA256 of the message, computed with the DCP for
+// both inner and outer hashes (RFC 2104).
+//
+// Keys longer than the SHA256 block size are hashed first, intermediate
+// buffers holding key material, including DMA ones, are cleared before
+// returning.
+//
+// There must be sufficient DMA memory allocated to hold the data, otherwise
+// the function will panic.
+func (hw *DCP) HMAC256(key []byte, msg []byte) (mac []byte, err error) {
+	k := make([]byte, blockSize)
+
+	if len(key) > blockSize {
+		sum, err := hw.sum256(key, true)
+
+		if err != nil {
+			return nil, err
+		}
+
+		copy(k, sum[:])
+		zero(sum[:])
+	} else {
+		copy(k, key)
+	}
+
+	inner := make([]byte, blockSize+len(msg))
+	defer zero(inner)
+
+	outer := make([]byte, blockSize+32)
+	defer zero(outer)
+
+	for i := range k {
+		inner[i] = k[i] ^ 0x36
+		outer[i] = k[i] ^ 0x5c
+	}
+
+	// the key is no longer needed once the pads are built
+	zero(k)
+
+	copy(inner[blockSize:], msg)
+
+	sum, err := hw.sum256(inner, true)
+	defer zero(sum[:])
+
+	if err != nil {
+		return
+	}
+
+	copy(outer[blockSize:], sum[:])
+
+	res, err := hw.sum256(outer, true)
+
+	if err != nil {
+		return
+	}
+
+	return res[:], nil
+}
+
+func zero(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
@@ -26,7 +26,8 @@ import (
 // enabled).
 //
 // *WARNING*: when SNVS is not enabled a default non-unique test vector is used
-// and therefore key derivation is *unsafe*, see snvs.Available().
+// and therefore key derivation is *unsafe*, see snvs.Available(). In this case
+// an error is returned unless AllowTestKey is true.
 //
 // A negative index argument results in the derived key being computed and
 // returned.
@@ -41,6 +42,10 @@ func (hw *DCP) DeriveKey(diversifier []byte, iv []byte, index int) (key []byte,
 		return nil, errors.New("invalid IV size")
 	}
 
+	if !hw.AllowTestKey && (hw.SNVS == nil || !hw.SNVS.Available()) {
+		return nil, errors.New("SNVS not available, refusing derivation with test key")
+	}
+
 	// prepare diversifier for in-place encryption
 	key = pad(diversifier, false)
 
@@ -59,13 +64,19 @@ func (hw *DCP) DeriveKey(diversifier []byte, iv []byte, index int) (key []byte,
 		}
 	}
 
-	sourceBufferAddress := region.Alloc(key, aes.BlockSize)
-	defer region.Free(sourceBufferAddress)
+	sourceBufferAddress, err := region.AllocZeroed(len(key), aes.BlockSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer region.FreeZero(sourceBufferAddress)
+
+	region.Write(sourceBufferAddress, 0, key)
 
 	payloadPointer := region.Alloc(iv, 0)
 	defer region.Free(payloadPointer)
 
-
 	pkt := &WorkPacket{}
 	pkt.SetCipherDefaults()
 
@@ -135,3 +146,9 @@ func (hw *DCP) setKeyData(index int, key []byte, addr uint32) (err error) {
 func (hw *DCP) SetKey(index int, key []byte) (err error) {
 	return hw.setKeyData(index, key, 0)
 }
+
+// ClearKey zeroes the AES-128 key in one of the 4 available slots of the DCP
+// key RAM.
+func (hw *DCP) ClearKey(index int) (err error) {
+	return hw.setKeyData(index, make([]byte, aes.BlockSize), 0)
+}
@@ -12,6 +12,7 @@ package dcp
 import (
 	"crypto/sha256"
 	"errors"
+	"hash"
 	"io"
 
 	"golang.org/x/sync/semaphore"
@@ -78,7 +79,7 @@ func (d *digest) Write(p []byte) (n int, err error) {
 	d.buf = append(d.buf, p[:cut]...)
 	p = p[cut:]
 
-	if _, err = d.dcp.hash(d.buf, d.mode, d.init, false); err != nil {
+	if _, err = d.dcp.hash(d.buf, d.mode, d.init, false, false); err != nil {
 		return
 	}
 
@@ -90,7 +91,7 @@ func (d *digest) Write(p []byte) (n int, err error) {
 	if l := len(p); l > d.bs {
 		r := l % d.bs
 
-		if _, err = d.dcp.hash(p[:l-r], d.mode, d.init, false); err != nil {
+		if _, err = d.dcp.hash(p[:l-r], d.mode, d.init, false, false); err != nil {
 			return
 		}
 
@@ -116,7 +117,7 @@ func (d *digest) Sum(in []byte) (sum []byte, err error) {
 	if d.init && len(d.buf) == 0 {
 		d.sum = sha256.New().Sum(nil)
 	} else {
-		s, err := d.dcp.hash(d.buf, HASH_SELECT_SHA256, d.init, true)
+		s, err := d.dcp.hash(d.buf, HASH_SELECT_SHA256, d.init, true, false)
 
 		if err != nil {
 			return nil, err
@@ -157,12 +158,103 @@ func (hw *DCP) New256() (Hash, error) {
 	return d, nil
 }
 
+// hash256 wraps a DCP SHA256 digest instance to implement hash.Hash.
+type hash256 struct {
+	dcp *DCP
+	d   Hash
+	sum []byte
+}
+
+// New256Hash returns a new hash.Hash computing the SHA256 checksum, for use
+// with existing code which expects the Go native interface.
+//
+// Written data is streamed to the DCP through the underlying digest instance
+// (see New256()), which is acquired on the first Write and terminated on the
+// first Sum or on Reset, in between no other digest instance can be used. After Sum is invoked the checksum is retained
+// and Write returns errors until Reset is invoked.
+//
+// As hash.Hash does not allow to return errors, Sum panics in case of hardware
+// errors.
+func (hw *DCP) New256Hash() hash.Hash {
+	return &hash256{
+		dcp: hw,
+	}
+}
+
+// Write adds more data to the running hash. It returns an error if another
+// digest instance is in use, if Sum has been already invoked or in case of
+// hardware errors.
+func (h *hash256) Write(p []byte) (n int, err error) {
+	if h.d == nil && h.sum == nil {
+		if h.d, err = h.dcp.New256(); err != nil {
+			return
+		}
+	}
+
+	if h.d == nil {
+		return 0, errors.New("digest instance can no longer be used")
+	}
+
+	return h.d.Write(p)
+}
+
+// Sum appends the current hash to b and returns the resulting slice.
+func (h *hash256) Sum(b []byte) []byte {
+	if h.sum != nil {
+		return append(b, h.sum...)
+	}
+
+	if h.d == nil {
+		h.sum = sha256.New().Sum(nil)
+		return append(b, h.sum...)
+	}
+
+	sum, err := h.d.Sum(nil)
+	h.d = nil
+
+	if err != nil {
+		panic(err)
+	}
+
+	h.sum = sum
+
+	return append(b, h.sum...)
+}
+
+// Reset resets the hash to its initial state, terminating any running digest
+// instance.
+func (h *hash256) Reset() {
+	if h.d != nil {
+		h.d.Sum(nil)
+	}
+
+	h.d = nil
+	h.sum = nil
+}
+
+// Size returns the number of bytes Sum will return.
+func (h *hash256) Size() int {
+	return sha256.Size
+}
+
+// BlockSize returns the hash's underlying block size.
+func (h *hash256) BlockSize() int {
+	return blockSize
+}
+
 // Sum256 returns the SHA256 checksum of the data.
 //
 // There must be sufficient DMA memory allocated to hold the data, otherwise
 // the function will panic.
 func (hw *DCP) Sum256(data []byte) (sum [32]byte, err error) {
-	s, err := hw.hash(data, HASH_SELECT_SHA256, true, true)
+	return hw.sum256(data, false)
+}
+
+// sum256 returns the SHA256 checksum of the data, when wipe is true the DMA
+// buffers are zeroed before being released.
+func (hw *DCP) sum256(data []byte, wipe bool) (sum [32]byte, err error) {
+	s, err := hw.hash(data, HASH_SELECT_SHA256, true, true, wipe)
+	defer zero(s)
 
 	if err != nil {
 		return
@@ -0,0 +1,281 @@
+// NXP Enhanced Configurable SPI (ECSPI) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package ecspi implements a driver for NXP Enhanced Configurable SPI (ECSPI)
+// controllers adopting the following reference specifications:
+//   - IMX6ULLRM - i.MX 6ULL Applications Processor Reference Manual - Rev 1 2017/11
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go on ARM SoCs, see
+// https://github.com/usbarmory/tamago.
+package ecspi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// ECSPI registers
+// (20.7 ECSPI Memory Map/Register Definition, IMX6ULLRM).
+const (
+	ECSPIx_RXDATA = 0x00
+	ECSPIx_TXDATA = 0x04
+
+	ECSPIx_CONREG       = 0x08
+	CONREG_BURST_LENGTH = 20
+	CONREG_CHANNEL_SEL  = 18
+	CONREG_PRE_DIVIDER  = 12
+	CONREG_POST_DIVIDER = 8
+	CONREG_CHANNEL_MODE = 4
+	CONREG_SMC          = 3
+	CONREG_XCH          = 2
+	CONREG_EN           = 0
+
+	ECSPIx_CONFIGREG   = 0x0c
+	CONFIGREG_SCLK_CTL = 20
+	CONFIGREG_DATA_CTL = 16
+	CONFIGREG_SS_POL   = 12
+	CONFIGREG_SS_CTL   = 8
+	CONFIGREG_SCLK_POL = 4
+	CONFIGREG_SCLK_PHA = 0
+
+	ECSPIx_STATREG = 0x18
+	STATREG_TC     = 7
+	STATREG_RR     = 3
+	STATREG_TF     = 2
+
+	ECSPIx_PERIODREG = 0x1c
+)
+
+// ECSPI constants
+const (
+	// number of chip select lines
+	CHANNELS = 4
+	// TX/RX FIFO depth in 32-bit words
+	FIFO_SIZE = 64
+	// maximum burst length in bytes
+	MAX_BURST = FIFO_SIZE * 4
+
+	// Timeout is the default timeout for ECSPI transfers.
+	Timeout = 100 * time.Millisecond
+)
+
+// ECSPI represents an ECSPI controller instance.
+type ECSPI struct {
+	sync.Mutex
+
+	// Controller index
+	Index int
+	// Base register
+	Base uint32
+	// Clock gate register
+	CCGR uint32
+	// Clock gate
+	CG int
+	// Clock retrieval function
+	Clock func() uint32
+	// Timeout for ECSPI transfers
+	Timeout time.Duration
+
+	// control registers
+	rxdata    uint32
+	txdata    uint32
+	conreg    uint32
+	configreg uint32
+	statreg   uint32
+}
+
+// Init initializes the ECSPI controller instance, at this time only master
+// mode is supported by this driver.
+func (hw *ECSPI) Init() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 || hw.CCGR == 0 || hw.Clock == nil {
+		panic("invalid ECSPI controller instance")
+	}
+
+	if hw.Timeout == 0 {
+		hw.Timeout = Timeout
+	}
+
+	hw.rxdata = hw.Base + ECSPIx_RXDATA
+	hw.txdata = hw.Base + ECSPIx_TXDATA
+	hw.conreg = hw.Base + ECSPIx_CONREG
+	hw.configreg = hw.Base + ECSPIx_CONFIGREG
+	hw.statreg = hw.Base + ECSPIx_STATREG
+
+	// enable clock
+	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+
+	// reset controller
+	reg.Clear(hw.conreg, CONREG_EN)
+	reg.Set(hw.conreg, CONREG_EN)
+
+	// configure all channels in master mode
+	reg.SetN(hw.conreg, CONREG_CHANNEL_MODE, 0b1111, 0b1111)
+}
+
+// Configure sets the SPI clock frequency, the SPI mode (0-3, encoding clock
+// polarity and phase) and the chip select line (0-3) used for subsequent
+// transfers.
+//
+// The SPI clock is derived from the controller reference clock through
+// integer dividers, therefore the resulting frequency is the highest one that
+// does not exceed the requested value.
+func (hw *ECSPI) Configure(speedHz uint32, mode int, cs int) (err error) {
+	if speedHz == 0 {
+		return errors.New("invalid speed")
+	}
+
+	if mode < 0 || mode > 3 {
+		return fmt.Errorf("invalid SPI mode %d", mode)
+	}
+
+	if cs < 0 || cs >= CHANNELS {
+		return fmt.Errorf("invalid chip select %d", cs)
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.conreg == 0 {
+		return errors.New("controller is not initialized")
+	}
+
+	pre, post, err := dividers(hw.Clock(), speedHz)
+
+	if err != nil {
+		return
+	}
+
+	cpol := mode>>1&1 == 1
+	cpha := mode&1 == 1
+
+	reg.SetN(hw.conreg, CONREG_PRE_DIVIDER, 0xf, pre)
+	reg.SetN(hw.conreg, CONREG_POST_DIVIDER, 0xf, post)
+	reg.SetN(hw.conreg, CONREG_CHANNEL_SEL, 0b11, uint32(cs))
+
+	reg.SetTo(hw.configreg, CONFIGREG_SCLK_POL+cs, cpol)
+	reg.SetTo(hw.configreg, CONFIGREG_SCLK_CTL+cs, cpol)
+	reg.SetTo(hw.configreg, CONFIGREG_SCLK_PHA+cs, cpha)
+	// active low chip select
+	reg.Clear(hw.configreg, CONFIGREG_SS_POL+cs)
+	// single burst, chip select asserted for its whole duration
+	reg.Clear(hw.configreg, CONFIGREG_SS_CTL+cs)
+
+	return
+}
+
+// dividers returns the pre and post divider values, where the resulting
+// clock is ref / ((pre + 1) * 2^post).
+func dividers(ref uint32, speedHz uint32) (pre uint32, post uint32, err error) {
+	for post = 0; post <= 0xf; post++ {
+		for pre = 0; pre <= 0xf; pre++ {
+			if ref/((pre+1)<<post) <= speedHz {
+				return
+			}
+		}
+	}
+
+	return 0, 0, errors.New("speed too low for reference clock")
+}
+
+// burst performs a single burst transfer, of up to MAX_BURST bytes, by
+// filling the TX FIFO before starting the exchange.
+func (hw *ECSPI) burst(tx []byte, rx []byte) (err error) {
+	n := len(tx)
+
+	// burst length in bits
+	reg.SetN(hw.conreg, CONREG_BURST_LENGTH, 0xfff, uint32(8*n-1))
+
+	// The first word shifted out carries only the (n % 4) residual bytes,
+	// remaining words are shifted out with their MSB first.
+	for off := 0; off < n; {
+		size := 4
+
+		if off == 0 && n%4 != 0 {
+			size = n % 4
+		}
+
+		var word uint32
+
+		for _, c := range tx[off : off+size] {
+			word = word<<8 | uint32(c)
+		}
+
+		reg.Write(hw.txdata, word)
+		off += size
+	}
+
+	// clear transfer completed status (w1c)
+	reg.Write(hw.statreg, 1<<STATREG_TC)
+	reg.Set(hw.conreg, CONREG_XCH)
+
+	if !reg.WaitFor(hw.Timeout, hw.statreg, STATREG_TC, 1, 1) {
+		return errors.New("transfer timeout")
+	}
+
+	for off := 0; off < n; {
+		size := 4
+
+		if off == 0 && n%4 != 0 {
+			size = n % 4
+		}
+
+		if reg.Get(hw.statreg, STATREG_RR, 1) == 0 {
+			return errors.New("receive underrun")
+		}
+
+		word := reg.Read(hw.rxdata)
+
+		for i := size - 1; i >= 0; i-- {
+			rx[off+i] = byte(word)
+			word >>= 8
+		}
+
+		off += size
+	}
+
+	return
+}
+
+// Transfer performs a full duplex transfer on the configured chip select
+// line, returning the data received while transmitting the argument buffer.
+//
+// Transfers larger than MAX_BURST are split across separate bursts, with the
+// chip select line negated between them.
+func (hw *ECSPI) Transfer(tx []byte) (rx []byte, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.conreg == 0 {
+		return nil, errors.New("controller is not initialized")
+	}
+
+	rx = make([]byte, len(tx))
+
+	for off := 0; off < len(tx); off += MAX_BURST {
+		end := off + MAX_BURST
+
+		if end > len(tx) {
+			end = len(tx)
+		}
+
+		if err = hw.burst(tx[off:end], rx[off:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
@@ -75,22 +75,24 @@ func (hw *GPIO) Init(num int) (gpio *Pin, err error) {
 
 // Out configures a GPIO as output.
 func (gpio *Pin) Out() {
-	reg.Set(gpio.dir, gpio.num)
+	// the direction register is shared by all bank pins
+	reg.RMW(gpio.dir, 1<<gpio.num, 1<<gpio.num)
 }
 
 // In configures a GPIO as input.
 func (gpio *Pin) In() {
-	reg.Clear(gpio.dir, gpio.num)
+	reg.RMW(gpio.dir, 1<<gpio.num, 0)
 }
 
 // High configures a GPIO signal as high.
 func (gpio *Pin) High() {
-	reg.Set(gpio.data, gpio.num)
+	// the data register is shared by all bank pins
+	reg.RMW(gpio.data, 1<<gpio.num, 1<<gpio.num)
 }
 
 // Low configures a GPIO signal as low.
 func (gpio *Pin) Low() {
-	reg.Clear(gpio.data, gpio.num)
+	reg.RMW(gpio.data, 1<<gpio.num, 0)
 }
 
 // Value returns the GPIO signal level.
@@ -0,0 +1,240 @@
+// NXP General Purpose Timer (GPT) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package gpt implements a driver for NXP General Purpose Timer (GPT)
+// adopting the following reference specifications:
+//   - IMX6ULLRM - i.MX 6ULL Applications Processor Reference Manual - Rev 1 2017/11
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go on ARM SoCs, see
+// https://github.com/usbarmory/tamago.
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// GPT registers
+// (GPT Memory Map/Register Definition, IMX6ULLRM).
+const (
+	GPTx_CR   = 0x00
+	CR_SWR    = 15
+	CR_FRR    = 9
+	CR_CLKSRC = 6
+	CR_ENMOD  = 1
+	CR_EN     = 0
+
+	GPTx_PR      = 0x04
+	PR_PRESCALER = 0
+
+	GPTx_SR = 0x08
+	SR_ROV  = 5
+	SR_OF1  = 0
+
+	GPTx_IR  = 0x0c
+	IR_OF1IE = 0
+
+	GPTx_OCR1 = 0x10
+	GPTx_CNT  = 0x24
+)
+
+// GPT constants
+const (
+	// peripheral clock source (ipg_clk)
+	CLKSRC_PERIPHERAL = 0b001
+	// high frequency reference clock source (PERCLK_CLK_ROOT)
+	CLKSRC_HIGH_FREQ = 0b010
+
+	// counter frequency
+	TICK_FREQ = 1000000
+
+	// number of output compare channels
+	CHANNELS = 3
+)
+
+// GPT represents a General Purpose Timer instance.
+type GPT struct {
+	// counter extension, accessed atomically and therefore kept as first
+	// field for 64-bit alignment
+	ext uint64
+
+	sync.Mutex
+
+	// Controller index
+	Index int
+	// Base register
+	Base uint32
+	// Clock gate register
+	CCGR uint32
+	// Clock gate (bus, immediately followed by serial clock gate)
+	CG int
+	// Clock retrieval function, for the high frequency reference clock
+	Clock func() uint32
+
+	// control registers
+	cr  uint32
+	sr  uint32
+	ir  uint32
+	cnt uint32
+
+	// compare match handlers
+	handlers [CHANNELS]func()
+}
+
+// Init initializes the GPT instance as a free-running counter with
+// microsecond resolution.
+func (hw *GPT) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 || hw.CCGR == 0 || hw.Clock == nil {
+		panic("invalid GPT instance")
+	}
+
+	hw.cr = hw.Base + GPTx_CR
+	hw.sr = hw.Base + GPTx_SR
+	hw.ir = hw.Base + GPTx_IR
+	hw.cnt = hw.Base + GPTx_CNT
+
+	freq := hw.Clock()
+
+	if freq%TICK_FREQ != 0 || freq/TICK_FREQ > 0x1000 {
+		return fmt.Errorf("unsupported reference clock %d Hz", freq)
+	}
+
+	// enable bus and serial clocks
+	reg.SetN(hw.CCGR, hw.CG, 0b1111, 0b1111)
+
+	// disable and reset timer
+	reg.Write(hw.cr, 0)
+	reg.Write(hw.ir, 0)
+
+	reg.Set(hw.cr, CR_SWR)
+	reg.Wait(hw.cr, CR_SWR, 1, 0)
+
+	reg.SetN(hw.cr, CR_CLKSRC, 0b111, CLKSRC_HIGH_FREQ)
+	reg.Write(hw.Base+GPTx_PR, freq/TICK_FREQ-1)
+
+	// free-running mode, counter reset on enable
+	reg.Set(hw.cr, CR_FRR)
+	reg.Set(hw.cr, CR_ENMOD)
+	// clear all status flags (w1c)
+	reg.Write(hw.sr, 0x3f)
+
+	reg.Set(hw.cr, CR_EN)
+
+	atomic.StoreUint64(&hw.ext, 0)
+
+	return
+}
+
+// Count returns the 32-bit free-running counter value in microseconds.
+func (hw *GPT) Count() uint32 {
+	return reg.Read(hw.cnt)
+}
+
+// Now returns the number of microseconds elapsed since Init(), extending the
+// 32-bit counter to 64 bits by tracking its rollovers.
+//
+// The function must be invoked at least once every rollover period (~71
+// minutes) to maintain monotonicity. It can serve as a runtime timer source
+// by assigning it to arm.CPU.TimerFn, with arm.CPU.TimerMultiplier set to
+// 1000.
+//
+// The function is lock-free and can be invoked concurrently by the runtime
+// and the application.
+func (hw *GPT) Now() int64 {
+	for {
+		prev := atomic.LoadUint64(&hw.ext)
+		cnt := reg.Read(hw.cnt)
+
+		next := prev&^0xffffffff | uint64(cnt)
+
+		if cnt < uint32(prev) {
+			next += 1 << 32
+		}
+
+		if atomic.CompareAndSwapUint64(&hw.ext, prev, next) {
+			return int64(next)
+		}
+	}
+}
+
+// Compare configures the argument output compare channel (1-3) to match
+// after the argument number of microseconds, at which point the handler is
+// invoked by ServiceInterrupt(). A nil handler disables the channel.
+//
+// The application is responsible for invoking ServiceInterrupt() from its IRQ
+// exception handler (see arm.SystemExceptionHandler) and for enabling IRQ
+// exceptions (see arm.CPU.EnableInterrupts()).
+func (hw *GPT) Compare(ch int, us uint32, fn func()) (err error) {
+	if ch < 1 || ch > CHANNELS {
+		return fmt.Errorf("invalid channel %d", ch)
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.cr == 0 {
+		return errors.New("timer is not initialized")
+	}
+
+	pos := IR_OF1IE + ch - 1
+
+	if fn == nil {
+		reg.Clear(hw.ir, pos)
+		hw.handlers[ch-1] = nil
+		return
+	}
+
+	hw.handlers[ch-1] = fn
+
+	reg.Write(hw.Base+GPTx_OCR1+uint32(4*(ch-1)), reg.Read(hw.cnt)+us)
+	reg.Write(hw.sr, 1<<(SR_OF1+ch-1))
+	reg.Set(hw.ir, pos)
+
+	return
+}
+
+// ServiceInterrupt clears all output compare events, disabling the matched
+// channels, and invokes the corresponding handlers registered with
+// Compare().
+func (hw *GPT) ServiceInterrupt() {
+	var fns []func()
+
+	hw.Lock()
+
+	sr := reg.Read(hw.sr)
+	reg.Write(hw.sr, sr)
+
+	for ch := 0; ch < CHANNELS; ch++ {
+		if sr&(1<<(SR_OF1+ch)) == 0 || reg.Get(hw.ir, IR_OF1IE+ch, 1) == 0 {
+			continue
+		}
+
+		reg.Clear(hw.ir, IR_OF1IE+ch)
+
+		if fn := hw.handlers[ch]; fn != nil {
+			fns = append(fns, fn)
+			hw.handlers[ch] = nil
+		}
+	}
+
+	hw.Unlock()
+
+	// handlers are invoked without holding the lock to allow re-arming
+	for _, fn := range fns {
+		fn()
+	}
+}
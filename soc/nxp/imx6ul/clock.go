@@ -31,6 +31,10 @@ const (
 	CSCDR1_UART_CLK_SEL  = 6
 	CSCDR1_UART_CLK_PODF = 0
 
+	CCM_CSCDR2            = 0x020c4038
+	CSCDR2_ECSPI_CLK_PODF = 19
+	CSCDR2_ECSPI_CLK_SEL  = 18
+
 	CCM_CSCMR1            = 0x020c401c
 	CSCMR1_USDHC2_CLK_SEL = 17
 	CSCMR1_USDHC1_CLK_SEL = 16
@@ -74,6 +78,7 @@ const (
 	CCM_CCGR1 = 0x020c406c
 	CCM_CCGR2 = 0x020c4070
 	CCM_CCGR3 = 0x020c4074
+	CCM_CCGR4 = 0x020c4078
 	CCM_CCGR5 = 0x020c407c
 	CCM_CCGR6 = 0x020c4080
 
@@ -384,6 +389,23 @@ func GetUARTClock() uint32 {
 	return freq / (podf + 1)
 }
 
+// GetECSPIClock returns the ECSPI_CLK_ROOT frequency,
+// (p630, Figure 18-3. Clock Tree - Part 2, IMX6ULLRM).
+func GetECSPIClock() uint32 {
+	var freq uint32
+
+	if reg.Get(CCM_CSCDR2, CSCDR2_ECSPI_CLK_SEL, 1) == 1 {
+		freq = OSC_FREQ
+	} else {
+		// match /8 static divider (p630, Figure 18-3. Clock Tree - Part 2, IMX6ULLRM)
+		freq = PLL3_FREQ / 8
+	}
+
+	podf := reg.Get(CCM_CSCDR2, CSCDR2_ECSPI_CLK_PODF, 0b111111)
+
+	return freq / (podf + 1)
+}
+
 // GetUSDHCClock returns the USDHCx_CLK_ROOT clock by reading CSCMR1[USDHCx_CLK_SEL]
 // and CSCDR1[USDHCx_PODF]
 // (p629, Figure 18-2. Clock Tree - Part 1, IMX6ULLRM)
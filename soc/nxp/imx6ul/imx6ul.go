@@ -38,15 +38,19 @@ import (
 	"github.com/usbarmory/tamago/soc/nxp/caam"
 	"github.com/usbarmory/tamago/soc/nxp/csu"
 	"github.com/usbarmory/tamago/soc/nxp/dcp"
+	"github.com/usbarmory/tamago/soc/nxp/ecspi"
 	"github.com/usbarmory/tamago/soc/nxp/enet"
 	"github.com/usbarmory/tamago/soc/nxp/gpio"
+	"github.com/usbarmory/tamago/soc/nxp/gpt"
 	"github.com/usbarmory/tamago/soc/nxp/i2c"
 	"github.com/usbarmory/tamago/soc/nxp/ocotp"
+	"github.com/usbarmory/tamago/soc/nxp/pwm"
 	"github.com/usbarmory/tamago/soc/nxp/rngb"
 	"github.com/usbarmory/tamago/soc/nxp/snvs"
 	"github.com/usbarmory/tamago/soc/nxp/uart"
 	"github.com/usbarmory/tamago/soc/nxp/usb"
 	"github.com/usbarmory/tamago/soc/nxp/usdhc"
+	"github.com/usbarmory/tamago/soc/nxp/wdog"
 )
 
 // Peripheral registers
@@ -63,6 +67,12 @@ const (
 	// Data Co-Processor (ULL/ULZ only)
 	DCP_BASE = 0x02280000
 
+	// Enhanced Configurable SPI
+	ECSPI1_BASE = 0x02008000
+	ECSPI2_BASE = 0x0200c000
+	ECSPI3_BASE = 0x02010000
+	ECSPI4_BASE = 0x02014000
+
 	// General Interrupt Controller
 	GIC_BASE = 0x00a00000
 
@@ -73,6 +83,10 @@ const (
 	GPIO4_BASE = 0x020a8000
 	GPIO5_BASE = 0x020ac000
 
+	// General Purpose Timer
+	GPT1_BASE = 0x02098000
+	GPT2_BASE = 0x020e8000
+
 	// Ethernet MAC (UL/ULL only)
 	ENET1_BASE = 0x02188000
 	ENET2_BASE = 0x020b4000
@@ -89,6 +103,16 @@ const (
 	OCRAM_START = 0x00900000
 	OCRAM_SIZE  = 0x20000
 
+	// Pulse Width Modulation
+	PWM1_BASE = 0x02080000
+	PWM2_BASE = 0x02084000
+	PWM3_BASE = 0x02088000
+	PWM4_BASE = 0x0208c000
+	PWM5_BASE = 0x020f0000
+	PWM6_BASE = 0x020f4000
+	PWM7_BASE = 0x020f8000
+	PWM8_BASE = 0x020fc000
+
 	// True Random Number Generator (ULL/ULZ only)
 	RNGB_BASE = 0x02284000
 
@@ -118,6 +142,11 @@ const (
 	// SD/MMC
 	USDHC1_BASE = 0x02190000
 	USDHC2_BASE = 0x02194000
+
+	// Watchdog Timers
+	WDOG1_BASE = 0x020bc000
+	WDOG2_BASE = 0x020c0000
+	WDOG3_BASE = 0x021e4000
 )
 
 // Peripheral instances
@@ -141,6 +170,42 @@ var (
 	// Data Co-Processor (ULL/ULZ only)
 	DCP *dcp.DCP
 
+	// ECSPI controller 1
+	ECSPI1 = &ecspi.ECSPI{
+		Index: 1,
+		Base:  ECSPI1_BASE,
+		CCGR:  CCM_CCGR1,
+		CG:    CCGRx_CG0,
+		Clock: GetECSPIClock,
+	}
+
+	// ECSPI controller 2
+	ECSPI2 = &ecspi.ECSPI{
+		Index: 2,
+		Base:  ECSPI2_BASE,
+		CCGR:  CCM_CCGR1,
+		CG:    CCGRx_CG1,
+		Clock: GetECSPIClock,
+	}
+
+	// ECSPI controller 3
+	ECSPI3 = &ecspi.ECSPI{
+		Index: 3,
+		Base:  ECSPI3_BASE,
+		CCGR:  CCM_CCGR1,
+		CG:    CCGRx_CG2,
+		Clock: GetECSPIClock,
+	}
+
+	// ECSPI controller 4
+	ECSPI4 = &ecspi.ECSPI{
+		Index: 4,
+		Base:  ECSPI4_BASE,
+		CCGR:  CCM_CCGR1,
+		CG:    CCGRx_CG3,
+		Clock: GetECSPIClock,
+	}
+
 	// GPIO controller 1
 	GPIO1 = &gpio.GPIO{
 		Index: 1,
@@ -185,6 +250,24 @@ var (
 	ENET1 *enet.ENET
 	ENET2 *enet.ENET
 
+	// General Purpose Timer 1
+	GPT1 = &gpt.GPT{
+		Index: 1,
+		Base:  GPT1_BASE,
+		CCGR:  CCM_CCGR1,
+		CG:    CCGRx_CG10,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// General Purpose Timer 2
+	GPT2 = &gpt.GPT{
+		Index: 2,
+		Base:  GPT2_BASE,
+		CCGR:  CCM_CCGR0,
+		CG:    CCGRx_CG12,
+		Clock: GetHighFrequencyClock,
+	}
+
 	// I2C controller 1
 	I2C1 = &i2c.I2C{
 		Index: 1,
@@ -212,6 +295,78 @@ var (
 	// True Random Number Generator (ULL/ULZ only)
 	RNGB *rngb.RNGB
 
+	// PWM controller 1
+	PWM1 = &pwm.PWM{
+		Index: 1,
+		Base:  PWM1_BASE,
+		CCGR:  CCM_CCGR4,
+		CG:    CCGRx_CG8,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 2
+	PWM2 = &pwm.PWM{
+		Index: 2,
+		Base:  PWM2_BASE,
+		CCGR:  CCM_CCGR4,
+		CG:    CCGRx_CG9,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 3
+	PWM3 = &pwm.PWM{
+		Index: 3,
+		Base:  PWM3_BASE,
+		CCGR:  CCM_CCGR4,
+		CG:    CCGRx_CG10,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 4
+	PWM4 = &pwm.PWM{
+		Index: 4,
+		Base:  PWM4_BASE,
+		CCGR:  CCM_CCGR4,
+		CG:    CCGRx_CG11,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 5
+	PWM5 = &pwm.PWM{
+		Index: 5,
+		Base:  PWM5_BASE,
+		CCGR:  CCM_CCGR6,
+		CG:    CCGRx_CG13,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 6
+	PWM6 = &pwm.PWM{
+		Index: 6,
+		Base:  PWM6_BASE,
+		CCGR:  CCM_CCGR6,
+		CG:    CCGRx_CG14,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 7
+	PWM7 = &pwm.PWM{
+		Index: 7,
+		Base:  PWM7_BASE,
+		CCGR:  CCM_CCGR6,
+		CG:    CCGRx_CG15,
+		Clock: GetHighFrequencyClock,
+	}
+
+	// PWM controller 8
+	PWM8 = &pwm.PWM{
+		Index: 8,
+		Base:  PWM8_BASE,
+		CCGR:  CCM_CCGR6,
+		CG:    CCGRx_CG8,
+		Clock: GetHighFrequencyClock,
+	}
+
 	// Secure Non-Volatile Storage
 	SNVS = &snvs.SNVS{
 		Base: SNVS_BASE,
@@ -284,6 +439,24 @@ var (
 		CG:       CCGRx_CG2,
 		SetClock: SetUSDHCClock,
 	}
+
+	// Watchdog Timer 1
+	WDOG1 = &wdog.WDOG{
+		Index: 1,
+		Base:  WDOG1_BASE,
+	}
+
+	// Watchdog Timer 2
+	WDOG2 = &wdog.WDOG{
+		Index: 2,
+		Base:  WDOG2_BASE,
+	}
+
+	// Watchdog Timer 3
+	WDOG3 = &wdog.WDOG{
+		Index: 3,
+		Base:  WDOG3_BASE,
+	}
 )
 
 // SiliconVersion returns the SoC silicon version information
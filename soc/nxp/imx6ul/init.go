@@ -93,9 +93,10 @@ func init() {
 	case "i.MX6ULL", "i.MX6ULZ":
 		// Data Co-Processor
 		DCP = &dcp.DCP{
-			Base:            DCP_BASE,
-			CCGR:            CCM_CCGR0,
-			CG:              CCGRx_CG5,
+			Base: DCP_BASE,
+			CCGR: CCM_CCGR0,
+			CG:   CCGRx_CG5,
+			SNVS: SNVS,
 			// assign internal OCRAM to DCP internal key exchange
 			DeriveKeyMemory: dma.Default(),
 		}
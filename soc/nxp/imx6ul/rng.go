@@ -19,17 +19,57 @@ import (
 	"github.com/usbarmory/tamago/soc/nxp/rngb"
 )
 
+// DRBG_RESEED_INTERVAL is the default number of requests after which the
+// CAAM seeded DRBG is reseeded.
+const DRBG_RESEED_INTERVAL = 1 << 16
+
+// Random number generator sources
+const (
+	// CAAM TRNG seeded DRBG (i.MX6UL default)
+	RNG_CAAM = "caam"
+	// RNGB TRNG (i.MX6ULL/i.MX6ULZ default)
+	RNG_RNGB = "rngb"
+)
+
+// PreferredRNG overrides the model based selection of the random number
+// generator source (see RNG_CAAM, RNG_RNGB), the selected source must be
+// present on the running model. Any other value results in a panic during
+// initialization.
+//
+// As the random number generator is initialized before any Go package
+// initialization takes place, the override can only be set at link time:
+//
+//	-ldflags "-X github.com/usbarmory/tamago/soc/nxp/imx6ul.PreferredRNG=rngb"
+var PreferredRNG string
+
+// CAAM seeded DRBG instance
+var drbg *rng.DRBG
+
 //go:linkname initRNG runtime.initRNG
 func initRNG() {
 	if !Native {
-		drbg := &rng.DRBG{}
+		// emulated targets lack an entropy source, the DRBG is
+		// seeded with the time and flagged as insecure
+		rng.Insecure = true
+		drbg = &rng.DRBG{}
 		binary.LittleEndian.PutUint64(drbg.Seed[:], uint64(time.Now().UnixNano()))
 		rng.GetRandomDataFn = drbg.GetRandomData
 		return
 	}
 
-	switch Model() {
-	case "i.MX6UL":
+	source := PreferredRNG
+
+	if source == "" {
+		switch Model() {
+		case "i.MX6UL":
+			source = RNG_CAAM
+		case "i.MX6ULL", "i.MX6ULZ":
+			source = RNG_RNGB
+		}
+	}
+
+	switch source {
+	case RNG_CAAM:
 		// Cryptographic Acceleration and Assurance Module
 		CAAM = &caam.CAAM{
 			Base: CAAM_BASE,
@@ -38,19 +78,62 @@ func initRNG() {
 		}
 		CAAM.Init()
 
+		if err := CAAM.SelfTest(); err != nil {
+			panic("imx6ul: CAAM TRNG health test failure\n")
+		}
+
 		// The CAAM TRNG is too slow for direct use, therefore
-		// we use it to seed an AES-CTR based DRBG.
-		drbg := &rng.DRBG{}
+		// we use it to seed an AES-CTR based DRBG, periodically
+		// reseeded.
+		drbg = &rng.DRBG{
+			Source:         CAAM.GetRandomData,
+			ReseedInterval: DRBG_RESEED_INTERVAL,
+		}
 		CAAM.GetRandomData(drbg.Seed[:])
 
 		rng.GetRandomDataFn = drbg.GetRandomData
-	case "i.MX6ULL", "i.MX6ULZ":
+	case RNG_RNGB:
 		// True Random Number Generator
 		RNGB = &rngb.RNGB{
 			Base: RNGB_BASE,
 		}
 		RNGB.Init()
 
+		if err := RNGB.SelfTest(); err != nil {
+			panic("imx6ul: RNGB health test failure\n")
+		}
+
 		rng.GetRandomDataFn = RNGB.GetRandomData
+	default:
+		// never leave crypto/rand without a source
+		panic("imx6ul: invalid RNG source, see PreferredRNG\n")
+	}
+}
+
+// SetDRBGReseedInterval sets the number of random data requests after which
+// the CAAM seeded DRBG, used by default on i.MX6UL, is reseeded from the CAAM
+// TRNG (0 disables automatic reseeding).
+//
+// The function has no effect when the DRBG is not in use.
+func SetDRBGReseedInterval(requests uint64) {
+	if drbg != nil {
+		drbg.SetReseedInterval(requests)
 	}
 }
+
+// ReseedDRBG reseeds the CAAM seeded DRBG, used by default on i.MX6UL, from
+// the CAAM TRNG.
+//
+// The function has no effect when the DRBG is not in use.
+func ReseedDRBG() {
+	if drbg != nil {
+		drbg.Reseed()
+	}
+}
+
+// InsecureRNG returns whether the random number generator, backing Go
+// `crypto/rand`, lacks a hardware entropy source and is therefore unsuitable
+// for cryptographic use (e.g. emulated targets).
+func InsecureRNG() bool {
+	return rng.Insecure
+}
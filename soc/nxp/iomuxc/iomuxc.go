@@ -15,6 +15,7 @@
 package iomuxc
 
 import (
+	"github.com/usbarmory/tamago/bits"
 	"github.com/usbarmory/tamago/internal/reg"
 )
 
@@ -97,3 +98,79 @@ func (pad *Pad) Select(input uint32) {
 
 	reg.Write(pad.Daisy, input)
 }
+
+// PadCtl represents a pad control register value (see Pad.Ctl()), allowing
+// its fields to be composed before a single register write.
+type PadCtl uint32
+
+// PullUp enables the pull-up resistor with the argument strength (one of
+// SW_PAD_CTL_PUS_PULL_UP_*), leaving other fields unaffected.
+func (ctl *PadCtl) PullUp(strength uint32) {
+	bits.SetN((*uint32)(ctl), SW_PAD_CTL_PUS, 0b11, strength)
+	bits.Set((*uint32)(ctl), SW_PAD_CTL_PUE)
+	bits.Set((*uint32)(ctl), SW_PAD_CTL_PKE)
+}
+
+// PullDown enables the pull-down resistor with the argument strength (one of
+// SW_PAD_CTL_PUS_PULL_DOWN_*), leaving other fields unaffected.
+func (ctl *PadCtl) PullDown(strength uint32) {
+	bits.SetN((*uint32)(ctl), SW_PAD_CTL_PUS, 0b11, strength)
+	bits.Set((*uint32)(ctl), SW_PAD_CTL_PUE)
+	bits.Set((*uint32)(ctl), SW_PAD_CTL_PKE)
+}
+
+// DriveStrength configures the drive strength (e.g. SW_PAD_CTL_DSE_2_R0_6),
+// leaving other fields unaffected.
+func (ctl *PadCtl) DriveStrength(level uint32) {
+	bits.SetN((*uint32)(ctl), SW_PAD_CTL_DSE, 0b111, level)
+}
+
+// Speed configures the speed (e.g. SW_PAD_CTL_SPEED_100MHZ), leaving other
+// fields unaffected.
+func (ctl *PadCtl) Speed(level uint32) {
+	bits.SetN((*uint32)(ctl), SW_PAD_CTL_SPEED, 0b11, level)
+}
+
+// Hysteresis enables or disables the input hysteresis, leaving other fields
+// unaffected.
+func (ctl *PadCtl) Hysteresis(enabled bool) {
+	bits.SetTo((*uint32)(ctl), SW_PAD_CTL_HYS, enabled)
+}
+
+// update applies the argument function to the pad control register value,
+// with a single register write.
+func (pad *Pad) update(fn func(ctl *PadCtl)) {
+	ctl := PadCtl(reg.Read(pad.Pad))
+	fn(&ctl)
+	pad.Ctl(uint32(ctl))
+}
+
+// PullUp enables the pad pull-up resistor with the argument strength (one of
+// SW_PAD_CTL_PUS_PULL_UP_*), leaving other pad control fields unaffected.
+func (pad *Pad) PullUp(strength uint32) {
+	pad.update(func(ctl *PadCtl) { ctl.PullUp(strength) })
+}
+
+// PullDown enables the pad pull-down resistor with the argument strength (one
+// of SW_PAD_CTL_PUS_PULL_DOWN_*), leaving other pad control fields unaffected.
+func (pad *Pad) PullDown(strength uint32) {
+	pad.update(func(ctl *PadCtl) { ctl.PullDown(strength) })
+}
+
+// DriveStrength configures the pad drive strength (e.g.
+// SW_PAD_CTL_DSE_2_R0_6), leaving other pad control fields unaffected.
+func (pad *Pad) DriveStrength(level uint32) {
+	pad.update(func(ctl *PadCtl) { ctl.DriveStrength(level) })
+}
+
+// Speed configures the pad speed (e.g. SW_PAD_CTL_SPEED_100MHZ), leaving
+// other pad control fields unaffected.
+func (pad *Pad) Speed(level uint32) {
+	pad.update(func(ctl *PadCtl) { ctl.Speed(level) })
+}
+
+// Hysteresis enables or disables the pad input hysteresis, leaving other pad
+// control fields unaffected.
+func (pad *Pad) Hysteresis(enabled bool) {
+	pad.update(func(ctl *PadCtl) { ctl.Hysteresis(enabled) })
+}
@@ -0,0 +1,147 @@
+// NXP Pulse Width Modulation (PWM) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package pwm implements a driver for NXP Pulse Width Modulation (PWM)
+// controllers adopting the following reference specifications:
+//   - IMX6ULLRM - i.MX 6ULL Applications Processor Reference Manual - Rev 1 2017/11
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go on ARM SoCs, see
+// https://github.com/usbarmory/tamago.
+package pwm
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// PWM registers
+// (PWM Memory Map/Register Definition, IMX6ULLRM).
+const (
+	PWMx_PWMCR     = 0x00
+	PWMCR_CLKSRC   = 16
+	PWMCR_PRESCALE = 4
+	PWMCR_SWR      = 3
+	PWMCR_EN       = 0
+
+	PWMx_PWMSR  = 0x04
+	PWMx_PWMIR  = 0x08
+	PWMx_PWMSAR = 0x0c
+	PWMx_PWMPR  = 0x10
+	PWMx_PWMCNR = 0x14
+)
+
+// PWM constants
+const (
+	// high frequency clock source (ipg_clk_highfreq)
+	CLKSRC_HIGHFREQ = 0b10
+
+	// maximum period register value
+	MAX_PERIOD = 0xfffe
+	// maximum prescaler value
+	MAX_PRESCALE = 0xfff
+)
+
+// PWM represents a Pulse Width Modulation controller instance.
+type PWM struct {
+	sync.Mutex
+
+	// Controller index
+	Index int
+	// Base register
+	Base uint32
+	// Clock gate register
+	CCGR uint32
+	// Clock gate
+	CG int
+	// Clock retrieval function
+	Clock func() uint32
+
+	// control registers
+	pwmcr  uint32
+	pwmsar uint32
+	pwmpr  uint32
+}
+
+// Init initializes the PWM controller instance, the output remains disabled
+// until configured with Set().
+func (hw *PWM) Init() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 || hw.CCGR == 0 || hw.Clock == nil {
+		panic("invalid PWM controller instance")
+	}
+
+	hw.pwmcr = hw.Base + PWMx_PWMCR
+	hw.pwmsar = hw.Base + PWMx_PWMSAR
+	hw.pwmpr = hw.Base + PWMx_PWMPR
+
+	// enable clock
+	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+
+	reg.Set(hw.pwmcr, PWMCR_SWR)
+	reg.Wait(hw.pwmcr, PWMCR_SWR, 1, 0)
+}
+
+// Set configures and enables the PWM output with the argument frequency (in
+// Hz) and duty cycle (0.0 to 1.0, representing the fraction of each period
+// spent with the output high).
+func (hw *PWM) Set(freq uint32, duty float32) (err error) {
+	if duty < 0 || duty > 1 {
+		return errors.New("invalid duty cycle")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.pwmcr == 0 {
+		return errors.New("controller is not initialized")
+	}
+
+	ref := hw.Clock()
+
+	if freq == 0 || freq > ref/2 {
+		return errors.New("invalid frequency")
+	}
+
+	// the counter period is PWMPR + 2 clock cycles
+	cycles := ref / freq
+	prescale := (cycles - 1) / (MAX_PERIOD + 2)
+
+	if prescale > MAX_PRESCALE {
+		return errors.New("frequency too low for reference clock")
+	}
+
+	period := cycles / (prescale + 1)
+
+	// changes are applied while disabled to reset the sample FIFO
+	reg.Clear(hw.pwmcr, PWMCR_EN)
+
+	reg.SetN(hw.pwmcr, PWMCR_CLKSRC, 0b11, CLKSRC_HIGHFREQ)
+	reg.SetN(hw.pwmcr, PWMCR_PRESCALE, MAX_PRESCALE, prescale)
+
+	reg.Write(hw.pwmpr, period-2)
+	// the last sample is retained when the FIFO is empty
+	reg.Write(hw.pwmsar, uint32(duty*float32(period)))
+
+	reg.Set(hw.pwmcr, PWMCR_EN)
+
+	return
+}
+
+// Disable disables the PWM output.
+func (hw *PWM) Disable() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	reg.Clear(hw.pwmcr, PWMCR_EN)
+}
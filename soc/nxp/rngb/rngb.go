@@ -17,6 +17,8 @@
 package rngb
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/usbarmory/tamago/internal/reg"
@@ -133,3 +135,21 @@ func (hw *RNGB) GetRandomData(b []byte) {
 		}
 	}
 }
+
+// SelfTest returns an error if the RNGB hardware self-test, performed by
+// Init(), or its continuous tests on the entropy source have failed.
+//
+// The raw entropy source is not accessible as the RNGB output is
+// post-processed by its internal PRNG, statistical health tests on such
+// output would not detect source failures and are therefore not performed.
+func (hw *RNGB) SelfTest() error {
+	if reg.Get(hw.sr, RNG_SR_ST_PF, 1) != 0 {
+		return errors.New("self-test failure")
+	}
+
+	if reg.Get(hw.sr, RNG_SR_ERR, 1) != 0 {
+		return fmt.Errorf("entropy source error (esr:%#x)", reg.Read(hw.esr))
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+// NXP Secure Non-Volatile Storage (SNVS) support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package snvs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// SNVS low power real time counter registers
+// (p2929, 46.7 SNVS Memory Map/Register Definition, IMX6ULLRM).
+const (
+	SNVS_LPCR     = 0x38
+	LPCR_SRTC_ENV = 0
+
+	SNVS_LPSRTCMR = 0x50
+	SNVS_LPSRTCLR = 0x54
+
+	// 32.768 kHz counter, 15 fractional bits
+	SRTC_FRAC_BITS = 15
+	// 47-bit counter
+	SRTC_MR_MASK = 0x7fff
+
+	SRTC_TIMEOUT = 100 * time.Millisecond
+)
+
+// counter returns the low power secure real time counter value, which is
+// read until two consecutive reads match as required for asynchronous
+// counters (p2888, 46.4.2.1 SRTC, IMX6ULLRM).
+func (hw *SNVS) counter() (c uint64) {
+	read := func() uint64 {
+		mr := uint64(reg.Read(hw.Base+SNVS_LPSRTCMR) & SRTC_MR_MASK)
+		lr := uint64(reg.Read(hw.Base + SNVS_LPSRTCLR))
+		return mr<<32 | lr
+	}
+
+	for {
+		if c = read(); c == read() {
+			return
+		}
+	}
+}
+
+// Now returns the time held by the low power secure real time counter
+// (SRTC), which is preserved across resets when the SNVS low power domain is
+// battery backed. The counter is interpreted as seconds elapsed since the
+// Unix epoch, as previously configured with Set().
+func (hw *SNVS) Now() time.Time {
+	c := hw.counter()
+
+	sec := int64(c >> SRTC_FRAC_BITS)
+	nsec := int64(c&(1<<SRTC_FRAC_BITS-1)) * int64(time.Second) >> SRTC_FRAC_BITS
+
+	return time.Unix(sec, nsec)
+}
+
+// Set configures the low power secure real time counter (SRTC) to the
+// argument time.
+func (hw *SNVS) Set(t time.Time) (err error) {
+	if hw.Base == 0 {
+		return errors.New("invalid SNVS instance")
+	}
+
+	if t.Unix() < 0 {
+		return errors.New("invalid time")
+	}
+
+	c := uint64(t.Unix())<<SRTC_FRAC_BITS | uint64(t.Nanosecond())<<SRTC_FRAC_BITS/uint64(time.Second)
+	lpcr := hw.Base + SNVS_LPCR
+
+	// the counter can only be written while disabled
+	reg.Clear(lpcr, LPCR_SRTC_ENV)
+
+	if !reg.WaitFor(SRTC_TIMEOUT, lpcr, LPCR_SRTC_ENV, 1, 0) {
+		return errors.New("timeout disabling SRTC")
+	}
+
+	reg.Write(hw.Base+SNVS_LPSRTCMR, uint32(c>>32)&SRTC_MR_MASK)
+	reg.Write(hw.Base+SNVS_LPSRTCLR, uint32(c))
+
+	reg.Set(lpcr, LPCR_SRTC_ENV)
+
+	if !reg.WaitFor(SRTC_TIMEOUT, lpcr, LPCR_SRTC_ENV, 1, 1) {
+		return errors.New("timeout enabling SRTC")
+	}
+
+	return
+}
@@ -20,6 +20,7 @@ package usb
 
 import (
 	"sync"
+	"time"
 
 	"github.com/usbarmory/tamago/internal/reg"
 )
@@ -49,6 +50,9 @@ const (
 	USBSTS_URI      = 6
 	USBSTS_UI       = 0
 
+	USB_UOGx_FRINDEX = 0x14c
+	FRINDEX_FRINDEX  = 3
+
 	USB_UOGx_DEVICEADDR = 0x154
 	DEVICEADDR_USBADR   = 25
 	DEVICEADDR_USBADRA  = 24
@@ -59,9 +63,18 @@ const (
 	USB_UOGx_PORTSC1 = 0x184
 	PORTSC_PTS_1     = 30
 	PORTSC_PSPD      = 26
+	PORTSC_PFSC      = 24
+	PORTSC_PTC       = 16
+	PORTSC_PP        = 12
 	PORTSC_PR        = 8
+	PORTSC_PE        = 2
+	PORTSC_CCS       = 0
 
 	USB_UOGx_OTGSC = 0x1a4
+	OTGSC_BSV      = 11
+	OTGSC_AVV      = 9
+	OTGSC_ID       = 8
+	OTGSC_IDPU     = 5
 	OTGSC_OT       = 3
 
 	USB_UOGx_USBMODE  = 0x1a8
@@ -100,6 +113,17 @@ const (
 	ENDPTCTRL_RXS      = 0
 )
 
+// Configuration constants
+const (
+	// ControlTimeout is the default timeout for control endpoint (EP0)
+	// transfers.
+	ControlTimeout = 1 * time.Second
+
+	// EndpointTimeout is the maximum time allowed for endpoint handlers to
+	// return after endpoint cancellation.
+	EndpointTimeout = 1 * time.Second
+)
+
 // USB represents a USB controller instance.
 type USB struct {
 	sync.Mutex
@@ -118,9 +142,23 @@ type USB struct {
 	PHY uint32
 	// PLL enable function
 	EnablePLL func(index int) error
+	// Timeout for control endpoint (EP0) transfers
+	ControlTimeout time.Duration
 
 	// signal for EP1-N cancellation
 	done chan bool
+	// EP1-N handlers termination signals
+	handlers []chan bool
+	// EP1-N transfer timeouts
+	timeout [MAX_ENDPOINTS][2]time.Duration
+	// EP1-N forced zero length termination
+	zlp [MAX_ENDPOINTS][2]bool
+	// endpoint maximum packet lengths
+	maxPacket [MAX_ENDPOINTS][2]int
+	// test mode flag
+	test bool
+	// device mode flag (see Start())
+	started bool
 
 	// control registers
 	ctrl     uint32
@@ -144,6 +182,10 @@ type USB struct {
 	epListAddr uint32
 	// cache for endpoint queue heads pointers
 	dQH [MAX_ENDPOINTS][2]uint32
+	// preallocated endpoint transfer descriptors and buffers
+	pool [MAX_ENDPOINTS][2]*endpointPool
+	// endpoint statistics
+	stats endpointStats
 }
 
 // Init initializes the USB controller.
@@ -155,6 +197,10 @@ func (hw *USB) Init() {
 		panic("invalid USB controller instance")
 	}
 
+	if hw.ControlTimeout == 0 {
+		hw.ControlTimeout = ControlTimeout
+	}
+
 	hw.ctrl = hw.PHY + USBPHYx_CTRL
 	hw.pwd = hw.PHY + USBPHYx_PWD
 	hw.chrg = hw.Analog + USB_ANALOG_USBx_CHRG_DETECT
@@ -226,12 +272,30 @@ func (hw *USB) Run() {
 	reg.Set(hw.cmd, USBCMD_RS)
 }
 
-// Stop sets the controller in stop mode.
-func (hw *USB) Stop() {
+// Stop cancels all configured endpoints, waiting for their handlers to
+// return, and sets the controller in stop mode.
+//
+// An error is returned if any endpoint handler fails to return within
+// EndpointTimeout, which is possible when its EndpointFunction blocks, in
+// which case Stop() can be invoked again to wait for the remaining handlers.
+func (hw *USB) Stop() (err error) {
+	err = hw.stopEndpoints()
 	reg.Clear(hw.cmd, USBCMD_RS)
+
+	if err == nil {
+		hw.Lock()
+		hw.started = false
+		hw.Unlock()
+	}
+
+	return
 }
 
-// Reset waits for and handles a bus reset.
+// Reset waits for and handles a bus reset, re-initializing the control
+// endpoint (EP0) so that it is ready to receive the first setup packet.
+//
+// Any running endpoint handler must be cancelled before invoking Reset(), as
+// endpoint queue heads are re-initialized.
 func (hw *USB) Reset() {
 	hw.Lock()
 	defer hw.Unlock()
@@ -249,6 +313,10 @@ func (hw *USB) Reset() {
 
 	reg.Wait(hw.sc, PORTSC_PR, 1, 0)
 
+	// Re-initialize the endpoint queue heads as any previous endpoint
+	// configuration, and pending transfer, is no longer valid.
+	hw.initControlEndpoint()
+
 	// clear reset
 	reg.Or(hw.sts, (1<<USBSTS_URI | 1<<USBSTS_UI))
 }
@@ -14,6 +14,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 	"unicode/utf16"
 )
 
@@ -27,6 +28,15 @@ const (
 	DEVICE_QUALIFIER_LENGTH      = 10
 )
 
+// Device class codes for composite devices using Interface Association
+// Descriptors (USB Interface Association Descriptor Device Class Code and Use
+// Model 1.0).
+const (
+	MISCELLANEOUS_DEVICE_CLASS   = 0xef
+	COMMON_CLASS_DEVICE_SUBCLASS = 0x02
+	IAD_DEVICE_PROTOCOL          = 0x01
+)
+
 // DeviceDescriptor implements
 // p290, Table 9-8. Standard Device Descriptor, USB2.0.
 type DeviceDescriptor struct {
@@ -79,6 +89,9 @@ type ConfigurationDescriptor struct {
 	MaxPower           uint8
 
 	Interfaces []*InterfaceDescriptor
+
+	// cached Get Descriptor response
+	buf []byte
 }
 
 // SetDefaults initializes default values for the USB configuration descriptor.
@@ -103,6 +116,8 @@ func (d *ConfigurationDescriptor) AddInterface(iface *InterfaceDescriptor) {
 	}
 
 	d.Interfaces = append(d.Interfaces, iface)
+	iface.conf = d
+	d.buf = nil
 }
 
 // Bytes converts the descriptor structure to byte array format.
@@ -164,6 +179,9 @@ type InterfaceDescriptor struct {
 
 	Endpoints        []*EndpointDescriptor
 	ClassDescriptors [][]byte
+
+	// configuration including the interface (see AddInterface())
+	conf *ConfigurationDescriptor
 }
 
 // SetDefaults initializes default values for the USB interface descriptor.
@@ -173,6 +191,26 @@ func (d *InterfaceDescriptor) SetDefaults() {
 	d.NumEndpoints = 1
 }
 
+// AddEndpoint adds an Endpoint Descriptor to an interface, discarding any
+// cached descriptor of the configuration including it.
+func (d *InterfaceDescriptor) AddEndpoint(ep *EndpointDescriptor) {
+	d.Endpoints = append(d.Endpoints, ep)
+
+	if d.conf != nil {
+		d.conf.buf = nil
+	}
+}
+
+// AddClassDescriptor adds a class-specific descriptor to an interface,
+// discarding any cached descriptor of the configuration including it.
+func (d *InterfaceDescriptor) AddClassDescriptor(desc []byte) {
+	d.ClassDescriptors = append(d.ClassDescriptors, desc)
+
+	if d.conf != nil {
+		d.conf.buf = nil
+	}
+}
+
 // Bytes converts the descriptor structure to byte array format,
 func (d *InterfaceDescriptor) Bytes() []byte {
 	buf := new(bytes.Buffer)
@@ -215,6 +253,9 @@ func (d *InterfaceDescriptor) Bytes() []byte {
 // filled DMA buffer, for transmission to the host, such data is used to fill
 // the transmission queue in advance, to respond to IN requests. The function
 // is invoked by the EndpointHandler to fill the buffer as needed.
+//
+// Returning ErrStall halts the endpoint until the host clears the condition
+// (see CLEAR_FEATURE ENDPOINT_HALT).
 type EndpointFunction func(buf []byte, lastErr error) (res []byte, err error)
 
 // EndpointDescriptor implements
@@ -229,6 +270,13 @@ type EndpointDescriptor struct {
 
 	// Automatic Zero Length Termination
 	Zero bool
+	// Forced Zero Length Termination, a zero length packet is transmitted
+	// after IN transfers which are an exact multiple of the maximum packet
+	// size, overrides Zero as it also applies to multi dTD transfers
+	ForceZero bool
+	// Optional transfer completion timeout, transfers are flushed and
+	// return an error when exceeded (default: no timeout)
+	Timeout time.Duration
 
 	Function EndpointFunction
 }
@@ -323,6 +371,20 @@ func (d *DeviceQualifierDescriptor) SetDefaults() {
 	d.NumConfigurations = 1
 }
 
+// SetFromDevice initializes the USB device qualifier descriptor to match the
+// argument device descriptor, as the fields it mirrors must not differ
+// between operating speeds (p292, 9.6.2 Device_Qualifier, USB2.0).
+func (d *DeviceQualifierDescriptor) SetFromDevice(desc *DeviceDescriptor) {
+	d.SetDefaults()
+
+	d.bcdUSB = desc.bcdUSB
+	d.DeviceClass = desc.DeviceClass
+	d.DeviceSubClass = desc.DeviceSubClass
+	d.DeviceProtocol = desc.DeviceProtocol
+	d.MaxPacketSize = desc.MaxPacketSize
+	d.NumConfigurations = desc.NumConfigurations
+}
+
 // Bytes converts the descriptor structure to byte array format.
 func (d *DeviceQualifierDescriptor) Bytes() []byte {
 	buf := new(bytes.Buffer)
@@ -340,49 +402,112 @@ func (d *DeviceQualifierDescriptor) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// SetupResult represents the outcome of a SetupFunction invocation.
+type SetupResult struct {
+	// Handled signals that the request has been processed and that
+	// standard setup handlers must be skipped, when false the remaining
+	// fields are ignored and the request is passed to standard handlers.
+	Handled bool
+	// In is the data stage buffer for transmission on IN endpoint 0.
+	In []byte
+	// Ack signals that a zero length status packet must be sent when no
+	// data stage buffer is returned.
+	Ack bool
+}
+
 // SetupFunction represents the function to process class-specific setup
 // requests.
 //
-// The function is invoked before standard setup handlers and is expected to
-// return an `in` buffer for transmission on IN endpoint 0, the `ack` boolean
-// can be used to signal whether a zero length packet should be sent (true) in
-// case the `in` buffer returned empty.
-//
-// A non-nil `err` results in a stall. The `done` flag can be used to signal
-// whether standard setup handlers should be invoked (false) or not (true)
-// if function returns with a non-nil error.
-type SetupFunction func(setup *SetupData) (in []byte, ack bool, done bool, err error)
+// The function is invoked before standard setup handlers, a non-nil error
+// results in a stall of the control endpoint with no further processing,
+// otherwise the returned SetupResult determines whether the request is
+// considered handled or it is passed to standard setup handlers.
+type SetupFunction func(setup *SetupData) (res SetupResult, err error)
 
 // Device is a collection of USB device descriptors and host driven settings
 // to represent a USB device.
 type Device struct {
-	Descriptor     *DeviceDescriptor
+	Descriptor *DeviceDescriptor
+	// Optional device qualifier, derived from the device descriptor when
+	// nil (see DeviceQualifier())
 	Qualifier      *DeviceQualifierDescriptor
 	Configurations []*ConfigurationDescriptor
 	Strings        [][]byte
 
+	// Translated string descriptors indexed by language code and string
+	// index (see AddLocalizedString())
+	LocalizedStrings map[uint16]map[uint8][]byte
+
 	// Host requested settings
 	ConfigurationValue uint8
 	AlternateSetting   uint8
 
 	// Optional class-specific setup handler
 	Setup SetupFunction
+
+	// HID report descriptors indexed by interface number
+	HIDReports map[uint8]HIDReportDescriptor
+	// Optional HID report handlers
+	HIDSetReport HIDSetReportFunction
+	HIDGetReport HIDGetReportFunction
+
+	// Optional DFU firmware download and upload handlers
+	DFUWrite DFUWriteFunction
+	DFURead  DFUReadFunction
+	// Optional DFU manifestation handler
+	DFUManifest DFUManifestFunction
+	// Optional DFU detach handler
+	DFUDetach DFUDetachFunction
+
+	// DFU function state
+	dfu dfuState
+	// HID function state
+	hid hidState
+
+	// controller serving the device (see USB.Start())
+	bus *USB
+
+	// cached device descriptor, and the values it was converted from
+	buf  []byte
+	desc DeviceDescriptor
 }
 
-func (d *Device) setStringDescriptor(s []byte, zero bool) (uint8, error) {
+func stringDescriptor(s []byte) ([]byte, error) {
 	var buf []byte
 
 	desc := &StringDescriptor{}
 	desc.SetDefaults()
-	desc.Length += uint8(len(s))
 
-	if desc.Length > 255 {
-		return 0, fmt.Errorf("string descriptor size (%d) cannot exceed 255", desc.Length)
+	if len(s)+int(desc.Length) > 255 {
+		return nil, fmt.Errorf("string descriptor size (%d) cannot exceed 255", len(s)+int(desc.Length))
 	}
 
+	desc.Length += uint8(len(s))
+
 	buf = append(buf, desc.Bytes()...)
 	buf = append(buf, s...)
 
+	return buf, nil
+}
+
+func unicodeString(s string) (buf []byte) {
+	u := utf16.Encode([]rune(s))
+
+	for i := 0; i < len(u); i++ {
+		buf = append(buf, byte(u[i]&0xff))
+		buf = append(buf, byte(u[i]>>8))
+	}
+
+	return
+}
+
+func (d *Device) setStringDescriptor(s []byte, zero bool) (uint8, error) {
+	buf, err := stringDescriptor(s)
+
+	if err != nil {
+		return 0, err
+	}
+
 	if zero && len(d.Strings) >= 1 {
 		d.Strings[0] = buf
 	} else {
@@ -394,13 +519,13 @@ func (d *Device) setStringDescriptor(s []byte, zero bool) (uint8, error) {
 
 // SetLanguageCodes configures String Descriptor Zero language codes
 // (p273, Table 9-15. String Descriptor Zero, Specifying Languages Supported by the Device, USB2.0).
+//
+// Strings added with AddString() are returned for the first language, as
+// well as for any language lacking a translation added with
+// AddLocalizedString().
 func (d *Device) SetLanguageCodes(codes []uint16) (err error) {
 	var buf []byte
 
-	if len(codes) > 1 {
-		return fmt.Errorf("only a single language is currently supported")
-	}
-
 	for i := 0; i < len(codes); i++ {
 		b := make([]byte, 2)
 		binary.LittleEndian.PutUint16(b, codes[i])
@@ -416,20 +541,52 @@ func (d *Device) SetLanguageCodes(codes []uint16) (err error) {
 // be used to fill string descriptor index value in configuration descriptors
 // (p274, Table 9-16. UNICODE String Descriptor, USB2.0).
 func (d *Device) AddString(s string) (uint8, error) {
-	var buf []byte
+	return d.setStringDescriptor(unicodeString(s), false)
+}
 
-	desc := &StringDescriptor{}
-	desc.SetDefaults()
+// AddLocalizedString adds the translation, for the argument language code, of
+// a string descriptor previously added with AddString(). The translation is
+// returned to hosts requesting the string index with the matching language
+// ID (p281, 9.4.3 Get Descriptor, USB2.0).
+func (d *Device) AddLocalizedString(lang uint16, index uint8, s string) (err error) {
+	if index == 0 || int(index) >= len(d.Strings) {
+		return fmt.Errorf("invalid string descriptor index %d", index)
+	}
 
-	r := []rune(s)
-	u := utf16.Encode([]rune(r))
+	buf, err := stringDescriptor(unicodeString(s))
 
-	for i := 0; i < len(u); i++ {
-		buf = append(buf, byte(u[i]&0xff))
-		buf = append(buf, byte(u[i]>>8))
+	if err != nil {
+		return
+	}
+
+	if d.LocalizedStrings == nil {
+		d.LocalizedStrings = make(map[uint16]map[uint8][]byte)
+	}
+
+	if d.LocalizedStrings[lang] == nil {
+		d.LocalizedStrings[lang] = make(map[uint8][]byte)
+	}
+
+	d.LocalizedStrings[lang][index] = buf
+
+	return
+}
+
+// LocalizedString returns the string descriptor for the argument index and
+// language code, falling back to the string added with AddString() when no
+// translation is available.
+func (d *Device) LocalizedString(index uint8, lang uint16) (buf []byte, err error) {
+	if int(index) >= len(d.Strings) {
+		return nil, fmt.Errorf("invalid string descriptor index %d", index)
 	}
 
-	return d.setStringDescriptor(buf, false)
+	if index != 0 {
+		if buf, ok := d.LocalizedStrings[lang][index]; ok {
+			return buf, nil
+		}
+	}
+
+	return d.Strings[index], nil
 }
 
 // AddConfiguration adds a Configuration Descriptor to a device, updating its
@@ -446,9 +603,144 @@ func (d *Device) AddConfiguration(conf *ConfigurationDescriptor) (err error) {
 	return
 }
 
+// Invalidate discards cached descriptors, it must be invoked whenever any
+// configuration hierarchy descriptor is modified directly rather than through
+// Device, ConfigurationDescriptor or InterfaceDescriptor helpers.
+func (d *Device) Invalidate() {
+	d.buf = nil
+
+	for _, conf := range d.Configurations {
+		conf.buf = nil
+	}
+}
+
+// DeviceDescriptor converts the device descriptor to a buffer, as expected by
+// Get Descriptor for device descriptor type (p281, 9.4.3 Get Descriptor,
+// USB2.0). The buffer is cached until any device descriptor field changes.
+func (d *Device) DeviceDescriptor() []byte {
+	if d.buf == nil || d.desc != *d.Descriptor {
+		d.desc = *d.Descriptor
+		d.buf = d.desc.Bytes()
+	}
+
+	return d.buf
+}
+
+// DeviceQualifier converts the device qualifier descriptor to a buffer, as
+// expected by Get Descriptor for device qualifier descriptor type (p281,
+// 9.4.3 Get Descriptor, USB2.0).
+//
+// When the Qualifier field is nil the descriptor is derived from the device
+// one (see DeviceQualifierDescriptor.SetFromDevice()).
+func (d *Device) DeviceQualifier() (buf []byte, err error) {
+	if d.Descriptor == nil {
+		return nil, errors.New("invalid device descriptor")
+	}
+
+	if d.Qualifier != nil {
+		return d.Qualifier.Bytes(), nil
+	}
+
+	qualifier := &DeviceQualifierDescriptor{}
+	qualifier.SetFromDevice(d.Descriptor)
+
+	return qualifier.Bytes(), nil
+}
+
+// AddFunction adds a function, composed of one or more interfaces, to a
+// device configuration for composite device support. The interfaces are
+// added to the configuration, with sequential interface numbers, and are
+// grouped under the argument Interface Association Descriptor which is
+// emitted before the first function interface (p4, Table 9-Z. Interface
+// Association Descriptors, USB2.0 (ECN)).
+//
+// If the IAD argument is nil a default one is created, with function class
+// codes matching the first interface ones.
+//
+// The device descriptor class codes are set to the Miscellaneous Device Class
+// (0xef/0x02/0x01), as required for devices using Interface Association
+// Descriptors.
+func (d *Device) AddFunction(conf *ConfigurationDescriptor, iad *InterfaceAssociationDescriptor, ifaces ...*InterfaceDescriptor) (err error) {
+	found := false
+
+	for _, c := range d.Configurations {
+		if c == conf {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return errors.New("invalid configuration")
+	}
+
+	if len(ifaces) == 0 {
+		return errors.New("function requires at least one interface")
+	}
+
+	if iad == nil {
+		iad = &InterfaceAssociationDescriptor{}
+		iad.SetDefaults()
+		iad.FunctionClass = ifaces[0].InterfaceClass
+		iad.FunctionSubClass = ifaces[0].InterfaceSubClass
+		iad.FunctionProtocol = ifaces[0].InterfaceProtocol
+	}
+
+	iad.FirstInterface = conf.NumInterfaces
+
+	for _, iface := range ifaces {
+		iface.IAD = nil
+		conf.AddInterface(iface)
+	}
+
+	iad.InterfaceCount = conf.NumInterfaces - iad.FirstInterface
+	ifaces[0].IAD = iad
+
+	if d.Descriptor != nil {
+		d.Descriptor.DeviceClass = MISCELLANEOUS_DEVICE_CLASS
+		d.Descriptor.DeviceSubClass = COMMON_CLASS_DEVICE_SUBCLASS
+		d.Descriptor.DeviceProtocol = IAD_DEVICE_PROTOCOL
+	}
+
+	return
+}
+
+// SetHIDReport associates a HID report descriptor to an interface, the
+// descriptor is returned to the host on Get Descriptor requests addressed to
+// such interface (p49, 7.1.1 Get_Descriptor Request, HID1.11).
+func (d *Device) SetHIDReport(iface uint8, report HIDReportDescriptor) {
+	if d.HIDReports == nil {
+		d.HIDReports = make(map[uint8]HIDReportDescriptor)
+	}
+
+	d.HIDReports[iface] = report
+}
+
+// endpoint returns the descriptor matching the argument endpoint address
+// within the active configuration.
+func (d *Device) endpoint(addr uint8) *EndpointDescriptor {
+	for _, conf := range d.Configurations {
+		if conf.ConfigurationValue != d.ConfigurationValue {
+			continue
+		}
+
+		for _, iface := range conf.Interfaces {
+			for _, ep := range iface.Endpoints {
+				if ep.EndpointAddress == addr {
+					return ep
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // Configuration converts the device configuration hierarchy to a buffer, as expected by Get
 // Descriptor for configuration descriptor type
-// (p281, 9.4.3 Get Descriptor, USB2.0).
+// (p281, 9.4.3 Get Descriptor, USB2.0). The buffer is cached until the
+// configuration hierarchy is modified through descriptor helpers, or
+// Invalidate() is invoked, and must not be modified.
 func (d *Device) Configuration(wIndex uint16) (buf []byte, err error) {
 	if int(wIndex+1) > len(d.Configurations) {
 		err = errors.New("invalid configuration index")
@@ -457,14 +749,17 @@ func (d *Device) Configuration(wIndex uint16) (buf []byte, err error) {
 
 	conf := d.Configurations[int(wIndex)]
 
+	if conf.buf != nil {
+		return conf.buf, nil
+	}
+
 	for i := 0; i < len(conf.Interfaces); i++ {
 		iface := conf.Interfaces[i]
 
 		// If an IAD is present set the first interface value, unless
-		// already set, depending on where we are in the interface
-		// list.
+		// already set, to the interface it precedes.
 		if iface.IAD != nil && iface.IAD.FirstInterface == 0 {
-			iface.IAD.FirstInterface = uint8(i)
+			iface.IAD.FirstInterface = iface.InterfaceNumber
 		}
 
 		buf = append(buf, iface.Bytes()...)
@@ -478,5 +773,7 @@ func (d *Device) Configuration(wIndex uint16) (buf []byte, err error) {
 	conf.TotalLength = uint16(int(conf.Length) + len(buf))
 	buf = append(conf.Bytes(), buf...)
 
+	conf.buf = buf
+
 	return
 }
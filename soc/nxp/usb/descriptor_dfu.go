@@ -0,0 +1,133 @@
+// USB descriptor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// DFU descriptor constants
+const (
+	// p11, 4.1.2 Run-Time Descriptor Set, DFU1.1
+	DFU_INTERFACE_CLASS    = 0xfe
+	DFU_INTERFACE_SUBCLASS = 0x01
+	DFU_PROTOCOL_RUNTIME   = 0x01
+	DFU_PROTOCOL_DFU_MODE  = 0x02
+
+	// p13, 4.1.3 Run-Time DFU Functional Descriptor, DFU1.1
+	DFU_FUNCTIONAL        = 0x21
+	DFU_FUNCTIONAL_LENGTH = 9
+
+	DFU_WILL_DETACH            = 3
+	DFU_MANIFESTATION_TOLERANT = 2
+	DFU_CAN_UPLOAD             = 1
+	DFU_CAN_DNLOAD             = 0
+)
+
+// DFU class-specific request codes (p10, Table 3.2, DFU1.1)
+const (
+	DFU_DETACH    = 0
+	DFU_DNLOAD    = 1
+	DFU_UPLOAD    = 2
+	DFU_GETSTATUS = 3
+	DFU_CLRSTATUS = 4
+	DFU_GETSTATE  = 5
+	DFU_ABORT     = 6
+)
+
+// DFU states (p22, 6.1.2 DFU_GETSTATUS Request, DFU1.1)
+const (
+	APP_IDLE                = 0
+	APP_DETACH              = 1
+	DFU_IDLE                = 2
+	DFU_DNLOAD_SYNC         = 3
+	DFU_DNBUSY              = 4
+	DFU_DNLOAD_IDLE         = 5
+	DFU_MANIFEST_SYNC       = 6
+	DFU_MANIFEST            = 7
+	DFU_MANIFEST_WAIT_RESET = 8
+	DFU_UPLOAD_IDLE         = 9
+	DFU_ERROR               = 10
+)
+
+// DFU status codes (p21, 6.1.2 DFU_GETSTATUS Request, DFU1.1)
+const (
+	DFU_STATUS_OK               = 0x00
+	DFU_STATUS_ERR_TARGET       = 0x01
+	DFU_STATUS_ERR_FILE         = 0x02
+	DFU_STATUS_ERR_WRITE        = 0x03
+	DFU_STATUS_ERR_ERASE        = 0x04
+	DFU_STATUS_ERR_CHECK_ERASED = 0x05
+	DFU_STATUS_ERR_PROG         = 0x06
+	DFU_STATUS_ERR_VERIFY       = 0x07
+	DFU_STATUS_ERR_ADDRESS      = 0x08
+	DFU_STATUS_ERR_NOTDONE      = 0x09
+	DFU_STATUS_ERR_FIRMWARE     = 0x0a
+	DFU_STATUS_ERR_VENDOR       = 0x0b
+	DFU_STATUS_ERR_USBR         = 0x0c
+	DFU_STATUS_ERR_POR          = 0x0d
+	DFU_STATUS_ERR_UNKNOWN      = 0x0e
+	DFU_STATUS_ERR_STALLEDPKT   = 0x0f
+)
+
+// DFUFunctionalDescriptor implements
+// p13, Table 4.2 DFU Functional Descriptor, DFU1.1.
+type DFUFunctionalDescriptor struct {
+	Length         uint8
+	DescriptorType uint8
+	Attributes     uint8
+	DetachTimeout  uint16
+	TransferSize   uint16
+	bcdDFUVersion  uint16
+}
+
+// SetDefaults initializes default values for the USB DFU Functional
+// Descriptor.
+func (d *DFUFunctionalDescriptor) SetDefaults() {
+	d.Length = DFU_FUNCTIONAL_LENGTH
+	d.DescriptorType = DFU_FUNCTIONAL
+	d.Attributes = 1<<DFU_MANIFESTATION_TOLERANT | 1<<DFU_CAN_UPLOAD | 1<<DFU_CAN_DNLOAD
+	// 1 second
+	d.DetachTimeout = 1000
+	d.TransferSize = 4096
+	// DFU 1.1
+	d.bcdDFUVersion = 0x0110
+}
+
+// Bytes converts the descriptor structure to byte array format.
+func (d *DFUFunctionalDescriptor) Bytes() []byte {
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.LittleEndian, d.Length)
+	binary.Write(buf, binary.LittleEndian, d.DescriptorType)
+	binary.Write(buf, binary.LittleEndian, d.Attributes)
+	binary.Write(buf, binary.LittleEndian, d.DetachTimeout)
+	binary.Write(buf, binary.LittleEndian, d.TransferSize)
+	binary.Write(buf, binary.LittleEndian, d.bcdDFUVersion)
+
+	return buf.Bytes()
+}
+
+// DFUStatus implements
+// p21, 6.1.2 DFU_GETSTATUS Request, DFU1.1.
+type DFUStatus struct {
+	Status      uint8
+	PollTimeout [3]uint8
+	State       uint8
+	String      uint8
+}
+
+// Bytes converts the descriptor structure to byte array format.
+func (d *DFUStatus) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, d)
+	return buf.Bytes()
+}
@@ -7,7 +7,12 @@ import (
 
 const (
 	HID_DESCRIPTOR_LENGTH = 0x09
-	KEYBOARD_INTERFACE    = 0x21
+	// HID class descriptor type (p49, 7.1 Standard Requests, HID1.11)
+	HID_CLASS_DESCRIPTOR = 0x21
+
+	// Deprecated: use HID_CLASS_DESCRIPTOR, the descriptor type is not
+	// keyboard specific.
+	KEYBOARD_INTERFACE = HID_CLASS_DESCRIPTOR
 )
 
 // HIDDescriptor implements
@@ -22,14 +27,37 @@ type HIDDescriptor struct {
 	ReportDescriptorLength uint16
 }
 
-func (d *HIDDescriptor) SetKeyboardDefaults() {
+// SetDefaults initializes default values for a HID descriptor matching the
+// argument report descriptor, allowing any HID device class (e.g. gamepads)
+// to share the descriptor structure.
+func (d *HIDDescriptor) SetDefaults(report HIDReportDescriptor) {
 	d.Length = HID_DESCRIPTOR_LENGTH
-	d.DescriptorType = KEYBOARD_INTERFACE
+	d.DescriptorType = HID_CLASS_DESCRIPTOR
 	d.bcdHID = 0x101
-	d.CountryCode = 33   // United States
 	d.NumDescriptors = 1 // At least one for the report descriptor
-	d.ReportDescriptorType = 0x22
-	d.ReportDescriptorLength = 0x40
+	d.ReportDescriptorType = HID_REPORT
+	d.ReportDescriptorLength = uint16(len(report))
+}
+
+// SetKeyboardDefaults initializes default values for a HID descriptor
+// matching KeyboardReportDescriptor().
+func (d *HIDDescriptor) SetKeyboardDefaults() {
+	d.SetDefaults(KeyboardReportDescriptor())
+	d.CountryCode = 33 // United States
+}
+
+// SetMouseDefaults initializes default values for a HID descriptor matching
+// MouseReportDescriptor().
+func (d *HIDDescriptor) SetMouseDefaults() {
+	d.SetDefaults(MouseReportDescriptor())
+	d.CountryCode = 0 // Not Supported
+}
+
+// SetConsumerDefaults initializes default values for a HID descriptor
+// matching ConsumerReportDescriptor().
+func (d *HIDDescriptor) SetConsumerDefaults() {
+	d.SetDefaults(ConsumerReportDescriptor())
+	d.CountryCode = 0 // Not Supported
 }
 
 func (d *HIDDescriptor) Bytes() []byte {
@@ -41,8 +69,8 @@ func (d *HIDDescriptor) Bytes() []byte {
 type HIDReportDescriptor []byte
 
 // CoolermasterTKLSReportDescriptor returns bytes ripped from a coolermaster
-// keyboard I had lying around.
-func CoolermasterTKLSReportDescriptor() []byte {
+// keyboard I had lying around, its contents match KeyboardReportDescriptor().
+func CoolermasterTKLSReportDescriptor() HIDReportDescriptor {
 	return []byte{
 		0x05, 0x01, 0x09, 0x06, 0xa1, 0x01, 0x05, 0x07, 0x19, 0xe0, 0x29, 0xe7,
 		0x15, 0x00, 0x25, 0x01, 0x75, 0x01, 0x95, 0x08, 0x81, 0x02, 0x95, 0x01,
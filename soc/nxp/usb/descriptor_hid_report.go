@@ -0,0 +1,360 @@
+// USB HID report descriptor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+// HID report descriptor item types
+// (p26, 6.2.2.2 Short Items, HID1.11).
+const (
+	HID_ITEM_MAIN   = 0
+	HID_ITEM_GLOBAL = 1
+	HID_ITEM_LOCAL  = 2
+)
+
+// HID report descriptor main item tags
+// (p28, 6.2.2.4 Main Items, HID1.11).
+const (
+	HID_INPUT          = 0x8
+	HID_OUTPUT         = 0x9
+	HID_COLLECTION     = 0xa
+	HID_FEATURE        = 0xb
+	HID_END_COLLECTION = 0xc
+)
+
+// HID report descriptor global item tags
+// (p35, 6.2.2.7 Global Items, HID1.11).
+const (
+	HID_USAGE_PAGE       = 0x0
+	HID_LOGICAL_MINIMUM  = 0x1
+	HID_LOGICAL_MAXIMUM  = 0x2
+	HID_PHYSICAL_MINIMUM = 0x3
+	HID_PHYSICAL_MAXIMUM = 0x4
+	HID_UNIT_EXPONENT    = 0x5
+	HID_UNIT             = 0x6
+	HID_REPORT_SIZE      = 0x7
+	HID_REPORT_ID        = 0x8
+	HID_REPORT_COUNT     = 0x9
+)
+
+// HID report descriptor local item tags
+// (p40, 6.2.2.8 Local Items, HID1.11).
+const (
+	HID_USAGE         = 0x0
+	HID_USAGE_MINIMUM = 0x1
+	HID_USAGE_MAXIMUM = 0x2
+)
+
+// HID main item data flags
+// (p30, 6.2.2.5 Input, Output, and Feature Items, HID1.11).
+const (
+	HID_DATA     = 0
+	HID_CONSTANT = 1 << 0
+	HID_ARRAY    = 0
+	HID_VARIABLE = 1 << 1
+	HID_ABSOLUTE = 0
+	HID_RELATIVE = 1 << 2
+	HID_WRAP     = 1 << 3
+	HID_NULL     = 1 << 6
+)
+
+// HID collection types
+// (p33, 6.2.2.6 Collection, End Collection Items, HID1.11).
+const (
+	HID_COLLECTION_PHYSICAL    = 0x00
+	HID_COLLECTION_APPLICATION = 0x01
+	HID_COLLECTION_LOGICAL     = 0x02
+)
+
+// HID Consumer page usages (p75, 15 Consumer Page, HUT1.12).
+const (
+	HID_USAGE_CONSUMER_CONTROL = 0x01
+	HID_USAGE_SCAN_NEXT        = 0xb5
+	HID_USAGE_SCAN_PREVIOUS    = 0xb6
+	HID_USAGE_STOP             = 0xb7
+	HID_USAGE_PLAY_PAUSE       = 0xcd
+	HID_USAGE_MUTE             = 0xe2
+	HID_USAGE_VOLUME_UP        = 0xe9
+	HID_USAGE_VOLUME_DOWN      = 0xea
+)
+
+// HID usage pages (p14, 3 Usage Pages, HUT1.12).
+const (
+	HID_USAGE_PAGE_GENERIC_DESKTOP = 0x01
+	HID_USAGE_PAGE_KEYBOARD        = 0x07
+	HID_USAGE_PAGE_LED             = 0x08
+	HID_USAGE_PAGE_BUTTON          = 0x09
+	HID_USAGE_PAGE_CONSUMER        = 0x0c
+)
+
+// HID Generic Desktop page usages (p26, 4 Generic Desktop Page, HUT1.12).
+const (
+	HID_USAGE_POINTER  = 0x01
+	HID_USAGE_MOUSE    = 0x02
+	HID_USAGE_KEYBOARD = 0x06
+	HID_USAGE_X        = 0x30
+	HID_USAGE_Y        = 0x31
+	HID_USAGE_WHEEL    = 0x38
+)
+
+// HIDReport implements a builder for HID report descriptors, composed of
+// short items as described in p26, 6.2.2.2 Short Items, HID1.11.
+type HIDReport struct {
+	buf []byte
+}
+
+// NewHIDReport returns a new, empty, HID report descriptor builder.
+func NewHIDReport() *HIDReport {
+	return &HIDReport{}
+}
+
+// item appends a short item with the smallest data size able to represent
+// the unsigned argument value.
+func (r *HIDReport) item(typ int, tag int, val uint32) *HIDReport {
+	switch {
+	case val <= 0xff:
+		r.buf = append(r.buf, byte(tag<<4|typ<<2|1), byte(val))
+	case val <= 0xffff:
+		r.buf = append(r.buf, byte(tag<<4|typ<<2|2), byte(val), byte(val>>8))
+	default:
+		r.buf = append(r.buf, byte(tag<<4|typ<<2|3), byte(val), byte(val>>8), byte(val>>16), byte(val>>24))
+	}
+
+	return r
+}
+
+// signedItem appends a short item with the smallest data size able to
+// represent the signed argument value.
+func (r *HIDReport) signedItem(typ int, tag int, val int32) *HIDReport {
+	switch {
+	case val >= -0x80 && val <= 0x7f:
+		r.buf = append(r.buf, byte(tag<<4|typ<<2|1), byte(val))
+	case val >= -0x8000 && val <= 0x7fff:
+		r.buf = append(r.buf, byte(tag<<4|typ<<2|2), byte(val), byte(val>>8))
+	default:
+		r.buf = append(r.buf, byte(tag<<4|typ<<2|3), byte(val), byte(val>>8), byte(val>>16), byte(val>>24))
+	}
+
+	return r
+}
+
+// UsagePage appends a Usage Page global item.
+func (r *HIDReport) UsagePage(page uint16) *HIDReport {
+	return r.item(HID_ITEM_GLOBAL, HID_USAGE_PAGE, uint32(page))
+}
+
+// LogicalMinimum appends a Logical Minimum global item.
+func (r *HIDReport) LogicalMinimum(min int32) *HIDReport {
+	return r.signedItem(HID_ITEM_GLOBAL, HID_LOGICAL_MINIMUM, min)
+}
+
+// LogicalMaximum appends a Logical Maximum global item.
+func (r *HIDReport) LogicalMaximum(max int32) *HIDReport {
+	return r.signedItem(HID_ITEM_GLOBAL, HID_LOGICAL_MAXIMUM, max)
+}
+
+// PhysicalMinimum appends a Physical Minimum global item.
+func (r *HIDReport) PhysicalMinimum(min int32) *HIDReport {
+	return r.signedItem(HID_ITEM_GLOBAL, HID_PHYSICAL_MINIMUM, min)
+}
+
+// PhysicalMaximum appends a Physical Maximum global item.
+func (r *HIDReport) PhysicalMaximum(max int32) *HIDReport {
+	return r.signedItem(HID_ITEM_GLOBAL, HID_PHYSICAL_MAXIMUM, max)
+}
+
+// UnitExponent appends a Unit Exponent global item.
+func (r *HIDReport) UnitExponent(exp int32) *HIDReport {
+	return r.signedItem(HID_ITEM_GLOBAL, HID_UNIT_EXPONENT, exp)
+}
+
+// Unit appends a Unit global item.
+func (r *HIDReport) Unit(unit uint32) *HIDReport {
+	return r.item(HID_ITEM_GLOBAL, HID_UNIT, unit)
+}
+
+// ReportSize appends a Report Size global item, expressed in bits.
+func (r *HIDReport) ReportSize(size uint32) *HIDReport {
+	return r.item(HID_ITEM_GLOBAL, HID_REPORT_SIZE, size)
+}
+
+// ReportID appends a Report ID global item.
+func (r *HIDReport) ReportID(id uint8) *HIDReport {
+	return r.item(HID_ITEM_GLOBAL, HID_REPORT_ID, uint32(id))
+}
+
+// ReportCount appends a Report Count global item.
+func (r *HIDReport) ReportCount(count uint32) *HIDReport {
+	return r.item(HID_ITEM_GLOBAL, HID_REPORT_COUNT, count)
+}
+
+// Usage appends a Usage local item.
+func (r *HIDReport) Usage(usage uint32) *HIDReport {
+	return r.item(HID_ITEM_LOCAL, HID_USAGE, usage)
+}
+
+// UsageMinimum appends a Usage Minimum local item.
+func (r *HIDReport) UsageMinimum(min uint32) *HIDReport {
+	return r.item(HID_ITEM_LOCAL, HID_USAGE_MINIMUM, min)
+}
+
+// UsageMaximum appends a Usage Maximum local item.
+func (r *HIDReport) UsageMaximum(max uint32) *HIDReport {
+	return r.item(HID_ITEM_LOCAL, HID_USAGE_MAXIMUM, max)
+}
+
+// Input appends an Input main item with the argument data flags.
+func (r *HIDReport) Input(flags uint32) *HIDReport {
+	return r.item(HID_ITEM_MAIN, HID_INPUT, flags)
+}
+
+// Output appends an Output main item with the argument data flags.
+func (r *HIDReport) Output(flags uint32) *HIDReport {
+	return r.item(HID_ITEM_MAIN, HID_OUTPUT, flags)
+}
+
+// Feature appends a Feature main item with the argument data flags.
+func (r *HIDReport) Feature(flags uint32) *HIDReport {
+	return r.item(HID_ITEM_MAIN, HID_FEATURE, flags)
+}
+
+// Collection appends a Collection main item of the argument type.
+func (r *HIDReport) Collection(typ uint8) *HIDReport {
+	return r.item(HID_ITEM_MAIN, HID_COLLECTION, uint32(typ))
+}
+
+// EndCollection appends an End Collection main item.
+func (r *HIDReport) EndCollection() *HIDReport {
+	r.buf = append(r.buf, byte(HID_END_COLLECTION<<4|HID_ITEM_MAIN<<2))
+	return r
+}
+
+// Len returns the report descriptor length.
+func (r *HIDReport) Len() int {
+	return len(r.buf)
+}
+
+// Bytes converts the report descriptor structure to byte array format.
+func (r *HIDReport) Bytes() HIDReportDescriptor {
+	buf := make([]byte, len(r.buf))
+	copy(buf, r.buf)
+
+	return buf
+}
+
+// KeyboardReportDescriptor returns the report descriptor for a boot protocol
+// compatible keyboard, reporting 8 modifier keys, 6 simultaneous key codes
+// and 3 LEDs (p59, B.1 Protocol 1 (Keyboard), HID1.11).
+func KeyboardReportDescriptor() HIDReportDescriptor {
+	return NewHIDReport().
+		UsagePage(HID_USAGE_PAGE_GENERIC_DESKTOP).
+		Usage(HID_USAGE_KEYBOARD).
+		Collection(HID_COLLECTION_APPLICATION).
+		// modifier keys
+		UsagePage(HID_USAGE_PAGE_KEYBOARD).
+		UsageMinimum(0xe0).
+		UsageMaximum(0xe7).
+		LogicalMinimum(0).
+		LogicalMaximum(1).
+		ReportSize(1).
+		ReportCount(8).
+		Input(HID_DATA | HID_VARIABLE | HID_ABSOLUTE).
+		// reserved
+		ReportCount(1).
+		ReportSize(8).
+		Input(HID_CONSTANT | HID_VARIABLE | HID_ABSOLUTE).
+		// LEDs
+		ReportCount(3).
+		ReportSize(1).
+		UsagePage(HID_USAGE_PAGE_LED).
+		UsageMinimum(1).
+		UsageMaximum(3).
+		Output(HID_DATA | HID_VARIABLE | HID_ABSOLUTE).
+		// LEDs padding
+		ReportCount(1).
+		ReportSize(5).
+		Output(HID_CONSTANT | HID_VARIABLE | HID_ABSOLUTE).
+		// key codes
+		ReportCount(6).
+		ReportSize(8).
+		LogicalMinimum(0).
+		LogicalMaximum(0xa4).
+		UsagePage(HID_USAGE_PAGE_KEYBOARD).
+		UsageMinimum(0).
+		UsageMaximum(0xa4).
+		Input(HID_DATA | HID_ARRAY | HID_ABSOLUTE).
+		EndCollection().
+		Bytes()
+}
+
+// MouseReportDescriptor returns the report descriptor for a boot protocol
+// compatible mouse, reporting 3 buttons and relative X, Y and wheel axes
+// (p61, B.2 Protocol 2 (Mouse), HID1.11).
+//
+// Each input report is 4 bytes long: the button states (bits 0-2) followed by
+// signed X, Y and wheel displacements. As an example the following report,
+// sent on the interface interrupt IN endpoint, moves the cursor 10 units
+// right and 5 units up:
+//
+//	[]byte{0x00, 10, 0xfb, 0x00}
+func MouseReportDescriptor() HIDReportDescriptor {
+	return NewHIDReport().
+		UsagePage(HID_USAGE_PAGE_GENERIC_DESKTOP).
+		Usage(HID_USAGE_MOUSE).
+		Collection(HID_COLLECTION_APPLICATION).
+		Usage(HID_USAGE_POINTER).
+		Collection(HID_COLLECTION_PHYSICAL).
+		// buttons
+		UsagePage(HID_USAGE_PAGE_BUTTON).
+		UsageMinimum(1).
+		UsageMaximum(3).
+		LogicalMinimum(0).
+		LogicalMaximum(1).
+		ReportCount(3).
+		ReportSize(1).
+		Input(HID_DATA | HID_VARIABLE | HID_ABSOLUTE).
+		// buttons padding
+		ReportCount(1).
+		ReportSize(5).
+		Input(HID_CONSTANT | HID_VARIABLE | HID_ABSOLUTE).
+		// axes
+		UsagePage(HID_USAGE_PAGE_GENERIC_DESKTOP).
+		Usage(HID_USAGE_X).
+		Usage(HID_USAGE_Y).
+		Usage(HID_USAGE_WHEEL).
+		LogicalMinimum(-127).
+		LogicalMaximum(127).
+		ReportSize(8).
+		ReportCount(3).
+		Input(HID_DATA | HID_VARIABLE | HID_RELATIVE).
+		EndCollection().
+		EndCollection().
+		Bytes()
+}
+
+// ConsumerReportDescriptor returns the report descriptor for a consumer
+// control device reporting media keys.
+//
+// Each input report is 2 bytes long and carries a single 16-bit little-endian
+// Consumer page usage (e.g. HID_USAGE_VOLUME_UP) for the key being pressed, a
+// zero value reports key release.
+func ConsumerReportDescriptor() HIDReportDescriptor {
+	return NewHIDReport().
+		UsagePage(HID_USAGE_PAGE_CONSUMER).
+		Usage(HID_USAGE_CONSUMER_CONTROL).
+		Collection(HID_COLLECTION_APPLICATION).
+		LogicalMinimum(0).
+		LogicalMaximum(0x3ff).
+		UsageMinimum(0).
+		UsageMaximum(0x3ff).
+		ReportCount(1).
+		ReportSize(16).
+		Input(HID_DATA | HID_ARRAY | HID_ABSOLUTE).
+		EndCollection().
+		Bytes()
+}
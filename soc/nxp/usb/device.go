@@ -11,7 +11,6 @@ package usb
 
 import (
 	"log"
-	"sync"
 	"time"
 
 	"github.com/usbarmory/tamago/internal/reg"
@@ -38,11 +37,8 @@ func (hw *USB) DeviceMode() {
 	reg.Write(hw.mode, m)
 	reg.Wait(hw.mode, USBMODE_CM, 0b11, USBMODE_CM_DEVICE)
 
-	// initialize endpoint queue head list
-	hw.initQH()
-	// set control endpoint
-	hw.set(0, IN, 64, true, 0)
-	hw.set(0, OUT, 64, true, 0)
+	// initialize endpoint queue head list and control endpoint
+	hw.initControlEndpoint()
 
 	// set OTG termination
 	reg.Set(hw.otg, OTGSC_OT)
@@ -58,7 +54,12 @@ func (hw *USB) DeviceMode() {
 // never return. Note that isochronous endpoints are not supported.
 func (hw *USB) Start(dev *Device) {
 	var conf uint8
-	var wg sync.WaitGroup
+
+	hw.Lock()
+	hw.started = true
+	hw.Unlock()
+
+	dev.bus = hw
 
 	for {
 		// check for bus reset
@@ -67,8 +68,20 @@ func (hw *USB) Start(dev *Device) {
 			conf = 0
 			dev.ConfigurationValue = 0
 
+			// Cancel configuration endpoints before their queue
+			// heads are re-initialized, their handlers are not
+			// waited for as that would delay the reset procedure.
+			// They are restarted once the host selects a
+			// configuration again.
+			hw.cancelEndpoints()
+
 			// perform controller reset procedure
 			hw.Reset()
+
+			// enter DFU mode after a DFU_DETACH request
+			if dev.dfu.state == APP_DETACH {
+				dev.dfu.state = DFU_IDLE
+			}
 			log.Println("RESET DONE")
 		}
 
@@ -86,6 +99,13 @@ func (hw *USB) Start(dev *Device) {
 		}
 		log.Println("RETURNED from hw.handleSetup")
 
+		if hw.test {
+			// The device must be power cycled to exit test mode
+			// (p259, 9.4.9 Set Feature, USB2.0), no further
+			// requests are processed.
+			select {}
+		}
+
 		// check if configuration reload is required
 		if dev.ConfigurationValue == conf {
 			log.Println("Config reload required")
@@ -97,14 +117,17 @@ func (hw *USB) Start(dev *Device) {
 		}
 
 		// stop configuration endpoints
-		if hw.done != nil {
-			log.Println("CLOSING hw.done")
-			close(hw.done)
-			wg.Wait()
+		if err := hw.stopEndpoints(); err != nil {
+			log.Printf("usb: %v", err)
 		}
+
 		// start configuration endpoints
 		log.Println("STARTING ENDPOINTS")
-		hw.startEndpoints(&wg, dev, conf)
+		if err := hw.startEndpoints(dev, conf); err != nil {
+			log.Printf("usb: %v", err)
+			// retry on next setup packet
+			conf = 0
+		}
 		log.Println("RETURNED from startEndpoints")
 	}
 }
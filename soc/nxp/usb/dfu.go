@@ -0,0 +1,254 @@
+// USB device mode support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"fmt"
+)
+
+// DFUWriteFunction represents the function to process firmware blocks
+// received through DFU_DNLOAD requests.
+type DFUWriteFunction func(block uint16, data []byte) error
+
+// DFUReadFunction represents the function to return firmware blocks
+// requested through DFU_UPLOAD requests, a result shorter than the argument
+// length signals the end of the upload.
+type DFUReadFunction func(block uint16, length int) ([]byte, error)
+
+// DFUManifestFunction represents the function to process the downloaded
+// firmware once the host signals the end of the download, its execution
+// reflects the dfuMANIFEST state.
+type DFUManifestFunction func() error
+
+// DFUDetachFunction represents the function invoked on DFU_DETACH requests,
+// after their status stage, to detach and re-attach the device in DFU mode
+// (e.g. by resetting to a DFU capable firmware). Functions which perform the
+// detach-attach sequence should set the DFU functional descriptor
+// bitWillDetach attribute (see DFU_WILL_DETACH).
+type DFUDetachFunction func()
+
+// DFU_POLL_TIMEOUT is the minimum time, in milliseconds, that the host waits
+// before a DFU_GETSTATUS request while the function is busy.
+const DFU_POLL_TIMEOUT = 10
+
+// dfuState represents the DFU function state machine
+// (p24, 6.1.2 DFU_GETSTATUS Request, DFU1.1).
+type dfuState struct {
+	status uint8
+	state  uint8
+
+	// pending DFU_DNLOAD block
+	block uint16
+	data  []byte
+
+	// result of the pending write or manifestation
+	result chan error
+}
+
+// isDFU returns whether a setup request is addressed to a DFU interface of
+// the active configuration.
+func (d *Device) isDFU(setup *SetupData) bool {
+	if d.DFUWrite == nil && d.DFURead == nil {
+		return false
+	}
+
+	if setup.RequestType&0b01100000 != 0b00100000 {
+		return false
+	}
+
+	for _, conf := range d.Configurations {
+		if conf.ConfigurationValue != d.ConfigurationValue {
+			continue
+		}
+
+		for _, iface := range conf.Interfaces {
+			if uint16(iface.InterfaceNumber) == setup.Index &&
+				iface.InterfaceClass == DFU_INTERFACE_CLASS &&
+				iface.InterfaceSubClass == DFU_INTERFACE_SUBCLASS {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (dfu *dfuState) fail(status uint8) {
+	dfu.status = status
+	dfu.state = DFU_ERROR
+	dfu.data = nil
+}
+
+// run executes the argument function asynchronously, on its first invocation,
+// and returns whether it has completed along with its result.
+func (dfu *dfuState) run(fn func() error) (done bool, err error) {
+	if dfu.result == nil {
+		dfu.result = make(chan error, 1)
+
+		go func(result chan error) {
+			result <- fn()
+		}(dfu.result)
+	}
+
+	select {
+	case err = <-dfu.result:
+		dfu.result = nil
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+// busy reports the argument state, to the host, as requiring polling.
+func (dfu *dfuState) busy(state uint8, status *DFUStatus) {
+	dfu.state = state
+	status.PollTimeout[0] = DFU_POLL_TIMEOUT
+}
+
+func (hw *USB) handleDFUSetup(dev *Device, setup *SetupData) (err error) {
+	dfu := &dev.dfu
+
+	if dfu.state == APP_IDLE {
+		dfu.state = DFU_IDLE
+	}
+
+	switch setup.Request {
+	case DFU_DETACH:
+		if err = hw.ack(0); err != nil {
+			return
+		}
+
+		// DFU mode is entered on the next bus reset (see Start())
+		dfu.state = APP_DETACH
+
+		if dev.DFUDetach != nil {
+			go dev.DFUDetach()
+		}
+
+		return
+	case DFU_DNLOAD:
+		switch {
+		case dev.DFUWrite == nil:
+			dfu.fail(DFU_STATUS_ERR_STALLEDPKT)
+		case setup.Length > 0 && (dfu.state == DFU_IDLE || dfu.state == DFU_DNLOAD_IDLE):
+			var buf []byte
+
+			if buf, err = hw.rx(0, false, make([]byte, setup.Length)); err != nil {
+				dfu.fail(DFU_STATUS_ERR_UNKNOWN)
+				break
+			}
+
+			dfu.block = setup.Value<<8 | setup.Value>>8
+			dfu.data = buf
+			dfu.state = DFU_DNLOAD_SYNC
+
+			return hw.ack(0)
+		case setup.Length == 0 && dfu.state == DFU_DNLOAD_IDLE:
+			dfu.state = DFU_MANIFEST_SYNC
+			return hw.ack(0)
+		default:
+			dfu.fail(DFU_STATUS_ERR_STALLEDPKT)
+		}
+	case DFU_UPLOAD:
+		if dev.DFURead == nil || (dfu.state != DFU_IDLE && dfu.state != DFU_UPLOAD_IDLE) {
+			dfu.fail(DFU_STATUS_ERR_STALLEDPKT)
+			break
+		}
+
+		var buf []byte
+
+		if buf, err = dev.DFURead(setup.Value<<8|setup.Value>>8, int(setup.Length)); err != nil {
+			dfu.fail(DFU_STATUS_ERR_FILE)
+			break
+		}
+
+		if len(buf) < int(setup.Length) {
+			dfu.state = DFU_IDLE
+		} else {
+			dfu.state = DFU_UPLOAD_IDLE
+		}
+
+		return hw.tx(0, false, trim(buf, setup.Length))
+	case DFU_GETSTATUS:
+		status := &DFUStatus{}
+
+		// Writes and manifestation are performed asynchronously, the
+		// function reports dfuDNBUSY (or dfuMANIFEST) until their
+		// completion, the host polls again after bwPollTimeout.
+		switch dfu.state {
+		case DFU_DNLOAD_SYNC, DFU_DNBUSY:
+			block := dfu.block
+			data := dfu.data
+
+			done, e := dfu.run(func() error {
+				return dev.DFUWrite(block, data)
+			})
+
+			switch {
+			case !done:
+				dfu.busy(DFU_DNBUSY, status)
+			case e != nil:
+				dfu.fail(DFU_STATUS_ERR_WRITE)
+			default:
+				dfu.state = DFU_DNLOAD_IDLE
+				dfu.data = nil
+			}
+		case DFU_MANIFEST_SYNC, DFU_MANIFEST:
+			if dev.DFUManifest == nil {
+				dfu.state = DFU_IDLE
+				break
+			}
+
+			done, e := dfu.run(dev.DFUManifest)
+
+			switch {
+			case !done:
+				dfu.busy(DFU_MANIFEST, status)
+			case e != nil:
+				dfu.fail(DFU_STATUS_ERR_FIRMWARE)
+			default:
+				// manifestation tolerant function
+				dfu.state = DFU_IDLE
+			}
+		}
+
+		status.Status = dfu.status
+		status.State = dfu.state
+
+		return hw.tx(0, false, trim(status.Bytes(), setup.Length))
+	case DFU_CLRSTATUS:
+		if dfu.state == DFU_ERROR {
+			dfu.status = DFU_STATUS_OK
+			dfu.state = DFU_IDLE
+		}
+
+		return hw.ack(0)
+	case DFU_GETSTATE:
+		return hw.tx(0, false, trim([]byte{dfu.state}, setup.Length))
+	case DFU_ABORT:
+		if dfu.result != nil {
+			// writes and manifestation cannot be aborted, the
+			// host is expected to poll for their completion
+			break
+		}
+
+		dfu.state = DFU_IDLE
+		dfu.data = nil
+
+		return hw.ack(0)
+	default:
+		dfu.fail(DFU_STATUS_ERR_STALLEDPKT)
+		err = fmt.Errorf("unsupported DFU request code: %#x", setup.Request)
+	}
+
+	hw.stall(0, IN)
+
+	return
+}
@@ -0,0 +1,281 @@
+// USB CDC Ethernet Control Model support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// CDC ECM constants
+const (
+	// p39, Table 15: Class Codes, USB Class Definitions for Communication
+	// Devices 1.1
+	COMMUNICATION_INTERFACE_CLASS = 0x02
+	DATA_INTERFACE_CLASS          = 0x0a
+	ETHERNET_CONTROL_MODEL        = 0x06
+
+	// p6, Table 11: Class-Specific Notification Codes, USB Communications
+	// Class Subclass Specification for Ethernet Control Model Devices 1.2
+	NETWORK_CONNECTION      = 0x00
+	CONNECTION_SPEED_CHANGE = 0x2a
+
+	// nominal 480 Mbps high speed bit rate
+	ECM_BIT_RATE = 480000000
+
+	// frame queue depth
+	ECM_QUEUE_SIZE = 64
+)
+
+// ECM implements a CDC Ethernet Control Model (ECM) function, exchanging raw
+// Ethernet frames with the host.
+type ECM struct {
+	// MAC address assigned to the host side network interface
+	HostMAC net.HardwareAddr
+
+	// control interface number
+	iface uint8
+	// device instance, for endpoint cancellation
+	dev *Device
+
+	// latest link state, not yet notified to the host
+	notify chan bool
+	// serializes link state updates
+	link sync.Mutex
+	// pending connection speed notification
+	speed []byte
+	// frames received from the host
+	rx chan []byte
+	// frames for transmission to the host
+	tx chan []byte
+}
+
+// Init adds the ECM function, composed of its control and data interfaces,
+// to a device configuration. The control interface uses the argument
+// endpoint number for its interrupt IN notification endpoint, the data
+// interface uses the argument endpoint number for its bulk IN and OUT
+// endpoints.
+//
+// The link is initially reported as connected, see SetLink().
+func (ecm *ECM) Init(dev *Device, conf *ConfigurationDescriptor, ctrlEP uint8, dataEP uint8) (err error) {
+	if len(ecm.HostMAC) != 6 {
+		return errors.New("invalid host MAC address")
+	}
+
+	ecm.dev = dev
+	ecm.notify = make(chan bool, 1)
+	ecm.rx = make(chan []byte, ECM_QUEUE_SIZE)
+	ecm.tx = make(chan []byte, ECM_QUEUE_SIZE)
+
+	mac := strings.ToUpper(strings.ReplaceAll(ecm.HostMAC.String(), ":", ""))
+	macIndex, err := dev.AddString(mac)
+
+	if err != nil {
+		return
+	}
+
+	ecm.iface = conf.NumInterfaces
+
+	// control interface
+	ctrl := &InterfaceDescriptor{}
+	ctrl.SetDefaults()
+	ctrl.InterfaceClass = COMMUNICATION_INTERFACE_CLASS
+	ctrl.InterfaceSubClass = ETHERNET_CONTROL_MODEL
+
+	header := &CDCHeaderDescriptor{}
+	header.SetDefaults()
+
+	union := &CDCUnionDescriptor{}
+	union.SetDefaults()
+	union.MasterInterface = ecm.iface
+	union.SlaveInterface0 = ecm.iface + 1
+
+	ethernet := &CDCEthernetDescriptor{}
+	ethernet.SetDefaults()
+	ethernet.MacAddress = macIndex
+
+	ctrl.AddClassDescriptor(header.Bytes())
+	ctrl.AddClassDescriptor(union.Bytes())
+	ctrl.AddClassDescriptor(ethernet.Bytes())
+
+	notifyEP := &EndpointDescriptor{}
+	notifyEP.SetDefaults()
+	notifyEP.EndpointAddress = 0x80 | ctrlEP
+	notifyEP.Attributes = INTERRUPT
+	notifyEP.MaxPacketSize = 16
+	notifyEP.Interval = 9
+	notifyEP.Function = ecm.notification
+
+	ctrl.AddEndpoint(notifyEP)
+
+	// data interface, default alternate setting without endpoints
+	data0 := &InterfaceDescriptor{}
+	data0.SetDefaults()
+	data0.NumEndpoints = 0
+	data0.InterfaceClass = DATA_INTERFACE_CLASS
+
+	// data interface, operational alternate setting
+	data1 := &InterfaceDescriptor{}
+	data1.SetDefaults()
+	data1.AlternateSetting = 1
+	data1.NumEndpoints = 2
+	data1.InterfaceClass = DATA_INTERFACE_CLASS
+
+	inEP := &EndpointDescriptor{}
+	inEP.SetDefaults()
+	inEP.EndpointAddress = 0x80 | dataEP
+	inEP.Attributes = BULK
+	inEP.Function = ecm.transmit
+
+	outEP := &EndpointDescriptor{}
+	outEP.SetDefaults()
+	outEP.EndpointAddress = dataEP
+	outEP.Attributes = BULK
+	outEP.Function = ecm.receive
+
+	data1.AddEndpoint(inEP)
+	data1.AddEndpoint(outEP)
+
+	if err = dev.AddFunction(conf, nil, ctrl, data0, data1); err != nil {
+		return
+	}
+
+	ecm.SetLink(true)
+
+	return
+}
+
+// SetLink queues a link status notification to the host, followed by a
+// connection speed notification when the link is up
+// (p6, 6.3 Notifications, USB Communications Class Subclass Specification for
+// Ethernet Control Model Devices 1.2).
+//
+// The function never blocks, only the latest link state is notified when the
+// host has not yet polled a previous one.
+func (ecm *ECM) SetLink(up bool) {
+	ecm.link.Lock()
+	defer ecm.link.Unlock()
+
+	// discard any link state not yet notified
+	select {
+	case <-ecm.notify:
+	default:
+	}
+
+	ecm.notify <- up
+}
+
+func (ecm *ECM) connectionNotification(up bool) (buf []byte) {
+	var val uint16
+
+	if up {
+		val = 1
+	}
+
+	buf = make([]byte, 8)
+
+	buf[0] = 0xa1
+	buf[1] = NETWORK_CONNECTION
+	binary.LittleEndian.PutUint16(buf[2:], val)
+	binary.LittleEndian.PutUint16(buf[4:], uint16(ecm.iface))
+
+	return
+}
+
+func (ecm *ECM) speedNotification() (buf []byte) {
+	buf = make([]byte, 16)
+
+	buf[0] = 0xa1
+	buf[1] = CONNECTION_SPEED_CHANGE
+	binary.LittleEndian.PutUint16(buf[4:], uint16(ecm.iface))
+	binary.LittleEndian.PutUint16(buf[6:], 8)
+	// downstream bit rate
+	binary.LittleEndian.PutUint32(buf[8:], ECM_BIT_RATE)
+	// upstream bit rate
+	binary.LittleEndian.PutUint32(buf[12:], ECM_BIT_RATE)
+
+	return
+}
+
+// Read receives a single Ethernet frame from the host, blocking until one is
+// available.
+func (ecm *ECM) Read(buf []byte) (n int, err error) {
+	frame := <-ecm.rx
+
+	if len(frame) > len(buf) {
+		return 0, fmt.Errorf("frame size (%d) exceeds buffer size (%d)", len(frame), len(buf))
+	}
+
+	return copy(buf, frame), nil
+}
+
+// Write transmits a single Ethernet frame to the host.
+func (ecm *ECM) Write(buf []byte) (n int, err error) {
+	if len(buf) > MSS {
+		return 0, fmt.Errorf("frame size (%d) exceeds maximum segment size", len(buf))
+	}
+
+	frame := make([]byte, len(buf))
+	copy(frame, buf)
+
+	ecm.tx <- frame
+
+	return len(buf), nil
+}
+
+// notification is the ECM interrupt IN endpoint function.
+func (ecm *ECM) notification(_ []byte, lastErr error) (in []byte, err error) {
+	if in = ecm.speed; in != nil {
+		ecm.speed = nil
+		return
+	}
+
+	var up bool
+
+	select {
+	case up = <-ecm.notify:
+	case <-ecm.dev.done():
+		return
+	}
+
+	if up {
+		ecm.speed = ecm.speedNotification()
+	}
+
+	return ecm.connectionNotification(up), nil
+}
+
+// transmit is the ECM bulk IN endpoint function.
+func (ecm *ECM) transmit(_ []byte, lastErr error) (in []byte, err error) {
+	select {
+	case in = <-ecm.tx:
+	case <-ecm.dev.done():
+	}
+
+	return
+}
+
+// receive is the ECM bulk OUT endpoint function.
+func (ecm *ECM) receive(out []byte, lastErr error) (_ []byte, err error) {
+	frame := make([]byte, len(out))
+	copy(frame, out)
+
+	select {
+	case ecm.rx <- frame:
+	default:
+		err = errors.New("ECM receive queue full, frame dropped")
+	}
+
+	return
+}
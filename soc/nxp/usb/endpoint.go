@@ -13,10 +13,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"log"
-	"strconv"
 	"time"
 
+	"github.com/usbarmory/tamago/arm"
 	"github.com/usbarmory/tamago/bits"
 	"github.com/usbarmory/tamago/dma"
 	"github.com/usbarmory/tamago/internal/reg"
@@ -102,18 +101,32 @@ type dQH struct {
 // p3783, 56.4.5 Device Data Structures, IMX6ULLRM.
 type endpointList [MAX_ENDPOINTS * 2]dQH
 
-// initQH initializes the endpoint queue head list
+// initQH initializes the endpoint queue head list, a previously allocated
+// list is cleared and reused.
 func (hw *USB) initQH() {
 	var epList endpointList
 	buf := new(bytes.Buffer)
 
 	binary.Write(buf, binary.LittleEndian, &epList)
-	hw.epListAddr = uint32(dma.Alloc(buf.Bytes(), DQH_LIST_ALIGN))
+
+	if hw.epListAddr == 0 {
+		hw.epListAddr = uint32(dma.Alloc(buf.Bytes(), DQH_LIST_ALIGN))
+	} else {
+		dma.Write(uint(hw.epListAddr), 0, buf.Bytes())
+	}
 
 	// set endpoint queue head
 	reg.Write(hw.eplist, hw.epListAddr)
 }
 
+// initControlEndpoint initializes the endpoint queue head list and the
+// control endpoint (EP0) queue heads.
+func (hw *USB) initControlEndpoint() {
+	hw.initQH()
+	hw.set(0, IN, 64, true, 0)
+	hw.set(0, OUT, 64, true, 0)
+}
+
 // set configures an endpoint queue head as described in
 // p3784, 56.4.5.1 Endpoint Queue Head, IMX6ULLRM.
 func (hw *USB) set(n int, dir int, max int, zlt bool, mult int) {
@@ -150,6 +163,7 @@ func (hw *USB) set(n int, dir int, max int, zlt bool, mult int) {
 	dma.Write(uint(hw.epListAddr), offset, buf.Bytes())
 
 	hw.dQH[n][dir] = hw.epListAddr + uint32(offset)
+	hw.maxPacket[n][dir] = max
 }
 
 // enable enables an endpoint.
@@ -160,31 +174,49 @@ func (hw *USB) enable(n int, dir int, transferType int) {
 	}
 
 	ctrl := hw.epctrl + uint32(4*n)
-	c := reg.Read(ctrl)
 
-	if dir == IN {
-		bits.Set(&c, ENDPTCTRL_TXE)
-		bits.Set(&c, ENDPTCTRL_TXR)
-		bits.SetN(&c, ENDPTCTRL_TXT, 0b11, uint32(transferType))
-		bits.Clear(&c, ENDPTCTRL_TXS)
-
-		if reg.Get(ctrl, ENDPTCTRL_RXE, 1) == 0 {
-			// see note at p3879 of IMX6ULLRM
-			bits.SetN(&c, ENDPTCTRL_RXT, 0b11, BULK)
-		}
-	} else {
-		bits.Set(&c, ENDPTCTRL_RXE)
-		bits.Set(&c, ENDPTCTRL_RXR)
-		bits.SetN(&c, ENDPTCTRL_RXT, 0b11, uint32(transferType))
-		bits.Clear(&c, ENDPTCTRL_RXS)
-
-		if reg.Get(ctrl, ENDPTCTRL_TXE, 1) == 0 {
-			// see note at p3879 of IMX6ULLRM
-			bits.SetN(&c, ENDPTCTRL_TXT, 0b11, BULK)
+	// IN and OUT endpoints share the same control register
+	reg.Modify(ctrl, func(c uint32) uint32 {
+		if dir == IN {
+			bits.Set(&c, ENDPTCTRL_TXE)
+			bits.Set(&c, ENDPTCTRL_TXR)
+			bits.SetN(&c, ENDPTCTRL_TXT, 0b11, uint32(transferType))
+			bits.Clear(&c, ENDPTCTRL_TXS)
+
+			if bits.Get(&c, ENDPTCTRL_RXE, 1) == 0 {
+				// see note at p3879 of IMX6ULLRM
+				bits.SetN(&c, ENDPTCTRL_RXT, 0b11, BULK)
+			}
+		} else {
+			bits.Set(&c, ENDPTCTRL_RXE)
+			bits.Set(&c, ENDPTCTRL_RXR)
+			bits.SetN(&c, ENDPTCTRL_RXT, 0b11, uint32(transferType))
+			bits.Clear(&c, ENDPTCTRL_RXS)
+
+			if bits.Get(&c, ENDPTCTRL_TXE, 1) == 0 {
+				// see note at p3879 of IMX6ULLRM
+				bits.SetN(&c, ENDPTCTRL_TXT, 0b11, BULK)
+			}
 		}
+
+		return c
+	})
+}
+
+// disable disables an endpoint, in both directions.
+func (hw *USB) disable(n int) {
+	if n == 0 {
+		// EP0 is always enabled (p3790, IMX6ULLRM)
+		return
 	}
 
-	reg.Write(ctrl, c)
+	ctrl := hw.epctrl + uint32(4*n)
+
+	reg.Modify(ctrl, func(c uint32) uint32 {
+		bits.Clear(&c, ENDPTCTRL_TXE)
+		bits.Clear(&c, ENDPTCTRL_RXE)
+		return c
+	})
 }
 
 // clear resets the endpoint status (active and halt bits)
@@ -221,8 +253,23 @@ func (hw *USB) nextDTD(n int, dir int, dtd uint32) {
 // buildDTD configures an endpoint transfer descriptor as described in
 // p3787, 56.4.5.2 Endpoint Transfer Descriptor (dTD), IMX6ULLRM.
 func buildDTD(n int, dir int, ioc bool, addr uint32, size int) (dtd *dTD) {
-	// p3809, 56.4.6.6.2 Building a Transfer Descriptor, IMX6ULLRM
 	dtd = &dTD{}
+	dtd.init(ioc, addr, size)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, dtd)
+
+	// skip internal DMA buffer pointers
+	dtd._dtd = uint32(dma.Alloc(buf.Bytes()[0:DTD_SIZE], DTD_ALIGN))
+
+	return
+}
+
+// init sets the transfer descriptor fields for a transfer of the argument
+// size at the argument buffer address.
+func (dtd *dTD) init(ioc bool, addr uint32, size int) {
+	// p3809, 56.4.6.6.2 Building a Transfer Descriptor, IMX6ULLRM
+	dtd.Token = 0
 
 	// interrupt on completion (ioc)
 	bits.SetTo(&dtd.Token, TOKEN_IOC, ioc)
@@ -242,35 +289,24 @@ func buildDTD(n int, dir int, ioc bool, addr uint32, size int) (dtd *dTD) {
 	for n := 0; n < DTD_PAGES; n++ {
 		dtd.Buffer[n] = dtd._buf + DTD_PAGE_SIZE*uint32(n)
 	}
-
-	buf := new(bytes.Buffer)
-	binary.Write(buf, binary.LittleEndian, dtd)
-
-	// skip internal DMA buffer pointers
-	dtd._dtd = uint32(dma.Alloc(buf.Bytes()[0:DTD_SIZE], DTD_ALIGN))
-
-	return
 }
 
 // checkDTD verifies transfer descriptor completion as describe in
 // p3800, 56.4.6.4.1 Interrupt/Bulk Endpoint Operational Model, IMX6ULLRM
 // p3811, 56.4.6.6.4 Transfer Completion, IMX6ULLRM.
-func checkDTD(n int, dir int, dtds []*dTD, done chan bool) (size int, err error) {
+func checkDTD(n int, dir int, dtds []*dTD, done chan bool, timeout time.Duration) (size int, err error) {
 	for i, dtd := range dtds {
 		// treat dtd.token as a register within the dtd DMA buffer
 		token := dtd._dtd + DTD_TOKEN
 
-		// Wait indefinitely for active bit to be cleared.
+		// Wait for active bit to be cleared.
 		if n == 0 {
-			log.Println("Waiting for inactive...")
-			reg.WaitFor(time.Second, token, TOKEN_ACTIVE, 1, 0)
-			log.Println("timed out")
-			log.Println(strconv.FormatUint(uint64(reg.Read(token)), 2))
-			reg.Wait(token, TOKEN_ACTIVE, 1, 0)
-		} else {
-			reg.WaitSignal(done, token, TOKEN_ACTIVE, 1, 0)
+			if active, ok := reg.WaitForValue(timeout, token, TOKEN_ACTIVE, 1, 0); !ok {
+				return 0, fmt.Errorf("dTD[%d] timeout, active:%d token:%#x", i, active, reg.Read(token))
+			}
+		} else if !reg.WaitSignalFor(timeout, done, token, TOKEN_ACTIVE, 1, 0) {
+			return 0, fmt.Errorf("dTD[%d] cancelled or timed out, token:%#x", i, reg.Read(token))
 		}
-		log.Println("Active bit = 0")
 
 		dtdToken := reg.Read(token)
 
@@ -288,6 +324,13 @@ func checkDTD(n int, dir int, dtds []*dTD, done chan bool) (size int, err error)
 		}
 
 		size += n
+
+		// A short packet terminates the transfer (p227, 5.8.3 Bulk
+		// Transfer Packet Size Constraints, USB2.0), any following
+		// dTD is not retired by the controller.
+		if rest > 0 {
+			break
+		}
 	}
 
 	return
@@ -295,8 +338,13 @@ func checkDTD(n int, dir int, dtds []*dTD, done chan bool) (size int, err error)
 
 // transfer initates a transfer using transfer descriptors (dTDs) as described in
 // p3810, 56.4.6.6.3 Executing A Transfer Descriptor, IMX6ULLRM.
-func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte) (out []byte, err error) {
-	log.Printf("Entered transfer for EP: %d", n)
+//
+// Transfers served by a preallocated endpoint pool (see PreallocEndpoint())
+// do not allocate memory.
+//
+// Transfers on EP1-N are cancelled when the argument channel is closed,
+// control endpoint (EP0) transfers are instead bound by ControlTimeout.
+func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte, done chan bool) (out []byte, err error) {
 	var dtds []*dTD
 	var prev *dTD
 	var i int
@@ -305,19 +353,66 @@ func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte) (out []byte, err e
 	// hw.pos   IN:ENDPTCOMPLETE_ETCE+n OUT:ENDPTCOMPLETE_ERCE+n
 	pos := (dir * 16) + n
 
+	if n != 0 {
+		select {
+		case <-done:
+			// never prime an endpoint after cancellation
+			return nil, fmt.Errorf("transfer cancelled")
+		default:
+		}
+	}
+
 	dtdLength := DTD_PAGES * DTD_PAGE_SIZE
+	pool := hw.pool[n][dir]
+
+	var pages uint
+	var transferSize int
 
-	if dir == OUT && buf == nil {
-		buf = make([]byte, dtdLength)
+	switch {
+	case pool != nil && dir == OUT && buf == nil:
+		transferSize = dtdLength
+	case pool != nil && len(buf) <= len(pool.buf):
+		transferSize = len(buf)
+
+		if dir == IN {
+			copy(pool.buf, buf)
+		}
+	default:
+		pool = nil
 	}
 
-	transferSize := len(buf)
+	if pool != nil {
+		pages = pool.addr
+	} else {
+		if dir == OUT && buf == nil {
+			buf = make([]byte, dtdLength)
+		}
+
+		transferSize = len(buf)
 
-	pages := dma.Alloc(buf, DTD_PAGE_SIZE)
-	defer dma.Free(pages)
+		pages = dma.Alloc(buf, DTD_PAGE_SIZE)
+		defer dma.Free(pages)
+	}
+
+	// Ensure coherency of cacheable transfer buffers, transfer
+	// descriptors are instead accessed in place and must reside in
+	// non-cacheable memory.
+	arm.FlushDCache(pages, transferSize)
+
+	if pool != nil {
+		// the whole chain is linked before priming
+		dtds = pool.chain(ioc, uint32(pages), transferSize)
+
+		// reset endpoint status
+		hw.clear(n, dir)
+		// set dQH head pointer
+		hw.nextDTD(n, dir, dtds[0]._dtd)
+		// prime endpoint, ensuring the dQH update is visible first
+		reg.WriteBarrier(hw.prime, 1<<pos)
+	}
 
 	// loop condition to account for zero transferSize
-	for add := true; add; add = i < transferSize {
+	for add := pool == nil; add; add = i < transferSize {
 		prime := false
 		size := dtdLength
 
@@ -331,9 +426,11 @@ func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte) (out []byte, err e
 		if i == 0 {
 			prime = true
 		} else {
-			// treat dtd.next as a register within the dtd DMA buffer
-			reg.Write(prev._dtd+DTD_NEXT, dtd._dtd)
-			prime = reg.Get(hw.prime, pos, 1) == 0 && reg.Get(hw.stat, pos, 1) == 0
+			// treat dtd.next as a register within the dtd DMA buffer,
+			// ensure the link is visible before sampling status
+			reg.WriteBarrier(prev._dtd+DTD_NEXT, dtd._dtd)
+			prime = (reg.ReadBarrier(hw.prime)>>pos)&1 == 0 &&
+				(reg.ReadBarrier(hw.stat)>>pos)&1 == 0
 		}
 
 		if prime {
@@ -341,8 +438,8 @@ func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte) (out []byte, err e
 			hw.clear(n, dir)
 			// set dQH head pointer
 			hw.nextDTD(n, dir, dtd._dtd)
-			// prime endpoint
-			reg.Set(hw.prime, pos)
+			// prime endpoint, ensuring the dQH update is visible first
+			reg.WriteBarrier(hw.prime, 1<<pos)
 		}
 
 		prev = dtd
@@ -351,31 +448,63 @@ func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte) (out []byte, err e
 		i += dtdLength
 	}
 
-	log.Println("Waiting for priming completion...")
 	// wait for priming completion
 	reg.Wait(hw.prime, pos, 1, 0)
-	log.Println("done.")
 
-	log.Println("Waiting for completion...")
 	// wait for completion
 	if n == 0 {
-		complete := reg.WaitFor(20*time.Millisecond, hw.complete, pos, 1, 1)
-		if !complete {
-			log.Println("timedout")
-			err = fmt.Errorf("transfer completion timed out")
+		if !reg.WaitFor(hw.ControlTimeout, hw.complete, pos, 1, 1) {
+			// flush the stuck transfer
+			reg.Set(hw.flush, pos)
+			hw.stats.update(n, dir, func(s *EndpointStats) { s.Errors++ })
+			return nil, fmt.Errorf("transfer completion timed out")
 		}
-	} else {
-		reg.WaitSignal(hw.done, hw.complete, pos, 1, 1)
+	} else if !reg.WaitSignalFor(hw.timeout[n][dir], done, hw.complete, pos, 1, 1) {
+		select {
+		case <-done:
+			return nil, fmt.Errorf("transfer cancelled")
+		default:
+		}
+
+		// flush the stuck transfer
+		reg.Set(hw.flush, pos)
+		hw.stats.update(n, dir, func(s *EndpointStats) { s.Errors++ })
+		return nil, fmt.Errorf("transfer completion timed out")
 	}
-	log.Println("done.")
 
 	// clear completion
 	reg.Write(hw.complete, 1<<pos)
-	log.Println("Completion cleared")
 
-	size, err := checkDTD(n, dir, dtds, hw.done)
+	timeout := hw.ControlTimeout
+
+	if n != 0 {
+		timeout = hw.timeout[n][dir]
+	}
 
-	if n != 0 && dir == OUT && buf != nil {
+	size, err := checkDTD(n, dir, dtds, done, timeout)
+
+	if dir == OUT {
+		arm.InvalidateDCache(pages, size)
+	}
+
+	hw.stats.update(n, dir, func(s *EndpointStats) {
+		if err != nil {
+			s.Errors++
+			return
+		}
+
+		s.Bytes += uint64(size)
+		s.DTDs += uint64(len(dtds))
+	})
+
+	switch {
+	case dir != OUT:
+	case pool != nil && buf == nil:
+		out = pool.buf[0:size]
+	case pool != nil:
+		out = buf[0:size]
+		copy(out, pool.buf)
+	case buf != nil:
 		out = buf[0:size]
 		dma.Read(pages, 0, out)
 	}
@@ -383,20 +512,41 @@ func (hw *USB) transfer(n int, dir int, ioc bool, buf []byte) (out []byte, err e
 	return
 }
 
+// signal returns the current EP1-N cancellation signal.
+func (hw *USB) signal() chan bool {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.done
+}
+
 // ack transmits a zero length packet to the host through an IN endpoint
 func (hw *USB) ack(n int) (err error) {
-	_, err = hw.transfer(n, IN, false, nil)
+	_, err = hw.transfer(n, IN, false, nil, hw.signal())
 	return
 }
 
 // tx transmits a data buffer to the host through an IN endpoint
 func (hw *USB) tx(n int, ioc bool, in []byte) (err error) {
-	log.Printf("Entered tx for EP%d", n)
-	_, err = hw.transfer(n, IN, ioc, in)
+	return hw.send(n, ioc, in, hw.signal())
+}
+
+// send transmits a data buffer to the host through an IN endpoint, the
+// transfer is cancelled when the argument channel is closed.
+func (hw *USB) send(n int, ioc bool, in []byte, done chan bool) (err error) {
+	_, err = hw.transfer(n, IN, ioc, in, done)
+
+	// A transfer which is an exact multiple of the maximum packet size is
+	// terminated by a zero length packet (5.8.3 Bulk Transfer Packet Size
+	// Constraints, USB2.0), when forced this is performed in software as
+	// the controller only terminates single dTD transfers.
+	if err == nil && hw.zlp[n][IN] && hw.maxPacket[n][IN] > 0 && len(in) > 0 && len(in)%hw.maxPacket[n][IN] == 0 {
+		_, err = hw.transfer(n, IN, false, nil, done)
+	}
 
 	// p3803, 56.4.6.4.2.3 Status Phase, IMX6ULLRM
 	if err == nil && n == 0 {
-		_, err = hw.transfer(n, OUT, false, nil)
+		_, err = hw.transfer(n, OUT, false, nil, done)
 	}
 
 	return
@@ -404,17 +554,30 @@ func (hw *USB) tx(n int, ioc bool, in []byte) (err error) {
 
 // tx receives a data buffer from the host through an OUT endpoint
 func (hw *USB) rx(n int, ioc bool, buf []byte) (out []byte, err error) {
-	return hw.transfer(n, OUT, ioc, buf)
+	return hw.transfer(n, OUT, ioc, buf, hw.signal())
 }
 
 // stall forces the endpoint to return a STALL handshake to the host
 func (hw *USB) stall(n int, dir int) {
 	ctrl := hw.epctrl + uint32(4*n)
 
+	hw.stats.update(n, dir, func(s *EndpointStats) { s.Stalls++ })
+
+	if dir == IN {
+		reg.RMW(ctrl, 1<<ENDPTCTRL_TXS, 1<<ENDPTCTRL_TXS)
+	} else {
+		reg.RMW(ctrl, 1<<ENDPTCTRL_RXS, 1<<ENDPTCTRL_RXS)
+	}
+}
+
+// unstall clears an endpoint STALL condition
+func (hw *USB) unstall(n int, dir int) {
+	ctrl := hw.epctrl + uint32(4*n)
+
 	if dir == IN {
-		reg.Set(ctrl, ENDPTCTRL_TXS)
+		reg.RMW(ctrl, 1<<ENDPTCTRL_TXS, 0)
 	} else {
-		reg.Set(ctrl, ENDPTCTRL_RXS)
+		reg.RMW(ctrl, 1<<ENDPTCTRL_RXS, 0)
 	}
 }
 
@@ -428,8 +591,8 @@ func (hw *USB) reset(n int, dir int) {
 	ctrl := hw.epctrl + uint32(4*n)
 
 	if dir == IN {
-		reg.Set(ctrl, ENDPTCTRL_TXR)
+		reg.RMW(ctrl, 1<<ENDPTCTRL_TXR, 1<<ENDPTCTRL_TXR)
 	} else {
-		reg.Set(ctrl, ENDPTCTRL_RXR)
+		reg.RMW(ctrl, 1<<ENDPTCTRL_RXR, 1<<ENDPTCTRL_RXR)
 	}
 }
@@ -10,20 +10,25 @@
 package usb
 
 import (
-	"encoding/hex"
+	"errors"
 	"log"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/usbarmory/tamago/internal/reg"
 )
 
+// ErrStall can be returned by an EndpointFunction to halt the endpoint.
+var ErrStall = errors.New("endpoint stalled")
+
 // Endpoint represents a USB 2.0 endpoint.
 type Endpoint struct {
 	sync.Mutex
 
 	bus  *USB
-	wg   *sync.WaitGroup
+	done chan bool
+	exit chan bool
 	desc *EndpointDescriptor
 
 	n   int
@@ -35,7 +40,9 @@ func (ep *Endpoint) Init() {
 	ep.n = ep.desc.Number()
 	ep.dir = ep.desc.Direction()
 
-	ep.bus.set(ep.n, ep.dir, int(ep.desc.MaxPacketSize), ep.desc.Zero, 0)
+	ep.bus.set(ep.n, ep.dir, int(ep.desc.MaxPacketSize), ep.desc.Zero && !ep.desc.ForceZero, 0)
+	ep.bus.timeout[ep.n][ep.dir] = ep.desc.Timeout
+	ep.bus.zlp[ep.n][ep.dir] = ep.desc.ForceZero
 	ep.bus.enable(ep.n, ep.dir, ep.desc.TransferType())
 }
 
@@ -51,6 +58,9 @@ func (ep *Endpoint) Start() {
 	var res []byte
 
 	if ep.desc.Function == nil {
+		// the endpoint is left to EndpointReader()/EndpointWriter()
+		ep.Init()
+		close(ep.exit)
 		return
 	}
 
@@ -58,16 +68,15 @@ func (ep *Endpoint) Start() {
 
 	defer func() {
 		ep.Flush()
-		ep.wg.Done()
+		close(ep.exit)
 		ep.Unlock()
 	}()
 
 	ep.Init()
 	for {
 		runtime.Gosched()
-		log.Printf("\nep: %d\ndir: %d\nbuf: %s\n", ep.n, ep.dir, hex.EncodeToString(buf))
 		if ep.dir == OUT {
-			buf, err = ep.bus.rx(ep.n, false, res)
+			buf, err = ep.bus.transfer(ep.n, OUT, false, res, ep.done)
 
 			if err == nil && len(buf) != 0 {
 				res, err = ep.desc.Function(buf, err)
@@ -76,29 +85,57 @@ func (ep *Endpoint) Start() {
 			res, err = ep.desc.Function(nil, err)
 
 			if err == nil && len(res) != 0 {
-				err = ep.bus.tx(ep.n, false, res)
+				err = ep.bus.send(ep.n, false, res, ep.done)
 			}
 		}
 
+		if errors.Is(err, ErrStall) {
+			ep.bus.stall(ep.n, ep.dir)
+			res = nil
+			err = nil
+		}
+
 		if err != nil {
 			ep.Flush()
 			log.Printf("usb: EP%d.%d transfer error, %v", ep.n, ep.dir, err)
 		}
 
 		select {
-		case <-ep.bus.done:
+		case <-ep.done:
 			return
 		default:
 		}
 	}
 }
 
-func (hw *USB) startEndpoints(wg *sync.WaitGroup, dev *Device, configurationValue uint8) {
+// done returns a channel closed when the endpoints of the active device
+// configuration are stopped, allowing blocking endpoint functions to return
+// on cancellation.
+func (d *Device) done() chan bool {
+	if d.bus == nil {
+		return nil
+	}
+
+	return d.bus.signal()
+}
+
+func (hw *USB) startEndpoints(dev *Device, configurationValue uint8) (err error) {
 	if configurationValue == 0 {
 		return
 	}
 
-	hw.done = make(chan bool)
+	hw.Lock()
+	defer hw.Unlock()
+
+	// Handlers which did not return on a previous cancellation still
+	// drive their endpoint queue heads, which therefore cannot be
+	// reused.
+	if len(hw.handlers) != 0 {
+		return errors.New("endpoint handlers from previous configuration still running")
+	}
+
+	done := make(chan bool)
+	hw.done = done
 
 	for _, conf := range dev.Configurations {
 		if configurationValue != conf.ConfigurationValue {
@@ -108,12 +145,13 @@ func (hw *USB) startEndpoints(wg *sync.WaitGroup, dev *Device, configurationValu
 		for _, iface := range conf.Interfaces {
 			for _, desc := range iface.Endpoints {
 				ep := &Endpoint{
-					wg:   wg,
 					bus:  hw,
+					done: done,
+					exit: make(chan bool),
 					desc: desc,
 				}
 
-				wg.Add(1)
+				hw.handlers = append(hw.handlers, ep.exit)
 
 				go func(ep *Endpoint) {
 					log.Printf("Starting EP%d", ep.desc.Number())
@@ -122,4 +160,67 @@ func (hw *USB) startEndpoints(wg *sync.WaitGroup, dev *Device, configurationValu
 			}
 		}
 	}
+
+	return
+}
+
+// cancelEndpoints signals cancellation to all endpoint handlers, terminating
+// pending transfers and disabling EP1-N, without waiting for handlers to
+// return. The function returns the handlers which might still be running.
+func (hw *USB) cancelEndpoints() (handlers []chan bool) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.done == nil {
+		return
+	}
+
+	select {
+	case <-hw.done:
+		// already cancelled
+	default:
+		// signal cancellation
+		close(hw.done)
+
+		for n := 1; n < MAX_ENDPOINTS; n++ {
+			hw.disable(n)
+		}
+
+		// flush all endpoints to terminate pending transfers
+		reg.Write(hw.flush, 0xffffffff)
+	}
+
+	return hw.handlers
+}
+
+func (hw *USB) stopEndpoints() (err error) {
+	handlers := hw.cancelEndpoints()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	timeout := time.NewTimer(EndpointTimeout)
+	defer timeout.Stop()
+
+	for i, exit := range handlers {
+		select {
+		case <-exit:
+			continue
+		case <-timeout.C:
+		}
+
+		// keep track of handlers which are still running
+		hw.Lock()
+		hw.handlers = handlers[i:]
+		hw.Unlock()
+
+		return errors.New("endpoint handlers did not return on cancellation")
+	}
+
+	hw.Lock()
+	hw.handlers = nil
+	hw.Unlock()
+
+	return
 }
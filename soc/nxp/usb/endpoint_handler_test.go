@@ -0,0 +1,65 @@
+// USB device mode support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestStopEndpointsTimeout(t *testing.T) {
+	var flush uint32
+	var epctrl [MAX_ENDPOINTS]uint32
+
+	hw := &USB{
+		flush:  uint32(uintptr(unsafe.Pointer(&flush))),
+		epctrl: uint32(uintptr(unsafe.Pointer(&epctrl[0]))),
+		done:   make(chan bool),
+	}
+
+	// simulate an endpoint handler blocked in its EndpointFunction
+	block := make(chan bool)
+	exit := make(chan bool)
+	hw.handlers = append(hw.handlers, exit)
+
+	go func() {
+		<-block
+		close(exit)
+	}()
+
+	n := runtime.NumGoroutine()
+
+	if err := hw.stopEndpoints(); err == nil {
+		t.Fatal("expected error on blocked endpoint handler")
+	}
+
+	if m := runtime.NumGoroutine(); m != n {
+		t.Errorf("goroutine leak on timeout (%d != %d)", m, n)
+	}
+
+	if flush != 0xffffffff {
+		t.Errorf("endpoints not flushed on cancellation (%#x)", flush)
+	}
+
+	if err := hw.startEndpoints(&Device{}, 1); err == nil {
+		t.Error("expected error on restart with running endpoint handlers")
+	}
+
+	close(block)
+
+	if err := hw.stopEndpoints(); err != nil {
+		t.Errorf("unexpected error after handler return, %v", err)
+	}
+
+	if len(hw.handlers) != 0 {
+		t.Errorf("stale endpoint handlers (%d)", len(hw.handlers))
+	}
+}
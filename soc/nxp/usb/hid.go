@@ -0,0 +1,333 @@
+// USB device mode support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HID report types (p51, 7.2.1 Get_Report Request, HID1.11)
+const (
+	HID_REPORT_INPUT   = 1
+	HID_REPORT_OUTPUT  = 2
+	HID_REPORT_FEATURE = 3
+)
+
+// HID idle rate unit (p53, 7.2.4 Set_Idle Request, HID1.11)
+const HID_IDLE_UNIT = 4 * time.Millisecond
+
+// hidInputReport represents the state of an input report.
+type hidInputReport struct {
+	// last transmitted input report
+	last []byte
+	// input report sequence number, to invalidate pending idle
+	// retransmissions
+	seq uint64
+	// idle retransmission timer
+	timer *time.Timer
+}
+
+// hidInterface represents the input report state of a HID interface.
+type hidInterface struct {
+	// serializes input report transmissions
+	tx sync.Mutex
+
+	// idle rate for all report IDs, zero for indefinite
+	idle time.Duration
+	// idle rates overriding the default one, indexed by report ID
+	idles map[uint8]time.Duration
+
+	// input report state indexed by report ID
+	reports map[uint8]*hidInputReport
+}
+
+// idleRate returns the idle rate for the argument report ID.
+func (hid *hidInterface) idleRate(id uint8) time.Duration {
+	if idle, ok := hid.idles[id]; ok {
+		return idle
+	}
+
+	return hid.idle
+}
+
+// setIdleRate sets the idle rate for the argument report ID, report ID 0
+// applies to all reports (p53, 7.2.4 Set_Idle Request, HID1.11).
+func (hid *hidInterface) setIdleRate(id uint8, idle time.Duration) {
+	if id == 0 {
+		hid.idle = idle
+		hid.idles = nil
+		return
+	}
+
+	if hid.idles == nil {
+		hid.idles = make(map[uint8]time.Duration)
+	}
+
+	hid.idles[id] = idle
+}
+
+func (hid *hidInterface) report(id uint8) *hidInputReport {
+	if hid.reports == nil {
+		hid.reports = make(map[uint8]*hidInputReport)
+	}
+
+	if _, ok := hid.reports[id]; !ok {
+		hid.reports[id] = &hidInputReport{}
+	}
+
+	return hid.reports[id]
+}
+
+// hidState represents the HID function state.
+type hidState struct {
+	sync.Mutex
+
+	// input report state indexed by interface number
+	ifaces map[uint8]*hidInterface
+}
+
+func (hid *hidState) get(iface uint8) *hidInterface {
+	if hid.ifaces == nil {
+		hid.ifaces = make(map[uint8]*hidInterface)
+	}
+
+	if _, ok := hid.ifaces[iface]; !ok {
+		hid.ifaces[iface] = &hidInterface{}
+	}
+
+	return hid.ifaces[iface]
+}
+
+// hidReportIDs returns whether a HID report descriptor declares report IDs,
+// in which case each report is prefixed by its report ID
+// (p35, 6.2.2.7 Global Items, HID1.11).
+func hidReportIDs(desc HIDReportDescriptor) bool {
+	for i := 0; i < len(desc); {
+		prefix := desc[i]
+
+		// long items
+		if prefix == 0xfe {
+			if i+1 >= len(desc) {
+				break
+			}
+
+			i += 3 + int(desc[i+1])
+			continue
+		}
+
+		size := int(prefix & 0b11)
+
+		if size == 3 {
+			size = 4
+		}
+
+		if prefix>>4 == HID_REPORT_ID && (prefix>>2)&0b11 == HID_ITEM_GLOBAL {
+			return true
+		}
+
+		i += 1 + size
+	}
+
+	return false
+}
+
+// HIDSetReportFunction represents the function to process reports received
+// through SET_REPORT requests (p52, 7.2.2 Set_Report Request, HID1.11), such
+// as keyboard LED state output reports.
+type HIDSetReportFunction func(reportType uint8, reportID uint8, data []byte)
+
+// HIDGetReportFunction represents the function to return reports requested
+// through GET_REPORT requests (p51, 7.2.1 Get_Report Request, HID1.11), a nil
+// result stalls the request.
+type HIDGetReportFunction func(reportType uint8, reportID uint8) []byte
+
+// hidReport returns the report type and ID addressed by a GET_REPORT or
+// SET_REPORT request.
+func hidReport(setup *SetupData) (reportType uint8, reportID uint8) {
+	// wValue is byte swapped (see SetupData.swap())
+	return uint8(setup.Value), uint8(setup.Value >> 8)
+}
+
+func (hw *USB) hidGetReport(dev *Device, setup *SetupData) (err error) {
+	reportType, reportID := hidReport(setup)
+
+	if dev.HIDGetReport == nil {
+		return fmt.Errorf("unsupported HID report %d:%d", reportType, reportID)
+	}
+
+	buf := dev.HIDGetReport(reportType, reportID)
+
+	if buf == nil {
+		return fmt.Errorf("invalid HID report %d:%d", reportType, reportID)
+	}
+
+	return hw.tx(0, false, trim(buf, setup.Length))
+}
+
+func (hw *USB) hidSetReport(dev *Device, setup *SetupData) (err error) {
+	reportType, reportID := hidReport(setup)
+
+	if dev.HIDSetReport == nil {
+		return fmt.Errorf("unsupported HID report %d:%d", reportType, reportID)
+	}
+
+	var buf []byte
+
+	if setup.Length > 0 {
+		if buf, err = hw.rx(0, false, make([]byte, setup.Length)); err != nil {
+			return
+		}
+	}
+
+	dev.HIDSetReport(reportType, reportID, buf)
+
+	return hw.ack(0)
+}
+
+func (d *Device) hidSetIdle(setup *SetupData) {
+	// wValue is byte swapped (see SetupData.swap())
+	duration := uint8(setup.Value)
+	reportID := uint8(setup.Value >> 8)
+
+	d.hid.Lock()
+	defer d.hid.Unlock()
+
+	d.hid.get(uint8(setup.Index)).setIdleRate(reportID, time.Duration(duration)*HID_IDLE_UNIT)
+}
+
+func (hw *USB) hidGetIdle(dev *Device, setup *SetupData) (err error) {
+	// wValue is byte swapped (see SetupData.swap())
+	reportID := uint8(setup.Value >> 8)
+
+	dev.hid.Lock()
+	idle := dev.hid.get(uint8(setup.Index)).idleRate(reportID)
+	dev.hid.Unlock()
+
+	return hw.tx(0, false, trim([]byte{uint8(idle / HID_IDLE_UNIT)}, setup.Length))
+}
+
+// hidEndpoint returns the interrupt IN endpoint of the argument interface
+// within the active configuration.
+func (d *Device) hidEndpoint(iface uint8) *EndpointDescriptor {
+	for _, conf := range d.Configurations {
+		if conf.ConfigurationValue != d.ConfigurationValue {
+			continue
+		}
+
+		for _, desc := range conf.Interfaces {
+			if desc.InterfaceNumber != iface {
+				continue
+			}
+
+			for _, ep := range desc.Endpoints {
+				if ep.Direction() == IN && ep.TransferType() == INTERRUPT {
+					return ep
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// hidTransmit transmits an input report, unless superseded, and schedules its
+// retransmission according to the idle rate.
+func (d *Device) hidTransmit(n int, iface uint8, id uint8, seq uint64) (err error) {
+	d.hid.Lock()
+	state := d.hid.get(iface)
+	d.hid.Unlock()
+
+	state.tx.Lock()
+	defer state.tx.Unlock()
+
+	d.hid.Lock()
+	report := state.report(id)
+
+	if report.seq != seq {
+		d.hid.Unlock()
+		return
+	}
+
+	buf := report.last
+	idle := state.idleRate(id)
+	d.hid.Unlock()
+
+	if err = d.bus.tx(n, false, buf); err != nil {
+		return
+	}
+
+	if idle == 0 {
+		return
+	}
+
+	d.hid.Lock()
+	defer d.hid.Unlock()
+
+	if report.seq == seq {
+		report.timer = time.AfterFunc(idle, func() {
+			d.hidTransmit(n, iface, id, seq)
+		})
+	}
+
+	return
+}
+
+// SendHIDReport transmits an input report through the interrupt IN endpoint
+// of the argument HID interface, within the active configuration. The
+// endpoint descriptor must not have a Function set, as the handler would
+// compete for the same transfers.
+//
+// The idle rate requested by the host, for each report ID, is honored (p53,
+// 7.2.4 Set_Idle Request, HID1.11): an unchanged report is never transmitted
+// again by this function, the last report is instead retransmitted every
+// idle rate period until superseded, or never when the idle rate is zero
+// (default).
+//
+// The function blocks until the host has read the report.
+func (d *Device) SendHIDReport(iface int, report []byte) (err error) {
+	var id uint8
+
+	if d.bus == nil {
+		return errors.New("device is not started")
+	}
+
+	ep := d.hidEndpoint(uint8(iface))
+
+	if ep == nil {
+		return fmt.Errorf("no interrupt IN endpoint for interface %d", iface)
+	}
+
+	if len(report) > 0 && hidReportIDs(d.HIDReports[uint8(iface)]) {
+		id = report[0]
+	}
+
+	d.hid.Lock()
+	state := d.hid.get(uint8(iface)).report(id)
+
+	if bytes.Equal(report, state.last) {
+		d.hid.Unlock()
+		return
+	}
+
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+
+	state.last = append([]byte{}, report...)
+	state.seq += 1
+	seq := state.seq
+	d.hid.Unlock()
+
+	return d.hidTransmit(ep.Number(), uint8(iface), id, seq)
+}
@@ -0,0 +1,80 @@
+// USB host mode support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// HostMode sets the USB controller in host mode, enabling port power.
+//
+// At this time only controller mode and port status are supported, host
+// enumeration and transfers are not implemented.
+func (hw *USB) HostMode() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	reg.Set(hw.cmd, USBCMD_RST)
+	reg.Wait(hw.cmd, USBCMD_RST, 1, 0)
+
+	// p3872, 56.6.33 USB Device Mode (USB_nUSBMODE), IMX6ULLRM)
+	reg.SetN(hw.mode, USBMODE_CM, 0b11, USBMODE_CM_HOST)
+	reg.Wait(hw.mode, USBMODE_CM, 0b11, USBMODE_CM_HOST)
+
+	// clear OTG termination, as pull-down resistors are required in host
+	// mode
+	reg.Clear(hw.otg, OTGSC_OT)
+
+	// enable port power
+	reg.Set(hw.sc, PORTSC_PP)
+
+	// clear all pending interrupts
+	reg.Write(hw.sts, 0xffffffff)
+
+	// run
+	reg.Set(hw.cmd, USBCMD_RS)
+}
+
+// IsHost returns whether the OTG ID pin is grounded, indicating that the
+// controller should operate in host mode (A-device). The ID pin is sampled
+// with its internal pull-up enabled.
+func (hw *USB) IsHost() bool {
+	reg.Set(hw.otg, OTGSC_IDPU)
+	return reg.Get(hw.otg, OTGSC_ID, 1) == 0
+}
+
+// SwitchRole sets the USB controller in host or device mode according to the
+// OTG ID pin state (see IsHost()), it returns whether host mode was selected.
+func (hw *USB) SwitchRole() (host bool) {
+	if host = hw.IsHost(); host {
+		hw.HostMode()
+	} else {
+		hw.DeviceMode()
+	}
+
+	return
+}
+
+// VBUS returns whether VBUS is valid, as an A-device in host mode or as a
+// B-device (session valid) in device mode.
+func (hw *USB) VBUS() bool {
+	return reg.Get(hw.otg, OTGSC_AVV, 1) == 1 || reg.Get(hw.otg, OTGSC_BSV, 1) == 1
+}
+
+// PortStatus returns whether a device is connected to the port and whether
+// the port is enabled, as reported in host mode.
+func (hw *USB) PortStatus() (connected bool, enabled bool) {
+	sc := reg.Read(hw.sc)
+
+	connected = sc&(1<<PORTSC_CCS) != 0
+	enabled = sc&(1<<PORTSC_PE) != 0
+
+	return
+}
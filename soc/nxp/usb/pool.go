@@ -0,0 +1,150 @@
+// NXP USBOH3USBO2 / USBPHY driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"errors"
+
+	"github.com/usbarmory/tamago/dma"
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// endpointPool represents a set of transfer descriptors and buffers which are
+// allocated once and reused across endpoint transfers.
+type endpointPool struct {
+	region *dma.Region
+	dtds   []*dTD
+
+	// DMA pointer for transfer descriptors
+	dtdAddr uint
+	// DMA pointer for transfer buffers
+	addr uint
+	// transfer buffers
+	buf []byte
+}
+
+// newEndpointPool allocates count transfer descriptors, along with their
+// transfer buffers, from the argument DMA region.
+func newEndpointPool(region *dma.Region, count int) (pool *endpointPool) {
+	dtdLength := DTD_PAGES * DTD_PAGE_SIZE
+
+	pool = &endpointPool{
+		region: region,
+		dtds:   make([]*dTD, count),
+	}
+
+	pool.dtdAddr, _ = region.Reserve(count*DTD_ALIGN, DTD_ALIGN)
+	pool.addr, pool.buf = region.Reserve(count*dtdLength, DTD_PAGE_SIZE)
+
+	for i := range pool.dtds {
+		pool.dtds[i] = &dTD{
+			_dtd: uint32(pool.dtdAddr) + uint32(i*DTD_ALIGN),
+		}
+	}
+
+	return
+}
+
+// free releases the pool DMA buffers.
+func (pool *endpointPool) free() {
+	pool.region.Release(pool.dtdAddr)
+	pool.region.Release(pool.addr)
+}
+
+// chain prepares, and links, the pool transfer descriptors for a transfer of
+// the argument size at the argument buffer address.
+func (pool *endpointPool) chain(ioc bool, addr uint32, size int) []*dTD {
+	var i, count int
+
+	dtdLength := DTD_PAGES * DTD_PAGE_SIZE
+
+	// loop condition to account for zero size
+	for add := true; add; add = i < size {
+		n := dtdLength
+
+		if i+n > size {
+			n = size - i
+		}
+
+		dtd := pool.dtds[count]
+		dtd.reset(ioc, addr+uint32(i), n)
+
+		if count > 0 {
+			// treat dtd.next as a register within the dtd DMA buffer
+			reg.WriteBarrier(pool.dtds[count-1]._dtd+DTD_NEXT, dtd._dtd)
+		}
+
+		count++
+		i += dtdLength
+	}
+
+	return pool.dtds[0:count]
+}
+
+// reset updates a previously allocated transfer descriptor, directly within
+// its DMA buffer, for a new transfer.
+func (dtd *dTD) reset(ioc bool, addr uint32, size int) {
+	dtd.init(ioc, addr, size)
+
+	reg.Write(dtd._dtd+DTD_NEXT, dtd.Next)
+	reg.Write(dtd._dtd+DTD_TOKEN, dtd.Token)
+
+	for i, page := range dtd.Buffer {
+		reg.Write(dtd._dtd+8+uint32(4*i), page)
+	}
+}
+
+// PreallocEndpoint allocates count transfer descriptors, along with their
+// transfer buffers, for exclusive use of the argument endpoint.
+//
+// Transfers up to count*DTD_PAGES*DTD_PAGE_SIZE bytes reuse such buffers
+// rather than allocating DMA memory on each transfer, larger transfers fall
+// back to dynamic allocation. For OUT transfers performed without a receive
+// buffer the returned slice points to the preallocated buffer and it is
+// therefore only valid until the next endpoint transfer.
+//
+// Calling PreallocEndpoint with a zero count releases any previously
+// allocated buffer.
+//
+// The function must be invoked before Start(), as the buffers are accessed by
+// transfers without locking, otherwise an error is returned.
+func (hw *USB) PreallocEndpoint(n int, dir int, count int) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.started {
+		return errors.New("device mode is already started")
+	}
+
+	if n < 0 || n >= MAX_ENDPOINTS {
+		return errors.New("invalid endpoint number")
+	}
+
+	if dir != OUT && dir != IN {
+		return errors.New("invalid endpoint direction")
+	}
+
+	if count < 0 {
+		return errors.New("invalid descriptor count")
+	}
+
+	if pool := hw.pool[n][dir]; pool != nil {
+		pool.free()
+		hw.pool[n][dir] = nil
+	}
+
+	if count == 0 {
+		return
+	}
+
+	hw.pool[n][dir] = newEndpointPool(dma.Default(), count)
+
+	return
+}
@@ -0,0 +1,83 @@
+// NXP USBOH3USBO2 / USBPHY driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/dma"
+)
+
+const testPoolCount = 4
+
+// backing memory for a test DMA region, sized to fit the pool buffers along
+// with their alignment
+var testPoolMem [(testPoolCount+2)*DTD_PAGES*DTD_PAGE_SIZE + 2*DTD_PAGE_SIZE]byte
+
+func newTestPool(tb testing.TB) *endpointPool {
+	region, err := dma.NewRegion(uint(uintptr(unsafe.Pointer(&testPoolMem[0]))), len(testPoolMem), true)
+
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return newEndpointPool(region, testPoolCount)
+}
+
+func TestPoolChain(t *testing.T) {
+	pool := newTestPool(t)
+	defer pool.free()
+
+	dtdLength := DTD_PAGES * DTD_PAGE_SIZE
+
+	for _, size := range []int{0, 1, dtdLength, dtdLength + 1, testPoolCount * dtdLength} {
+		dtds := pool.chain(true, uint32(pool.addr), size)
+
+		if n := (size + dtdLength - 1) / dtdLength; len(dtds) != n && !(size == 0 && len(dtds) == 1) {
+			t.Errorf("size %d, got %d dTDs, expected %d", size, len(dtds), n)
+		}
+
+		for i := 1; i < len(dtds); i++ {
+			if next := *(*uint32)(unsafe.Pointer(uintptr(dtds[i-1]._dtd + DTD_NEXT))); next != dtds[i]._dtd {
+				t.Errorf("size %d, dTD %d not linked (%#x != %#x)", size, i, next, dtds[i]._dtd)
+			}
+		}
+	}
+}
+
+func TestPoolChainAllocs(t *testing.T) {
+	pool := newTestPool(t)
+	defer pool.free()
+
+	size := len(pool.buf)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		pool.chain(true, uint32(pool.addr), size)
+	})
+
+	if allocs != 0 {
+		t.Errorf("got %v allocations per chain, expected 0", allocs)
+	}
+}
+
+func BenchmarkPoolChain(b *testing.B) {
+	pool := newTestPool(b)
+	defer pool.free()
+
+	size := len(pool.buf)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+
+	for i := 0; i < b.N; i++ {
+		pool.chain(true, uint32(pool.addr), size)
+	}
+}
@@ -10,12 +10,12 @@
 package usb
 
 import (
-	"encoding/binary"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/usbarmory/tamago/bits"
 	"github.com/usbarmory/tamago/internal/reg"
 )
 
@@ -32,7 +32,11 @@ const (
 	GET_INTERFACE      = 10
 	SET_INTERFACE      = 11
 	SYNCH_FRAME        = 12
+	HID_GET_REPORT     = 0x01
+	HID_GET_IDLE       = 0x02
+	HID_SET_REPORT     = 0x09
 	HID_SET_IDLE       = 0x0a
+	HID_SET_PROTOCOL   = 0x0b
 	HID_GET_DESCRIPTOR = 0x22
 )
 
@@ -63,6 +67,15 @@ const (
 	TEST_MODE            = 2
 )
 
+// Test mode selectors (p259, Table 9-7, USB2.0)
+const (
+	TEST_J            = 1
+	TEST_K            = 2
+	TEST_SE0_NAK      = 3
+	TEST_PACKET       = 4
+	TEST_FORCE_ENABLE = 5
+)
+
 // SetupData implements
 // p276, Table 9-2. Format of Setup Data, USB2.0.
 type SetupData struct {
@@ -76,10 +89,7 @@ type SetupData struct {
 // swap adjusts the endianness of values written in memory by the hardware, as
 // they do not match the expected one by Go.
 func (s *SetupData) swap() {
-	b := make([]byte, 2)
-
-	binary.BigEndian.PutUint16(b, s.Value)
-	s.Value = binary.LittleEndian.Uint16(b)
+	s.Value = bits.ReverseBytes16(s.Value)
 }
 
 func (hw *USB) getSetup() (setup *SetupData) {
@@ -87,6 +97,12 @@ func (hw *USB) getSetup() (setup *SetupData) {
 
 	// p3801, 56.4.6.4.2.1 Setup Phase, IMX6ULLRM
 
+	// a protocol stall is cleared at the beginning of the next control
+	// transfer (8.5.3.4 STALL Handshakes Returned by Control Pipes,
+	// USB2.0), do not rely on the controller having done so.
+	hw.unstall(0, IN)
+	hw.unstall(0, OUT)
+
 	// clear setup status
 	reg.Set(hw.setup, 0)
 	// flush EP0 IN
@@ -107,27 +123,41 @@ func (hw *USB) getDescriptor(dev *Device, setup *SetupData) (err error) {
 	log.Println("DescType: " + fmt.Sprint(bDescriptorType))
 	switch bDescriptorType {
 	case DEVICE:
-		err = hw.tx(0, false, trim(dev.Descriptor.Bytes(), setup.Length))
+		err = hw.tx(0, false, trim(dev.DeviceDescriptor(), setup.Length))
 	case CONFIGURATION:
 		var conf []byte
 		if conf, err = dev.Configuration(index); err == nil {
 			err = hw.tx(0, false, trim(conf, setup.Length))
 		}
 	case STRING:
-		if int(index+1) > len(dev.Strings) {
+		// wIndex holds the requested language ID
+		if buf, e := dev.LocalizedString(uint8(index), setup.Index); e != nil {
 			hw.stall(0, IN)
-			err = fmt.Errorf("invalid string descriptor index %d", index)
+			err = e
 		} else {
-			err = hw.tx(0, false, trim(dev.Strings[index], setup.Length))
+			err = hw.tx(0, false, trim(buf, setup.Length))
 		}
 	case DEVICE_QUALIFIER:
-		err = hw.tx(0, false, dev.Qualifier.Bytes())
+		// full-speed only devices must respond with a request error
+		// (p292, 9.6.2 Device_Qualifier, USB2.0)
+		if reg.Get(hw.sc, PORTSC_PFSC, 1) == 1 {
+			hw.stall(0, IN)
+			return errors.New("device qualifier not supported at full-speed only")
+		}
+
+		if buf, e := dev.DeviceQualifier(); e != nil {
+			hw.stall(0, IN)
+			err = e
+		} else {
+			err = hw.tx(0, false, trim(buf, setup.Length))
+		}
 	case HID_REPORT:
-		log.Println("HID_REPORT")
-		r, e := hex.DecodeString("05010906a101050719e029e71500250175019508810295017508810395037501050819012903910295017505910395067508150026a4000507190029a48100c0")
-		log.Println("error? = ", e)
-		err = hw.tx(0, false, trim(r, setup.Length))
-		log.Println("HID_REPORT sent")
+		if report, ok := dev.HIDReports[uint8(setup.Index)]; ok {
+			err = hw.tx(0, false, trim(report, setup.Length))
+		} else {
+			hw.stall(0, IN)
+			err = fmt.Errorf("invalid HID report descriptor interface %d", setup.Index)
+		}
 	default:
 		log.Println("DEFAULTED getDescriptor")
 		hw.stall(0, IN)
@@ -148,11 +178,34 @@ func (hw *USB) handleStandardSetup(dev *Device, setup *SetupData) (err error) {
 			n := int(setup.Index & 0b1111)
 			dir := int(setup.Index&0b10000000) / 0b10000000
 
+			hw.unstall(n, dir)
 			hw.reset(n, dir)
 			err = hw.ack(0)
 		default:
 			hw.stall(0, IN)
 		}
+	case SET_FEATURE:
+		switch setup.Value<<8 | setup.Value>>8 {
+		case TEST_MODE:
+			sel := uint32(setup.Index >> 8)
+
+			if sel < TEST_J || sel > TEST_FORCE_ENABLE {
+				hw.stall(0, IN)
+				return fmt.Errorf("invalid test selector %#x", sel)
+			}
+
+			// the transition to test mode must be complete no
+			// later than 3 ms after the completion of the status
+			// stage (p259, 9.4.9 Set Feature, USB2.0)
+			if err = hw.ack(0); err != nil {
+				return
+			}
+
+			reg.SetN(hw.sc, PORTSC_PTC, 0b1111, sel)
+			hw.test = true
+		default:
+			hw.stall(0, IN)
+		}
 	case SET_ADDRESS:
 		addr := uint32((setup.Value<<8)&0xff00 | (setup.Value >> 8))
 
@@ -172,6 +225,18 @@ func (hw *USB) handleStandardSetup(dev *Device, setup *SetupData) (err error) {
 	case SET_INTERFACE:
 		dev.AlternateSetting = uint8(setup.Value >> 8)
 		err = hw.ack(0)
+	case SYNCH_FRAME:
+		// only isochronous endpoints support synchronization frames
+		// (p285, 9.4.11 Synch Frame, USB2.0)
+		ep := dev.endpoint(uint8(setup.Index))
+
+		if ep == nil || ep.TransferType() != ISOCHRONOUS {
+			hw.stall(0, IN)
+			return fmt.Errorf("invalid synch frame endpoint %#x", setup.Index)
+		}
+
+		frame := uint16(reg.Get(hw.Base+USB_UOGx_FRINDEX, FRINDEX_FRINDEX, 0x7ff))
+		err = hw.tx(0, false, []byte{byte(frame), byte(frame >> 8)})
 	case SET_ETHERNET_PACKET_FILTER:
 		// no meaningful action for now
 		err = hw.ack(0)
@@ -187,8 +252,22 @@ func (hw *USB) handleClassSpecificSetup(dev *Device, setup *SetupData) (err erro
 	// I only care about HID Setup Requests for now
 	// TODO: extract logic to HID-specific file/method
 	switch setup.Request {
+	case HID_GET_REPORT:
+		err = hw.hidGetReport(dev, setup)
+	case HID_SET_REPORT:
+		err = hw.hidSetReport(dev, setup)
+	case HID_GET_IDLE:
+		err = hw.hidGetIdle(dev, setup)
 	case HID_SET_IDLE:
 		log.Println("SET_IDLE")
+		dev.hidSetIdle(setup)
+		err = hw.ack(0)
+	case SET_ETHERNET_PACKET_FILTER:
+		// no meaningful action for now
+		err = hw.ack(0)
+	case HID_SET_PROTOCOL:
+		// boot and report protocols share the same report format for
+		// all provided report descriptors
 		err = hw.ack(0)
 	default:
 		log.Println("DEFAULT")
@@ -204,18 +283,20 @@ func (hw *USB) handleSetup(dev *Device, setup *SetupData) (err error) {
 	}
 
 	if dev.Setup != nil {
-		in, ack, done, err := dev.Setup(setup)
+		res, err := dev.Setup(setup)
 
 		if err != nil {
 			hw.stall(0, IN)
 			return err
-		} else if len(in) != 0 {
-			err = hw.tx(0, false, in)
-		} else if ack {
-			err = hw.ack(0)
 		}
 
-		if done || err != nil {
+		if res.Handled {
+			if len(res.In) != 0 {
+				err = hw.tx(0, false, res.In)
+			} else if res.Ack {
+				err = hw.ack(0)
+			}
+
 			return err
 		}
 	}
@@ -223,14 +304,23 @@ func (hw *USB) handleSetup(dev *Device, setup *SetupData) (err error) {
 	log.Printf("%x %x %x %x %x \r", setup.RequestType, setup.Request, setup.Value, setup.Index, setup.Length)
 	time.Sleep(100 * time.Millisecond)
 	log.Printf("\nRequestType: %d", setup.RequestType)
-	if setup.RequestType == 0x21 {
+	if dev.isDFU(setup) {
+		err = hw.handleDFUSetup(dev, setup)
+	} else if setup.RequestType == 0x21 || setup.RequestType == 0xa1 {
 		log.Println("CLASS SPECIFIC")
-		hw.handleClassSpecificSetup(dev, setup)
+		err = hw.handleClassSpecificSetup(dev, setup)
 	} else {
 		log.Println("STANDARD")
-		hw.handleStandardSetup(dev, setup)
+		err = hw.handleStandardSetup(dev, setup)
 		log.Println("returned from hw.handleStandardSetup")
 	}
+
+	if err != nil {
+		// signal the failed request to the host, the control
+		// endpoint is recovered on the next setup packet
+		hw.stall(0, IN)
+	}
+
 	return
 }
 
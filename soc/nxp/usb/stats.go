@@ -0,0 +1,52 @@
+// NXP USBOH3USBO2 / USBPHY driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"sync"
+)
+
+// EndpointStats represents the transfer statistics accumulated by an
+// endpoint.
+type EndpointStats struct {
+	// Bytes transferred
+	Bytes uint64
+	// Transfer descriptors (dTDs) retired
+	DTDs uint64
+	// STALL handshakes issued
+	Stalls uint64
+	// Transfer errors (dTD error status or completion timeout)
+	Errors uint64
+}
+
+type endpointStats struct {
+	sync.Mutex
+	ep [MAX_ENDPOINTS][2]EndpointStats
+}
+
+func (s *endpointStats) update(n int, dir int, fn func(*EndpointStats)) {
+	s.Lock()
+	defer s.Unlock()
+
+	fn(&s.ep[n][dir])
+}
+
+// Stats returns the transfer statistics accumulated by an endpoint, with the
+// argument number and direction (IN or OUT).
+func (hw *USB) Stats(n int, dir int) EndpointStats {
+	if n < 0 || n >= MAX_ENDPOINTS || dir < OUT || dir > IN {
+		return EndpointStats{}
+	}
+
+	hw.stats.Lock()
+	defer hw.stats.Unlock()
+
+	return hw.stats.ep[n][dir]
+}
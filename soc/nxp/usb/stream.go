@@ -0,0 +1,192 @@
+// USB device mode support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"io"
+)
+
+// STREAM_BUFFERS is the number of transfer buffers used by endpoint readers
+// and writers, allowing a transfer to be queued while the previous one is
+// processed.
+const STREAM_BUFFERS = 2
+
+// streamResult represents a completed stream transfer.
+type streamResult struct {
+	buf []byte
+	err error
+}
+
+// endpointReader implements io.Reader over back-to-back OUT endpoint
+// transfers.
+type endpointReader struct {
+	hw *USB
+	n  int
+
+	// completed transfers
+	queue chan streamResult
+	// buffers available for reception
+	free chan []byte
+
+	// buffer being read
+	cur []byte
+	// data received but not yet read
+	buf []byte
+}
+
+// endpointWriter implements io.Writer over back-to-back IN endpoint
+// transfers.
+type endpointWriter struct {
+	hw *USB
+	n  int
+
+	// buffers pending transmission
+	queue chan []byte
+	// buffers available for transmission
+	free chan []byte
+	// transmission errors
+	errs chan error
+}
+
+func streamBuffers() (free chan []byte) {
+	free = make(chan []byte, STREAM_BUFFERS)
+
+	for i := 0; i < STREAM_BUFFERS; i++ {
+		free <- make([]byte, DTD_PAGES*DTD_PAGE_SIZE)
+	}
+
+	return
+}
+
+// EndpointReader returns an io.Reader which receives data from the host
+// through the argument OUT endpoint.
+//
+// Transfers are issued back-to-back, in a dedicated goroutine, with a
+// transfer being queued while data from the previous one is read. Each Read()
+// consumes data left over from previous transfers, when none is available it
+// blocks until the host sends data or the endpoints are stopped (e.g. on host
+// reset or configuration change).
+//
+// The endpoint must be part of the active configuration and its descriptor
+// must not have a Function set, as the handler would compete for the same
+// transfers.
+func (hw *USB) EndpointReader(n int) io.Reader {
+	return &endpointReader{hw: hw, n: n}
+}
+
+// EndpointWriter returns an io.Writer which transmits data to the host
+// through the argument IN endpoint.
+//
+// Transfers are issued back-to-back, in a dedicated goroutine, with a
+// transfer being queued while the previous one is transmitted. Each Write()
+// returns once its data is queued and blocks while all transfer buffers are
+// waiting for the host to read them, providing back-pressure to the caller.
+// Transfer errors (e.g. on endpoint cancellation) are returned by the next
+// Write().
+//
+// The endpoint must be part of the active configuration and its descriptor
+// must not have a Function set, as the handler would compete for the same
+// transfers.
+func (hw *USB) EndpointWriter(n int) io.Writer {
+	return &endpointWriter{hw: hw, n: n}
+}
+
+func (r *endpointReader) receive(queue chan streamResult, free chan []byte) {
+	for buf := range free {
+		buf, err := r.hw.rx(r.n, false, buf)
+		queue <- streamResult{buf: buf, err: err}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read receives data from the host.
+func (r *endpointReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return
+	}
+
+	// zero length packets carry no data and are skipped
+	for len(r.buf) == 0 {
+		if r.cur != nil {
+			r.free <- r.cur[:cap(r.cur)]
+			r.cur = nil
+		}
+
+		if r.queue == nil {
+			r.queue = make(chan streamResult, STREAM_BUFFERS)
+			r.free = streamBuffers()
+
+			go r.receive(r.queue, r.free)
+		}
+
+		res := <-r.queue
+
+		if res.err != nil {
+			// the receiver has returned, restart on next Read()
+			r.queue = nil
+			return 0, res.err
+		}
+
+		r.cur = res.buf
+		r.buf = res.buf
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return
+}
+
+func (w *endpointWriter) transmit(queue chan []byte, free chan []byte, errs chan error) {
+	for buf := range queue {
+		err := w.hw.tx(w.n, false, buf)
+		free <- buf[:cap(buf)]
+
+		if err != nil {
+			errs <- err
+			return
+		}
+	}
+}
+
+// Write transmits data to the host.
+func (w *endpointWriter) Write(p []byte) (n int, err error) {
+	if w.queue == nil {
+		w.queue = make(chan []byte, STREAM_BUFFERS)
+		w.free = streamBuffers()
+		w.errs = make(chan error, 1)
+
+		go w.transmit(w.queue, w.free, w.errs)
+	}
+
+	// loop condition to account for zero length writes
+	for add := true; add; add = len(p) > 0 {
+		var buf []byte
+
+		select {
+		case err = <-w.errs:
+			// the transmitter has returned, restart on next Write()
+			w.queue = nil
+			return
+		case buf = <-w.free:
+		}
+
+		c := copy(buf, p)
+		w.queue <- buf[:c]
+
+		p = p[c:]
+		n += c
+	}
+
+	return
+}
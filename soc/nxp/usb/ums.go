@@ -0,0 +1,544 @@
+// USB mass storage support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Mass Storage interface constants
+const (
+	// p11, 4.3 Data Transport Protocol, USB Mass Storage Class 1.0
+	MASS_STORAGE_CLASS     = 0x08
+	SCSI_TRANSPARENT       = 0x06
+	BULK_ONLY_TRANSPORT    = 0x50
+	CBW_FLAGS_DIRECTION    = 7
+	INQUIRY_LENGTH         = 36
+	REQUEST_SENSE_LENGTH   = 18
+	READ_CAPACITY_LENGTH   = 8
+	FORMAT_CAPACITY_LENGTH = 12
+
+	// maximum size of each data IN phase transfer
+	MASS_STORAGE_TRANSFER_SIZE = 64 * 1024
+)
+
+// SCSI commands
+// (p10, Table 10 - Commands for direct access block devices, SBC-2).
+const (
+	TEST_UNIT_READY              = 0x00
+	REQUEST_SENSE                = 0x03
+	INQUIRY                      = 0x12
+	MODE_SENSE_6                 = 0x1a
+	START_STOP_UNIT              = 0x1b
+	PREVENT_ALLOW_MEDIUM_REMOVAL = 0x1e
+	READ_FORMAT_CAPACITIES       = 0x23
+	READ_CAPACITY_10             = 0x25
+	READ_10                      = 0x28
+	WRITE_10                     = 0x2a
+	VERIFY_10                    = 0x2f
+	SYNCHRONIZE_CACHE_10         = 0x35
+)
+
+// SCSI sense keys and additional sense codes
+// (p37, 4.5.6 Sense key and sense code definitions, SPC-3).
+const (
+	SENSE_NO_SENSE        = 0x00
+	SENSE_MEDIUM_ERROR    = 0x03
+	SENSE_ILLEGAL_REQUEST = 0x05
+
+	ASC_INVALID_COMMAND  = 0x20
+	ASC_LBA_OUT_OF_RANGE = 0x21
+	ASC_INVALID_FIELD    = 0x24
+	ASC_UNRECOVERED_READ = 0x11
+	ASC_WRITE_FAULT      = 0x03
+)
+
+// BlockDevice represents a block addressable storage device.
+type BlockDevice interface {
+	// ReadBlocks reads len(buf) bytes starting from the argument logical
+	// block address.
+	ReadBlocks(lba int, buf []byte) error
+	// WriteBlocks writes len(buf) bytes starting from the argument
+	// logical block address.
+	WriteBlocks(lba int, buf []byte) error
+}
+
+// MassStorage implements a USB Mass Storage Bulk-Only Transport function,
+// supporting the SCSI transparent command set, backed by a block device.
+//
+// The Setup, Rx and Tx methods are meant to be used respectively as device
+// setup function and bulk OUT and IN endpoint functions, see Interface().
+type MassStorage struct {
+	// Backing block device
+	Device BlockDevice
+	// Block size in bytes
+	BlockSize int
+	// Number of blocks
+	Blocks int64
+
+	// INQUIRY identification strings
+	Vendor   string
+	Product  string
+	Revision string
+
+	// device instance, for endpoint cancellation
+	dev *Device
+
+	// pending CBW for data OUT phase
+	cbw *CBW
+	// data OUT phase bytes already written
+	written int
+	// serializes data OUT phase state updates
+	pending sync.Mutex
+
+	// sense data for REQUEST SENSE
+	sense [3]byte
+	// IN endpoint transmission queue
+	send chan []byte
+}
+
+// Init initializes the mass storage function for the argument device.
+func (ms *MassStorage) Init(dev *Device) {
+	if dev == nil || ms.Device == nil || ms.BlockSize == 0 {
+		panic("invalid mass storage instance")
+	}
+
+	ms.dev = dev
+	ms.send = make(chan []byte, 2)
+}
+
+// Interface returns a Bulk-Only Transport interface descriptor, with bulk IN
+// and OUT endpoints, served by the mass storage function.
+func (ms *MassStorage) Interface(in uint8, out uint8) (iface *InterfaceDescriptor) {
+	iface = &InterfaceDescriptor{}
+	iface.SetDefaults()
+
+	iface.NumEndpoints = 2
+	iface.InterfaceClass = MASS_STORAGE_CLASS
+	iface.InterfaceSubClass = SCSI_TRANSPARENT
+	iface.InterfaceProtocol = BULK_ONLY_TRANSPORT
+
+	ep1IN := &EndpointDescriptor{}
+	ep1IN.SetDefaults()
+	ep1IN.EndpointAddress = 0x80 | in
+	ep1IN.Attributes = BULK
+	// data IN phases can span multiple transfers
+	ep1IN.Zero = false
+	ep1IN.Function = ms.Tx
+
+	ep1OUT := &EndpointDescriptor{}
+	ep1OUT.SetDefaults()
+	ep1OUT.EndpointAddress = out
+	ep1OUT.Attributes = BULK
+	ep1OUT.Zero = false
+	ep1OUT.Function = ms.Rx
+
+	iface.AddEndpoint(ep1IN)
+	iface.AddEndpoint(ep1OUT)
+
+	return
+}
+
+// Setup handles mass storage class-specific requests
+// (p7, 3 Functional Characteristics, USB Mass Storage Class 1.0).
+func (ms *MassStorage) Setup(setup *SetupData) (res SetupResult, err error) {
+	// class-specific interface requests only
+	if setup.RequestType&0b01111111 != 0b00100001 {
+		return
+	}
+
+	switch setup.Request {
+	case BULK_ONLY_MASS_STORAGE_RESET:
+		ms.pending.Lock()
+		ms.cbw = nil
+		ms.pending.Unlock()
+
+		res.Ack = true
+		res.Handled = true
+	case GET_MAX_LUN:
+		res.In = []byte{0x00}
+		res.Handled = true
+	}
+
+	return
+}
+
+// Tx is the mass storage bulk IN endpoint function.
+func (ms *MassStorage) Tx(_ []byte, lastErr error) (in []byte, err error) {
+	select {
+	case in = <-ms.send:
+	case <-ms.dev.done():
+	}
+
+	return
+}
+
+// Rx is the mass storage bulk OUT endpoint function.
+func (ms *MassStorage) Rx(out []byte, lastErr error) (res []byte, err error) {
+	ms.pending.Lock()
+	cbw, written := ms.cbw, ms.written
+	ms.pending.Unlock()
+
+	if cbw != nil {
+		return ms.write(cbw, written, out)
+	}
+
+	cbw = &CBW{}
+
+	if len(out) != CBW_LENGTH {
+		return nil, fmt.Errorf("invalid CBW size %d != %d", len(out), CBW_LENGTH)
+	}
+
+	if err = binary.Read(bytes.NewReader(out), binary.LittleEndian, cbw); err != nil {
+		return
+	}
+
+	if cbw.Signature != CBW_SIGNATURE {
+		return nil, fmt.Errorf("invalid CBW signature %x", cbw.Signature)
+	}
+
+	if cbw.Length < 1 || cbw.Length > CBW_CB_MAX_LENGTH {
+		return nil, fmt.Errorf("invalid CBW command length %d", cbw.Length)
+	}
+
+	return ms.handleCDB(cbw)
+}
+
+// queue schedules data for transmission on the IN endpoint, it returns false
+// when the endpoints are stopped before the data can be queued.
+func (ms *MassStorage) queue(data []byte) bool {
+	select {
+	case ms.send <- data:
+		return true
+	case <-ms.dev.done():
+		return false
+	}
+}
+
+func (ms *MassStorage) status(cbw *CBW, sent int, status uint8) []byte {
+	csw := &CSW{}
+	csw.SetDefaults()
+
+	csw.Tag = cbw.Tag
+	csw.Status = status
+
+	if sent < int(cbw.DataTransferLength) {
+		csw.DataResidue = cbw.DataTransferLength - uint32(sent)
+	}
+
+	return csw.Bytes()
+}
+
+func (ms *MassStorage) reply(cbw *CBW, data []byte, status uint8) {
+	if len(data) > int(cbw.DataTransferLength) {
+		data = data[0:cbw.DataTransferLength]
+	}
+
+	if len(data) > 0 && !ms.queue(data) {
+		return
+	}
+
+	ms.queue(ms.status(cbw, len(data), status))
+}
+
+// pad transmits zero filled data, in chunks of at most
+// MASS_STORAGE_TRANSFER_SIZE bytes, to complete a data IN phase.
+func (ms *MassStorage) pad(size int) {
+	var zero []byte
+
+	for size > 0 {
+		n := size
+
+		if n > MASS_STORAGE_TRANSFER_SIZE {
+			n = MASS_STORAGE_TRANSFER_SIZE
+		}
+
+		if len(zero) < n {
+			zero = make([]byte, n)
+		}
+
+		if !ms.queue(zero[:n]) {
+			return
+		}
+
+		size -= n
+	}
+}
+
+// fail terminates a command with a failed status, after sent bytes of its
+// data IN phase have been transmitted.
+//
+// For data OUT phases the host is expected to be notified of the failure with
+// an OUT endpoint stall (6.7.3 Ho - Host expects to send data to the
+// device, USB Mass Storage Class Bulk-Only Transport 1.0), see ErrStall.
+func (ms *MassStorage) fail(cbw *CBW, sent int, key byte, asc byte) {
+	ms.sense = [3]byte{key, asc, 0}
+
+	// pad data IN phase to the length expected by the host
+	if cbw.Flags>>CBW_FLAGS_DIRECTION == 1 {
+		ms.pad(int(cbw.DataTransferLength) - sent)
+	}
+
+	ms.queue(ms.status(cbw, sent, CSW_STATUS_COMMAND_FAILED))
+}
+
+func (ms *MassStorage) inquiry() []byte {
+	data := make([]byte, INQUIRY_LENGTH)
+
+	// direct access block device
+	data[0] = 0x00
+	// removable medium
+	data[1] = 0x80
+	// SPC-2
+	data[2] = 0x04
+	// response data format
+	data[3] = 0x02
+	// additional length
+	data[4] = INQUIRY_LENGTH - 5
+
+	copy(data[8:16], fmt.Sprintf("%-8s", ms.Vendor))
+	copy(data[16:32], fmt.Sprintf("%-16s", ms.Product))
+	copy(data[32:36], fmt.Sprintf("%-4s", ms.Revision))
+
+	return data
+}
+
+func (ms *MassStorage) requestSense() []byte {
+	data := make([]byte, REQUEST_SENSE_LENGTH)
+
+	// current errors, fixed format
+	data[0] = 0x70
+	data[2] = ms.sense[0]
+	// additional sense length
+	data[7] = REQUEST_SENSE_LENGTH - 8
+	data[12] = ms.sense[1]
+	data[13] = ms.sense[2]
+
+	ms.sense = [3]byte{}
+
+	return data
+}
+
+func (ms *MassStorage) readCapacity() []byte {
+	data := make([]byte, READ_CAPACITY_LENGTH)
+
+	binary.BigEndian.PutUint32(data[0:], uint32(ms.Blocks-1))
+	binary.BigEndian.PutUint32(data[4:], uint32(ms.BlockSize))
+
+	return data
+}
+
+func (ms *MassStorage) readFormatCapacities() []byte {
+	data := make([]byte, FORMAT_CAPACITY_LENGTH)
+
+	// capacity list length
+	data[3] = 8
+	binary.BigEndian.PutUint32(data[4:], uint32(ms.Blocks))
+	// formatted media
+	data[8] = 0x02
+	// 24-bit block length
+	data[9] = byte(ms.BlockSize >> 16)
+	data[10] = byte(ms.BlockSize >> 8)
+	data[11] = byte(ms.BlockSize)
+
+	return data
+}
+
+// blocks returns the logical block address and transfer length of a READ(10)
+// or WRITE(10) command, validating them against the device capacity.
+func (ms *MassStorage) blocks(cbw *CBW) (lba int, size int, err error) {
+	start := int64(binary.BigEndian.Uint32(cbw.CommandBlock[2:]))
+	n := int64(binary.BigEndian.Uint16(cbw.CommandBlock[7:]))
+
+	if start+n > ms.Blocks || start+n > math.MaxInt {
+		return 0, 0, errors.New("block address out of range")
+	}
+
+	return int(start), int(n) * ms.BlockSize, nil
+}
+
+// chunk returns the size of the next transfer of a data phase with the
+// argument remaining size, bounded to MASS_STORAGE_TRANSFER_SIZE bytes
+// rounded to whole blocks.
+func (ms *MassStorage) chunk(size int) int {
+	n := MASS_STORAGE_TRANSFER_SIZE - MASS_STORAGE_TRANSFER_SIZE%ms.BlockSize
+
+	if n == 0 {
+		n = ms.BlockSize
+	}
+
+	if size < n {
+		n = size
+	}
+
+	return n
+}
+
+// read transmits the data IN phase of a READ(10) command, in chunks of at
+// most MASS_STORAGE_TRANSFER_SIZE bytes to bound memory usage.
+func (ms *MassStorage) read(cbw *CBW, lba int, size int) {
+	var sent int
+
+	if size > int(cbw.DataTransferLength) {
+		size = int(cbw.DataTransferLength)
+	}
+
+	for sent < size {
+		n := ms.chunk(size - sent)
+
+		// only whole blocks can be read from the device
+		data := make([]byte, (n+ms.BlockSize-1)/ms.BlockSize*ms.BlockSize)
+
+		if err := ms.Device.ReadBlocks(lba+sent/ms.BlockSize, data); err != nil {
+			ms.fail(cbw, sent, SENSE_MEDIUM_ERROR, ASC_UNRECOVERED_READ)
+			return
+		}
+
+		if !ms.queue(data[:n]) {
+			return
+		}
+
+		sent += n
+	}
+
+	ms.queue(ms.status(cbw, sent, CSW_STATUS_COMMAND_PASSED))
+}
+
+func (ms *MassStorage) handleCDB(cbw *CBW) (res []byte, err error) {
+	switch op := cbw.CommandBlock[0]; op {
+	case TEST_UNIT_READY, PREVENT_ALLOW_MEDIUM_REMOVAL, START_STOP_UNIT, VERIFY_10, SYNCHRONIZE_CACHE_10:
+		ms.reply(cbw, nil, CSW_STATUS_COMMAND_PASSED)
+	case INQUIRY:
+		ms.reply(cbw, ms.inquiry(), CSW_STATUS_COMMAND_PASSED)
+	case REQUEST_SENSE:
+		ms.reply(cbw, ms.requestSense(), CSW_STATUS_COMMAND_PASSED)
+	case MODE_SENSE_6:
+		// no mode pages, medium not write protected
+		ms.reply(cbw, []byte{0x03, 0x00, 0x00, 0x00}, CSW_STATUS_COMMAND_PASSED)
+	case READ_FORMAT_CAPACITIES:
+		ms.reply(cbw, ms.readFormatCapacities(), CSW_STATUS_COMMAND_PASSED)
+	case READ_CAPACITY_10:
+		ms.reply(cbw, ms.readCapacity(), CSW_STATUS_COMMAND_PASSED)
+	case READ_10:
+		lba, size, e := ms.blocks(cbw)
+
+		if e != nil {
+			ms.fail(cbw, 0, SENSE_ILLEGAL_REQUEST, ASC_LBA_OUT_OF_RANGE)
+			break
+		}
+
+		ms.read(cbw, lba, size)
+	case WRITE_10:
+		_, size, e := ms.blocks(cbw)
+
+		if e != nil {
+			ms.fail(cbw, 0, SENSE_ILLEGAL_REQUEST, ASC_LBA_OUT_OF_RANGE)
+
+			// reject the data OUT phase, if any
+			if cbw.DataTransferLength > 0 {
+				err = ErrStall
+			}
+
+			break
+		}
+
+		if size > int(cbw.DataTransferLength) {
+			// the host does not intend to transfer the whole data
+			ms.fail(cbw, 0, SENSE_ILLEGAL_REQUEST, ASC_INVALID_FIELD)
+
+			if cbw.DataTransferLength > 0 {
+				err = ErrStall
+			}
+
+			break
+		}
+
+		if size == 0 {
+			ms.reply(cbw, nil, CSW_STATUS_COMMAND_PASSED)
+			break
+		}
+
+		ms.pending.Lock()
+		ms.cbw = cbw
+		ms.written = 0
+		ms.pending.Unlock()
+
+		// the next OUT transfer carries the first data phase chunk
+		res = make([]byte, ms.chunk(size))
+	default:
+		ms.fail(cbw, 0, SENSE_ILLEGAL_REQUEST, ASC_INVALID_COMMAND)
+		err = fmt.Errorf("unsupported SCSI command %#x", op)
+	}
+
+	return
+}
+
+// update replaces the pending data OUT phase state, unless it has been
+// reset in the meantime (see Setup()), and reports whether it was replaced.
+func (ms *MassStorage) update(cur *CBW, cbw *CBW, written int) bool {
+	ms.pending.Lock()
+	defer ms.pending.Unlock()
+
+	if ms.cbw != cur {
+		return false
+	}
+
+	ms.cbw = cbw
+	ms.written = written
+
+	return true
+}
+
+// write handles a data OUT phase chunk of a WRITE(10) command, in chunks of
+// at most MASS_STORAGE_TRANSFER_SIZE bytes to bound memory usage.
+func (ms *MassStorage) write(cbw *CBW, written int, buf []byte) (res []byte, err error) {
+	lba, size, _ := ms.blocks(cbw)
+	n := ms.chunk(size - written)
+
+	if len(buf) != n {
+		ms.update(cbw, nil, 0)
+		ms.fail(cbw, written, SENSE_ILLEGAL_REQUEST, ASC_INVALID_COMMAND)
+		return nil, fmt.Errorf("invalid write size %d != %d", len(buf), n)
+	}
+
+	if err = ms.Device.WriteBlocks(lba+written/ms.BlockSize, buf); err != nil {
+		ms.update(cbw, nil, 0)
+		ms.fail(cbw, written, SENSE_MEDIUM_ERROR, ASC_WRITE_FAULT)
+
+		// reject the rest of the data OUT phase, if any
+		if written+n < size {
+			err = ErrStall
+		}
+
+		return
+	}
+
+	written += n
+
+	if written < size {
+		// the next OUT transfer carries the following data phase chunk
+		if ms.update(cbw, cbw, written) {
+			res = make([]byte, ms.chunk(size-written))
+		}
+
+		return
+	}
+
+	if ms.update(cbw, nil, 0) {
+		ms.queue(ms.status(cbw, written, CSW_STATUS_COMMAND_PASSED))
+	}
+
+	return
+}
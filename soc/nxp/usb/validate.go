@@ -0,0 +1,142 @@
+// USB descriptor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usb
+
+import (
+	"errors"
+	"fmt"
+)
+
+func (d *Device) validateString(index uint8, name string) error {
+	if index == 0 {
+		return nil
+	}
+
+	if len(d.Strings) == 0 {
+		return fmt.Errorf("%s string index %d references missing language codes", name, index)
+	}
+
+	if int(index) >= len(d.Strings) {
+		return fmt.Errorf("%s string index %d is invalid", name, index)
+	}
+
+	return nil
+}
+
+func (d *Device) validateInterface(iface *InterfaceDescriptor, addrs map[uint8]uint8) (err error) {
+	name := fmt.Sprintf("interface %d.%d", iface.InterfaceNumber, iface.AlternateSetting)
+
+	if iface.Length != INTERFACE_LENGTH || iface.DescriptorType != INTERFACE {
+		return fmt.Errorf("%s has invalid length or type", name)
+	}
+
+	if int(iface.NumEndpoints) != len(iface.Endpoints) {
+		return fmt.Errorf("%s endpoint count mismatch (%d != %d)", name, iface.NumEndpoints, len(iface.Endpoints))
+	}
+
+	if err = d.validateString(iface.Interface, name); err != nil {
+		return
+	}
+
+	if iad := iface.IAD; iad != nil {
+		if iad.Length != INTERFACE_ASSOCIATION_LENGTH || iad.DescriptorType != INTERFACE_ASSOCIATION {
+			return fmt.Errorf("%s association descriptor has invalid length or type", name)
+		}
+
+		if err = d.validateString(iad.Function, name+" association"); err != nil {
+			return
+		}
+	}
+
+	for _, ep := range iface.Endpoints {
+		if ep.Length != ENDPOINT_LENGTH || ep.DescriptorType != ENDPOINT {
+			return fmt.Errorf("%s endpoint %#x has invalid length or type", name, ep.EndpointAddress)
+		}
+
+		if ep.Number() == 0 {
+			return fmt.Errorf("%s endpoint %#x collides with control endpoint", name, ep.EndpointAddress)
+		}
+
+		if ep.Number() >= MAX_ENDPOINTS {
+			return fmt.Errorf("%s endpoint %#x exceeds maximum endpoint number", name, ep.EndpointAddress)
+		}
+
+		// alternate settings of the same interface can reuse
+		// endpoint addresses
+		if n, ok := addrs[ep.EndpointAddress]; ok && n != iface.InterfaceNumber {
+			return fmt.Errorf("%s endpoint %#x is already used by interface %d", name, ep.EndpointAddress, n)
+		}
+
+		addrs[ep.EndpointAddress] = iface.InterfaceNumber
+	}
+
+	return
+}
+
+// Validate verifies the consistency of the device descriptor hierarchy,
+// checking descriptor lengths and types, endpoint numbers and string
+// descriptor indices. It is meant to be invoked before Start() to detect
+// malformed descriptors that would otherwise surface as enumeration failures.
+func (d *Device) Validate() (err error) {
+	desc := d.Descriptor
+
+	if desc == nil {
+		return errors.New("missing device descriptor")
+	}
+
+	if desc.Length != DEVICE_LENGTH || desc.DescriptorType != DEVICE {
+		return errors.New("device descriptor has invalid length or type")
+	}
+
+	if int(desc.NumConfigurations) != len(d.Configurations) {
+		return fmt.Errorf("device configuration count mismatch (%d != %d)", desc.NumConfigurations, len(d.Configurations))
+	}
+
+	if err = d.validateString(desc.Manufacturer, "manufacturer"); err != nil {
+		return
+	}
+
+	if err = d.validateString(desc.Product, "product"); err != nil {
+		return
+	}
+
+	if err = d.validateString(desc.SerialNumber, "serial number"); err != nil {
+		return
+	}
+
+	for _, conf := range d.Configurations {
+		name := fmt.Sprintf("configuration %d", conf.ConfigurationValue)
+
+		if conf.Length != CONFIGURATION_LENGTH || conf.DescriptorType != CONFIGURATION {
+			return fmt.Errorf("%s has invalid length or type", name)
+		}
+
+		if err = d.validateString(conf.Configuration, name); err != nil {
+			return
+		}
+
+		ifaces := make(map[uint8]bool)
+		addrs := make(map[uint8]uint8)
+
+		for _, iface := range conf.Interfaces {
+			if err = d.validateInterface(iface, addrs); err != nil {
+				return fmt.Errorf("%s %v", name, err)
+			}
+
+			ifaces[iface.InterfaceNumber] = true
+		}
+
+		if int(conf.NumInterfaces) != len(ifaces) {
+			return fmt.Errorf("%s interface count mismatch (%d != %d)", name, conf.NumInterfaces, len(ifaces))
+		}
+	}
+
+	return
+}
@@ -31,6 +31,10 @@ const (
 	DMASEL_ADMA2 = 0b10
 
 	ADMA_BD_MAX_LENGTH = 65532
+
+	// ADMA_SEGMENT_SIZE is the maximum size of each DMA buffer composing a
+	// scatter-gather transfer.
+	ADMA_SEGMENT_SIZE = 512 * 1024
 )
 
 // ADMABufferDescriptor implements p3964 58.4.2.4.1 ADMA Concept and Descriptor Format, IMX6ULLRM.
@@ -67,6 +71,26 @@ func (bd *ADMABufferDescriptor) Init(addr uint, size int) {
 	}
 }
 
+// Append adds a buffer to an ADMA2 buffer descriptor table, allowing a single
+// transfer to span multiple non-contiguous buffers (scatter-gather).
+func (bd *ADMABufferDescriptor) Append(addr uint, size int) {
+	if bd.Attribute == 0 {
+		bd.Init(addr, size)
+		return
+	}
+
+	b := bd
+
+	for b.next != nil {
+		b = b.next
+	}
+
+	// continue with the appended descriptors
+	b.Attribute &^= 1 << ATTR_END
+	b.next = &ADMABufferDescriptor{}
+	b.next.Init(addr, size)
+}
+
 // Bytes converts the descriptor structure to byte array format.
 func (bd *ADMABufferDescriptor) Bytes() []byte {
 	buf := new(bytes.Buffer)
@@ -0,0 +1,152 @@
+// NXP Ultra Secured Digital Host Controller (uSDHC) driver
+// https://github.com/usbarmory/tamago
+//
+// IP: https://www.mobiveil.com/esdhc/
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usdhc
+
+import (
+	"strings"
+	"time"
+)
+
+// CID registers
+const (
+	// p199, 5.2 CID register, SD-PL-7.10
+	SD_CID_MID = 120 + CID_RSP_OFF
+	SD_CID_OID = 104 + CID_RSP_OFF
+	SD_CID_PNM = 64 + CID_RSP_OFF
+	SD_CID_PRV = 56 + CID_RSP_OFF
+	SD_CID_PSN = 24 + CID_RSP_OFF
+	SD_CID_MDT = 8 + CID_RSP_OFF
+
+	// p182, 7.2 CID register, JESD84-B51
+	MMC_CID_MID = 120 + CID_RSP_OFF
+	MMC_CID_OID = 104 + CID_RSP_OFF
+	MMC_CID_PNM = 56 + CID_RSP_OFF
+	MMC_CID_PRV = 48 + CID_RSP_OFF
+	MMC_CID_PSN = 16 + CID_RSP_OFF
+	MMC_CID_MDT = 8 + CID_RSP_OFF
+)
+
+// CardID represents the card identification (CID) register.
+type CardID struct {
+	// Manufacturer ID
+	ManufacturerID uint8
+	// OEM/Application ID
+	OEM string
+	// Product name
+	ProductName string
+	// Product revision (BCD, major.minor)
+	Revision uint8
+	// Product serial number
+	Serial uint32
+	// Manufacturing date (month precision)
+	Date time.Time
+}
+
+// CardSpecificData represents the card specific data (CSD) register.
+type CardSpecificData struct {
+	// CSD structure (CSD_STRUCTURE)
+	Structure int
+	// Maximum data transfer rate (TRAN_SPEED)
+	TranSpeed uint8
+	// Block size
+	BlockSize int
+	// Capacity in blocks
+	Blocks int
+	// Capacity in bytes
+	Capacity int64
+}
+
+// field returns the value of a bit field from a response register copy.
+func field(buf [16]byte, pos int, width int) (val uint64) {
+	for i := 0; i < width; i++ {
+		b := pos + i
+
+		if (buf[b/8]>>(b%8))&1 == 1 {
+			val |= 1 << i
+		}
+	}
+
+	return
+}
+
+// ascii returns the string encoded in a bit field from a response register
+// copy.
+func ascii(buf [16]byte, pos int, size int) string {
+	var s []byte
+
+	for i := size - 1; i >= 0; i-- {
+		s = append(s, byte(field(buf, pos+i*8, 8)))
+	}
+
+	return strings.TrimRight(string(s), " \x00")
+}
+
+// CID returns the parsed card identification register of the detected card.
+func (hw *USDHC) CID() (cid CardID) {
+	var year int
+	var month int
+
+	buf := hw.card.CID
+
+	switch {
+	case hw.card.SD:
+		cid.ManufacturerID = uint8(field(buf, SD_CID_MID, 8))
+		cid.OEM = ascii(buf, SD_CID_OID, 2)
+		cid.ProductName = ascii(buf, SD_CID_PNM, 5)
+		cid.Revision = uint8(field(buf, SD_CID_PRV, 8))
+		cid.Serial = uint32(field(buf, SD_CID_PSN, 32))
+
+		// p201, Manufacturing date (MDT), SD-PL-7.10
+		year = 2000 + int(field(buf, SD_CID_MDT+4, 8))
+		month = int(field(buf, SD_CID_MDT, 4))
+	case hw.card.MMC:
+		cid.ManufacturerID = uint8(field(buf, MMC_CID_MID, 8))
+		cid.OEM = ascii(buf, MMC_CID_OID, 1)
+		cid.ProductName = ascii(buf, MMC_CID_PNM, 6)
+		cid.Revision = uint8(field(buf, MMC_CID_PRV, 8))
+		cid.Serial = uint32(field(buf, MMC_CID_PSN, 32))
+
+		// p183, MDT [15:8], JESD84-B51
+		year = 1997 + int(field(buf, MMC_CID_MDT, 4))
+		month = int(field(buf, MMC_CID_MDT+4, 4))
+
+		// devices compliant with revision 4.41 or later encode years
+		// starting from 2013, which are assumed for earlier values
+		if year < 2010 {
+			year += 16
+		}
+	default:
+		return
+	}
+
+	cid.Date = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+
+	return
+}
+
+// CSD returns the parsed card specific data register of the detected card.
+func (hw *USDHC) CSD() (csd CardSpecificData) {
+	buf := hw.card.CSD
+
+	if !hw.card.SD && !hw.card.MMC {
+		return
+	}
+
+	// CSD_STRUCTURE and TRAN_SPEED share the same location on SD and MMC
+	csd.Structure = int(field(buf, SD_CSD_STRUCTURE, 2))
+	csd.TranSpeed = uint8(field(buf, MMC_CSD_TRAN_SPEED, 8))
+	csd.BlockSize = hw.card.BlockSize
+	csd.Blocks = hw.card.Blocks
+	csd.Capacity = int64(hw.card.BlockSize) * int64(hw.card.Blocks)
+
+	return
+}
@@ -47,6 +47,8 @@ const (
 
 	// SEND_CSD response contains CSD[127:8],
 	CSD_RSP_OFF = -8
+	// ALL_SEND_CID response contains CID[127:8],
+	CID_RSP_OFF = -8
 
 	DEFAULT_CMD_TIMEOUT = 10 * time.Millisecond
 )
@@ -93,10 +95,22 @@ var cmds = map[uint32]cmdParams{
 	18: {READ, RSP_48, true, true},
 	// CMD19 - send tuning block command, ignore responses
 	19: {READ, RSP_48, true, true},
+	// MMC: CMD21 - SEND_TUNING_BLOCK - send tuning block command, ignore responses
+	21: {READ, RSP_48, true, true},
 	// CMD23 - SET_BLOCK_COUNT - define read/write block count
 	23: {READ, RSP_48, true, true},
 	// CMD25 - WRITE_MULTIPLE_BLOCK - write consecutive blocks
 	25: {WRITE, RSP_48, true, true},
+	// SD: CMD32 - ERASE_WR_BLK_START - set first block to erase
+	32: {READ, RSP_48, true, true},
+	// SD: CMD33 - ERASE_WR_BLK_END - set last block to erase
+	33: {READ, RSP_48, true, true},
+	// MMC: CMD35 - ERASE_GROUP_START - set first block to erase
+	35: {READ, RSP_48, true, true},
+	// MMC: CMD36 - ERASE_GROUP_END - set last block to erase
+	36: {READ, RSP_48, true, true},
+	// CMD38 - ERASE - erase selected blocks
+	38: {WRITE, RSP_48_CHECK_BUSY, true, true},
 	// SD: ACMD41 - SD_SEND_OP_COND - read capacity information
 	41: {READ, RSP_48, false, false},
 	// SD: CMD55 - APP_CMD - next command is application specific
@@ -41,6 +41,9 @@ const (
 
 	ACCESS_WRITE_BYTE = 0b11
 
+	// 6.6.10 TRIM, JESD84-B51
+	MMC_TRIM_ARG = 0x00000001
+
 	// p184 7.3 CSD register, JESD84-B51
 	MMC_CSD_SPEC_VERS   = 122 + CSD_RSP_OFF
 	MMC_CSD_TRAN_SPEED  = 96 + CSD_RSP_OFF
@@ -242,6 +245,10 @@ func (hw *USDHC) initMMC() (err error) {
 		return
 	}
 
+	for i := 0; i < len(hw.card.CSD); i += 4 {
+		binary.LittleEndian.PutUint32(hw.card.CSD[i:], hw.rsp(i/4))
+	}
+
 	// block count multiplier
 	c_size_mult := hw.rspVal(MMC_CSD_C_SIZE_MULT, 0b111)
 	// block count
@@ -341,9 +348,10 @@ func (hw *USDHC) initMMC() (err error) {
 	hw.SetClock(hw.Index, root_clk, 0)
 	hw.setFreq(DVS_HS, clk)
 
-	if tune {
-		// FIXME: Use fixed sampling clock as eMMC tuning fails for unknown reasons.
-		// err = hw.executeTuningMMC()
+	if tune && hw.executeTuningMMC() != nil {
+		// fall back to fixed sampling clock, which is known to work
+		// reliably on most eMMC parts
+		hw.resetTuning()
 	}
 
 	hw.card.DDR = ddr
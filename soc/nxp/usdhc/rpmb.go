@@ -0,0 +1,231 @@
+// NXP Ultra Secured Digital Host Controller (uSDHC) driver
+// https://github.com/usbarmory/tamago
+//
+// IP: https://www.mobiveil.com/esdhc/
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usdhc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// RPMB constants
+const (
+	// p105, Table 18 - Data Frame Files for RPMB, JESD84-B51
+	RPMB_FRAME_SIZE = 512
+	RPMB_DATA_SIZE  = 256
+	RPMB_KEY_SIZE   = 32
+
+	rpmbKeyMAC   = 196
+	rpmbData     = 228
+	rpmbNonce    = 484
+	rpmbCounter  = 500
+	rpmbAddress  = 504
+	rpmbBlocks   = 506
+	rpmbResult   = 508
+	rpmbReqResp  = 510
+	rpmbNonceLen = 16
+
+	// p106, Table 19 - RPMB Request/Response Message Types, JESD84-B51
+	RPMB_REQ_KEY_PROGRAMMING = 0x0001
+	RPMB_REQ_WRITE_COUNTER   = 0x0002
+	RPMB_REQ_DATA_WRITE      = 0x0003
+	RPMB_REQ_DATA_READ       = 0x0004
+	RPMB_REQ_RESULT_READ     = 0x0005
+
+	RPMB_RESP_KEY_PROGRAMMING = 0x0100
+	RPMB_RESP_WRITE_COUNTER   = 0x0200
+	RPMB_RESP_DATA_WRITE      = 0x0300
+	RPMB_RESP_DATA_READ       = 0x0400
+
+	// p106, Table 20 - RPMB Operation Results data structure, JESD84-B51
+	RPMB_RESULT_OK                 = 0x0000
+	RPMB_RESULT_COUNTER_EXPIRED    = 0x0080
+	RPMB_RESULT_KEY_NOT_PROGRAMMED = 0x0007
+)
+
+// rpmbFrame represents an RPMB data frame (p105, 6.6.22.2 Data Frame for
+// RPMB, JESD84-B51).
+type rpmbFrame [RPMB_FRAME_SIZE]byte
+
+func (f *rpmbFrame) mac(key []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(f[rpmbData:])
+	return h.Sum(nil)
+}
+
+func (f *rpmbFrame) sign(key []byte) {
+	copy(f[rpmbKeyMAC:rpmbData], f.mac(key))
+}
+
+func (f *rpmbFrame) verify(key []byte, resp uint16) (err error) {
+	if t := binary.BigEndian.Uint16(f[rpmbReqResp:]); t != resp {
+		return fmt.Errorf("unexpected RPMB response type %#x", t)
+	}
+
+	if !hmac.Equal(f[rpmbKeyMAC:rpmbData], f.mac(key)) {
+		return errors.New("invalid RPMB response MAC")
+	}
+
+	if res := binary.BigEndian.Uint16(f[rpmbResult:]); res != RPMB_RESULT_OK {
+		return fmt.Errorf("RPMB operation error, result:%#x", res)
+	}
+
+	return
+}
+
+// requestRPMB sends an RPMB request frame and reads back its response frame.
+func (hw *USDHC) requestRPMB(req *rpmbFrame, rel bool) (res *rpmbFrame, err error) {
+	if err = hw.WriteRPMB(req[:], rel); err != nil {
+		return
+	}
+
+	if rel {
+		// authenticated writes require an explicit result read request
+		req = &rpmbFrame{}
+		binary.BigEndian.PutUint16(req[rpmbReqResp:], RPMB_REQ_RESULT_READ)
+
+		if err = hw.WriteRPMB(req[:], false); err != nil {
+			return
+		}
+	}
+
+	res = &rpmbFrame{}
+	err = hw.ReadRPMB(res[:])
+
+	return
+}
+
+// RPMBCounter returns the Replay Protected Memory Block (RPMB) write counter,
+// authenticating the card response with the argument key.
+func (hw *USDHC) RPMBCounter(key []byte) (counter uint32, err error) {
+	if len(key) != RPMB_KEY_SIZE {
+		return 0, errors.New("invalid RPMB key size")
+	}
+
+	req := &rpmbFrame{}
+	nonce := req[rpmbNonce : rpmbNonce+rpmbNonceLen]
+
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	binary.BigEndian.PutUint16(req[rpmbReqResp:], RPMB_REQ_WRITE_COUNTER)
+
+	res, err := hw.requestRPMB(req, false)
+
+	if err != nil {
+		return
+	}
+
+	if err = res.verify(key, RPMB_RESP_WRITE_COUNTER); err != nil {
+		return
+	}
+
+	if !bytes.Equal(nonce, res[rpmbNonce:rpmbNonce+rpmbNonceLen]) {
+		return 0, errors.New("invalid RPMB response nonce")
+	}
+
+	return binary.BigEndian.Uint32(res[rpmbCounter:]), nil
+}
+
+// RPMBWrite performs an authenticated data write of a single Replay Protected
+// Memory Block (RPMB) half sector (256 bytes) at the argument address. The
+// data is zero padded when shorter than a half sector.
+//
+// The current write counter is read and authenticated before the write, the
+// card response is then verified to ensure the counter has been incremented.
+func (hw *USDHC) RPMBWrite(key []byte, addr uint16, data []byte) (err error) {
+	if len(data) > RPMB_DATA_SIZE {
+		return fmt.Errorf("data size must not exceed %d", RPMB_DATA_SIZE)
+	}
+
+	counter, err := hw.RPMBCounter(key)
+
+	if err != nil {
+		return
+	}
+
+	req := &rpmbFrame{}
+
+	copy(req[rpmbData:], data)
+	binary.BigEndian.PutUint32(req[rpmbCounter:], counter)
+	binary.BigEndian.PutUint16(req[rpmbAddress:], addr)
+	binary.BigEndian.PutUint16(req[rpmbBlocks:], 1)
+	binary.BigEndian.PutUint16(req[rpmbReqResp:], RPMB_REQ_DATA_WRITE)
+
+	req.sign(key)
+
+	res, err := hw.requestRPMB(req, true)
+
+	if err != nil {
+		return
+	}
+
+	if err = res.verify(key, RPMB_RESP_DATA_WRITE); err != nil {
+		return
+	}
+
+	if n := binary.BigEndian.Uint32(res[rpmbCounter:]); n != counter+1 {
+		return fmt.Errorf("unexpected RPMB write counter %d", n)
+	}
+
+	if a := binary.BigEndian.Uint16(res[rpmbAddress:]); a != addr {
+		return fmt.Errorf("unexpected RPMB response address %#x", a)
+	}
+
+	return
+}
+
+// RPMBRead performs an authenticated data read of a single Replay Protected
+// Memory Block (RPMB) half sector (256 bytes) at the argument address.
+func (hw *USDHC) RPMBRead(key []byte, addr uint16) (data []byte, err error) {
+	if len(key) != RPMB_KEY_SIZE {
+		return nil, errors.New("invalid RPMB key size")
+	}
+
+	req := &rpmbFrame{}
+	nonce := req[rpmbNonce : rpmbNonce+rpmbNonceLen]
+
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	binary.BigEndian.PutUint16(req[rpmbAddress:], addr)
+	binary.BigEndian.PutUint16(req[rpmbReqResp:], RPMB_REQ_DATA_READ)
+
+	res, err := hw.requestRPMB(req, false)
+
+	if err != nil {
+		return
+	}
+
+	if err = res.verify(key, RPMB_RESP_DATA_READ); err != nil {
+		return
+	}
+
+	if !bytes.Equal(nonce, res[rpmbNonce:rpmbNonce+rpmbNonceLen]) {
+		return nil, errors.New("invalid RPMB response nonce")
+	}
+
+	if a := binary.BigEndian.Uint16(res[rpmbAddress:]); a != addr {
+		return nil, fmt.Errorf("unexpected RPMB response address %#x", a)
+	}
+
+	data = make([]byte, RPMB_DATA_SIZE)
+	copy(data, res[rpmbData:rpmbNonce])
+
+	return
+}
@@ -252,6 +252,10 @@ func (hw *USDHC) detectCapabilitiesSD() (err error) {
 		return
 	}
 
+	for i := 0; i < len(hw.card.CSD); i += 4 {
+		binary.LittleEndian.PutUint32(hw.card.CSD[i:], hw.rsp(i/4))
+	}
+
 	ver := hw.rspVal(SD_CSD_STRUCTURE, 0b11)
 
 	switch ver {
@@ -287,19 +291,40 @@ func (hw *USDHC) detectCapabilitiesSD() (err error) {
 	return
 }
 
-// p60, 4.2.4 Bus Signal Voltage Switch Sequence, SD-PL-7.10
+// voltageSwitchSD performs the signaling voltage switch handshake, after a
+// successful CMD11, as described in
+// p60, 4.2.4 Bus Signal Voltage Switch Sequence, SD-PL-7.10.
+//
+// On failure the card is left in an undefined state and must be power cycled
+// before it can be initialized again, the host is reverted to 3.3V
+// signaling.
 func (hw *USDHC) voltageSwitchSD() (err error) {
-	// CMD11 - VOLTAGE_SWITCH - switch to 1.8V signaling
-	if err = hw.cmd(11, 0, 0, 0); err != nil {
-		return
-	}
+	defer func() {
+		if err == nil {
+			return
+		}
 
-	if !reg.WaitFor(1*time.Millisecond, hw.pres_state, PRES_STATE_DLSL, 1, 0) {
-		return fmt.Errorf("voltage switch failed, invalid data line")
-	}
+		reg.Clear(hw.vend_spec, VEND_SPEC_VSELECT)
 
+		if hw.LowVoltage != nil {
+			hw.LowVoltage(false)
+		}
+
+		err = fmt.Errorf("%v, card power cycle required", err)
+	}()
+
+	// stop the card clock
 	hw.setFreq(-1, -1)
 
+	// the card drives CMD and DAT[3:0] low after accepting CMD11
+	if dat, ok := reg.WaitForValue(1*time.Millisecond, hw.pres_state, PRES_STATE_DLSL, 0b1111, 0); !ok {
+		return fmt.Errorf("voltage switch failed, invalid data lines (%04b)", dat)
+	}
+
+	if reg.Get(hw.pres_state, PRES_STATE_CLSL, 1) != 0 {
+		return errors.New("voltage switch failed, invalid command line")
+	}
+
 	// SoC uSDHC IO power voltage selection signal (might be unused)
 	reg.Set(hw.vend_spec, VEND_SPEC_VSELECT)
 
@@ -308,12 +333,15 @@ func (hw *USDHC) voltageSwitchSD() (err error) {
 		return errors.New("voltage switch failed, not at LV")
 	}
 
+	// the regulator output must be stable within 5ms
 	time.Sleep(10 * time.Millisecond)
 
+	// restart the card clock at 1.8V signaling
 	hw.setFreq(DVS_OP, SDCLKFS_OP)
 
-	if !reg.WaitFor(1*time.Millisecond, hw.pres_state, PRES_STATE_DLSL, 1, 1) {
-		return fmt.Errorf("voltage switch failed, invalid data line")
+	// the card drives DAT[3:0] high within 1ms from clock restart
+	if dat, ok := reg.WaitForValue(1*time.Millisecond, hw.pres_state, PRES_STATE_DLSL, 0b1111, 0b1111); !ok {
+		return fmt.Errorf("voltage switch failed, invalid data lines (%04b)", dat)
 	}
 
 	return
@@ -332,8 +360,13 @@ func (hw *USDHC) initSD() (err error) {
 	if hw.LowVoltage == nil {
 		hw.card.Rate = HS_MBPS
 	} else if hw.card.Rate >= SDR50_MBPS {
-		if err = hw.voltageSwitchSD(); err != nil {
+		// CMD11 - VOLTAGE_SWITCH - switch to 1.8V signaling
+		if err = hw.cmd(11, 0, 0, 0); err != nil {
+			// the card did not accept the switch and remains at
+			// 3.3V signaling
 			hw.card.Rate = HS_MBPS
+		} else if err = hw.voltageSwitchSD(); err != nil {
+			return
 		}
 	}
 
@@ -88,7 +88,9 @@ const (
 
 	USDHCx_PRES_STATE = 0x24
 	PRES_STATE_DLSL   = 24
+	PRES_STATE_CLSL   = 23
 	PRES_STATE_WPSPL  = 19
+	PRES_STATE_CINST  = 16
 	PRES_STATE_BREN   = 11
 	PRES_STATE_SDSTB  = 3
 	PRES_STATE_CDIHB  = 1
@@ -226,6 +228,8 @@ type CardInfo struct {
 
 	// device identification number
 	CID [16]byte
+	// device specific data
+	CSD [16]byte
 }
 
 // USDHC represents an SD/MMC controller instance.
@@ -356,6 +360,16 @@ func (hw *USDHC) executeTuning(index uint32, blocks uint32) error {
 	return errors.New("tuning failed")
 }
 
+// resetTuning clears the bus tuning configuration, restoring the fixed
+// sampling clock.
+func (hw *USDHC) resetTuning() {
+	reg.Clear(hw.tuning_ctrl, TUNING_CTRL_STD_TUNING_EN)
+	reg.Clear(hw.ac12_err_status, AUTOCMD12_ERR_STATUS_SMP_CLK_SEL)
+	reg.Clear(hw.ac12_err_status, AUTOCMD12_ERR_STATUS_EXE_TUNE)
+	reg.Clear(hw.mix_ctrl, MIX_CTRL_FBCLK_SEL)
+	reg.Clear(hw.mix_ctrl, MIX_CTRL_AUTO_TUNE_EN)
+}
+
 // Info returns detected card information.
 func (hw *USDHC) Info() CardInfo {
 	return hw.card
@@ -402,6 +416,37 @@ func (hw *USDHC) Init(width int) {
 	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
 }
 
+// CardDetect returns whether a card is inserted, as reported by the controller
+// card detect (CD_B) signal, which requires its pad to be configured by the
+// board package.
+//
+// On boards, or slots, without a card detect line (e.g. USB armory Mk II, the
+// MCIMX6ULL-EVK CPU board microSD) the return value reflects the unconnected
+// CD_B input and is not meaningful, card presence can only be determined by
+// attempting its initialization (see Detect()).
+func (hw *USDHC) CardDetect() bool {
+	if hw.pres_state == 0 {
+		return false
+	}
+
+	return reg.Get(hw.pres_state, PRES_STATE_CINST, 1) == 1
+}
+
+// DetectCard waits, until a timeout expires, for card insertion (see
+// CardDetect()) and initializes it (see Detect()). A zero timeout results in
+// a single card detection check, allowing the function to be polled.
+func (hw *USDHC) DetectCard(timeout time.Duration) (err error) {
+	if hw.pres_state == 0 {
+		return errors.New("controller is not initialized")
+	}
+
+	if !reg.WaitFor(timeout, hw.pres_state, PRES_STATE_CINST, 1, 1) {
+		return fmt.Errorf("no card inserted on uSDHC%d", hw.Index)
+	}
+
+	return hw.Detect()
+}
+
 // Detect initializes an SD/MMC card. The highest speed supported by the
 // driver, card and controller is automatically selected. Speed modes that
 // require voltage switching require definition of function VoltageSelect on
@@ -524,12 +569,25 @@ func (hw *USDHC) transfer(index uint32, dtd uint32, arg uint64, blocks uint32, b
 	// set block count
 	reg.SetN(hw.blk_att, BLK_ATT_BLKCNT, 0xffff, blocks)
 
-	bufAddress := dma.Alloc(buf, 32)
-	defer dma.Free(bufAddress)
-
-	// ADMA2 descriptor
+	// ADMA2 descriptor table, large buffers are split across separate DMA
+	// buffers to avoid requiring a single contiguous allocation while
+	// still executing a single scatter-gather transfer.
+	var segments []uint
 	bd := &ADMABufferDescriptor{}
-	bd.Init(bufAddress, len(buf))
+
+	for off := 0; off < len(buf); off += ADMA_SEGMENT_SIZE {
+		end := off + ADMA_SEGMENT_SIZE
+
+		if end > len(buf) {
+			end = len(buf)
+		}
+
+		addr := dma.Alloc(buf[off:end], 32)
+		defer dma.Free(addr)
+
+		bd.Append(addr, end-off)
+		segments = append(segments, addr)
+	}
 
 	bdAddress := dma.Alloc(bd.Bytes(), 4)
 	defer dma.Free(bdAddress)
@@ -579,7 +637,16 @@ func (hw *USDHC) transfer(index uint32, dtd uint32, arg uint64, blocks uint32, b
 	}
 
 	if dtd == READ {
-		dma.Read(bufAddress, 0, buf)
+		for i, addr := range segments {
+			off := i * ADMA_SEGMENT_SIZE
+			end := off + ADMA_SEGMENT_SIZE
+
+			if end > len(buf) {
+				end = len(buf)
+			}
+
+			dma.Read(addr, 0, buf[off:end])
+		}
 	}
 
 	return
@@ -618,6 +685,76 @@ func (hw *USDHC) ReadBlocks(lba int, buf []byte) (err error) {
 	return hw.transferBlocks(18, READ, lba, buf)
 }
 
+// Erase erases all blocks between the argument start and end addresses
+// (inclusive), waiting for the card to complete the operation by polling its
+// DAT0 line.
+//
+// On MMC cards the TRIM operation is used to only affect the argument write
+// blocks, rather than whole erase groups, and therefore requires eMMC 4.41 or
+// later.
+func (hw *USDHC) Erase(startLBA int, endLBA int) (err error) {
+	var start, end, arg uint32
+
+	if startLBA < 0 || endLBA < startLBA || endLBA >= hw.card.Blocks {
+		return errors.New("invalid erase range")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	// p102, 4.3.14 Command Functional Difference in Card Capacity Types, SD-PL-7.10
+	if hw.card.HC {
+		start = uint32(startLBA)
+		end = uint32(endLBA)
+	} else {
+		start = uint32(startLBA * hw.card.BlockSize)
+		end = uint32(endLBA * hw.card.BlockSize)
+	}
+
+	if err = hw.waitState(CURRENT_STATE_TRAN, 1*time.Millisecond); err != nil {
+		return
+	}
+
+	if hw.card.MMC {
+		// CMD35 - ERASE_GROUP_START - set first block to erase
+		if err = hw.cmd(35, start, 0, 0); err != nil {
+			return
+		}
+
+		// CMD36 - ERASE_GROUP_END - set last block to erase
+		err = hw.cmd(36, end, 0, 0)
+
+		// erase write blocks rather than erase groups
+		arg = MMC_TRIM_ARG
+	} else {
+		// CMD32 - ERASE_WR_BLK_START - set first block to erase
+		if err = hw.cmd(32, start, 0, 0); err != nil {
+			return
+		}
+
+		// CMD33 - ERASE_WR_BLK_END - set last block to erase
+		err = hw.cmd(33, end, 0, 0)
+	}
+
+	if err != nil {
+		return
+	}
+
+	timeout := hw.writeTimeout * time.Duration(endLBA-startLBA+1)
+
+	// CMD38 - ERASE - erase selected blocks
+	if err = hw.cmd(38, arg, 0, hw.writeTimeout); err != nil {
+		return
+	}
+
+	// wait for the card to release DAT0 (busy signaling)
+	if !reg.WaitFor(timeout, hw.pres_state, PRES_STATE_DLSL, 1, 1) {
+		return errors.New("erase timeout")
+	}
+
+	return hw.waitState(CURRENT_STATE_TRAN, hw.writeTimeout)
+}
+
 // Read transfers data from the card.
 func (hw *USDHC) Read(offset int64, size int64) (buf []byte, err error) {
 	blockSize := int64(hw.card.BlockSize)
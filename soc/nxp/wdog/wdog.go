@@ -0,0 +1,145 @@
+// NXP Watchdog Timer (WDOG) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package wdog implements a driver for NXP Watchdog Timer (WDOG) adopting
+// the following reference specifications:
+//   - IMX6ULLRM - i.MX 6ULL Applications Processor Reference Manual - Rev 1 2017/11
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go on ARM SoCs, see
+// https://github.com/usbarmory/tamago.
+package wdog
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// WDOG registers, 32-bit access should be avoided as all registers are
+// 16-bit (p4087, 59.8 WDOG Memory Map/Register Definition, IMX6ULLRM).
+const (
+	WDOGx_WCR = 0x00
+	WCR_WT    = 8
+	WCR_SRE   = 6
+	WCR_WDA   = 5
+	WCR_SRS   = 4
+	WCR_WDT   = 3
+	WCR_WDE   = 2
+
+	WDOGx_WSR = 0x02
+
+	WDOGx_WMCR = 0x08
+	WMCR_PDE   = 0
+)
+
+// WDOG constants
+const (
+	// p4084, 59.5.1 Timeout event, IMX6ULLRM
+	WDOG_TIMEOUT_STEP = 500 * time.Millisecond
+	WDOG_TIMEOUT_MAX  = 256 * WDOG_TIMEOUT_STEP
+
+	// p4085, 59.5.2 Servicing the watchdog, IMX6ULLRM
+	WSR_SEQ1 = 0x5555
+	WSR_SEQ2 = 0xaaaa
+)
+
+// WDOG represents a Watchdog Timer instance.
+type WDOG struct {
+	sync.Mutex
+
+	// Controller index
+	Index int
+	// Base register
+	Base uint32
+
+	// control registers
+	wcr  uint32
+	wsr  uint32
+	wmcr uint32
+}
+
+// Init initializes the watchdog timer instance, clearing the power-down
+// counter event.
+func (hw *WDOG) Init() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 {
+		panic("invalid WDOG instance")
+	}
+
+	hw.wcr = hw.Base + WDOGx_WCR
+	hw.wsr = hw.Base + WDOGx_WSR
+	hw.wmcr = hw.Base + WDOGx_WMCR
+
+	// p4085, 59.5.3 Power-down counter event, IMX6ULLRM
+	reg.Clear16(hw.wmcr, WMCR_PDE)
+}
+
+// Start enables the watchdog timer with the argument timeout, rounded up to
+// the nearest supported value (0.5s steps, up to 128s). The watchdog must be
+// periodically serviced, see Service(), to prevent a system reset.
+//
+// Once started the watchdog cannot be disabled, however its timeout can be
+// changed with subsequent calls.
+func (hw *WDOG) Start(timeout time.Duration) (err error) {
+	if timeout <= 0 || timeout > WDOG_TIMEOUT_MAX {
+		return errors.New("invalid timeout")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.wcr == 0 {
+		return errors.New("watchdog is not initialized")
+	}
+
+	// timeout = (WT + 1) * 0.5s
+	wt := (timeout+WDOG_TIMEOUT_STEP-1)/WDOG_TIMEOUT_STEP - 1
+
+	reg.SetN16(hw.wcr, WCR_WT, 0xff, uint16(wt))
+	// assert WDOG_B on timeout
+	reg.Set16(hw.wcr, WCR_WDT)
+	reg.Set16(hw.wcr, WCR_WDE)
+
+	hw.service()
+
+	return
+}
+
+func (hw *WDOG) service() {
+	reg.Write16(hw.wsr, WSR_SEQ1)
+	reg.Write16(hw.wsr, WSR_SEQ2)
+}
+
+// Service reloads the watchdog timer counter, preventing its timeout.
+func (hw *WDOG) Service() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.service()
+}
+
+// Reset asserts the watchdog software reset signal, causing an immediate
+// system reset.
+func (hw *WDOG) Reset() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	wcr := hw.Base + WDOGx_WCR
+
+	// enable software reset extension
+	reg.Set16(wcr, WCR_SRE)
+
+	// assert system reset signal
+	reg.Clear16(wcr, WCR_SRS)
+}
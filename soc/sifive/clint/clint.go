@@ -23,8 +23,11 @@ import (
 )
 
 // CLINT registers
+// (p59, 9.1 CLINT Memory Map, FU540C00RM).
 const (
-	MTIME = 0xbff8
+	MSIP     = 0x0000
+	MTIMECMP = 0x4000
+	MTIME    = 0xbff8
 )
 
 // CLINT represents a Core-Local Interruptor (CLINT) instance.
@@ -42,6 +45,27 @@ func (hw *CLINT) Mtime() uint64 {
 	return reg.Read64(hw.Base + MTIME)
 }
 
+// SetSoftwareInterrupt raises, or clears, a machine software interrupt on the
+// argument hart, by setting its software interrupt pending register (msip).
+//
+// This is the inter-processor interrupt mechanism used to release secondary
+// harts from a wait-for-interrupt park loop.
+func (hw *CLINT) SetSoftwareInterrupt(hart int, pending bool) {
+	reg.SetTo(uint32(hw.Base)+MSIP+uint32(4*hart), 0, pending)
+}
+
+// SoftwareInterrupt returns whether a machine software interrupt is pending on
+// the argument hart.
+func (hw *CLINT) SoftwareInterrupt(hart int) bool {
+	return reg.Get(uint32(hw.Base)+MSIP+uint32(4*hart), 0, 1) == 1
+}
+
+// SetCompare sets the argument hart timer compare register (mtimecmp), a
+// machine timer interrupt is raised once Mtime() reaches its value.
+func (hw *CLINT) SetCompare(hart int, cmp uint64) {
+	reg.Write64(hw.Base+MTIMECMP+uint64(8*hart), cmp)
+}
+
 // SetTimer sets the timer to the argument nanoseconds value.
 func (hw *CLINT) SetTimer(t int64) {
 	hw.TimerOffset = t - hw.Nanotime()
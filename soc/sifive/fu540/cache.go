@@ -0,0 +1,38 @@
+// SiFive FU540 L2 cache support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package fu540
+
+import (
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// L2 cache controller registers
+// (p66, 12.4 Memory Map, FU540C00RM).
+const (
+	L2_CONFIG    = L2_CACHE_BASE + 0x000
+	CONFIG_WAYS  = 8
+	L2_WAYENABLE = L2_CACHE_BASE + 0x008
+)
+
+// initL2Cache enables all L2 cache ways, at reset only way 0 is enabled while
+// the remaining ones are available as Loosely Integrated Memory (LIM), which
+// is therefore no longer usable after this call (p65, 12.3 Functional
+// Description, FU540C00RM).
+func initL2Cache() {
+	ways := reg.Get(L2_CONFIG, CONFIG_WAYS, 0xff)
+
+	if ways == 0 {
+		return
+	}
+
+	// ways can only be enabled, the register holds the largest enabled
+	// way index
+	reg.Write(L2_WAYENABLE, ways-1)
+}
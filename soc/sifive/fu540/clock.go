@@ -19,6 +19,7 @@ const (
 	PRCI_BASE = 0x10000000
 
 	PRCI_COREPLLCFG = PRCI_BASE + 0x4
+	COREPLL_LOCK    = 31
 	COREPLL_DIVR    = 0
 	COREPLL_DIVF    = 6
 	COREPLL_DIVQ    = 15
@@ -33,15 +34,33 @@ const (
 	COREPLL = 33330000
 )
 
-func init() {
+// Core and peripheral clock frequencies, as configured by Init()
+const (
+	// COREPLL * 2 * (59 + 1) / ((0 + 1) * 2^2)
+	CORECLK_FREQ = 999900000
+	// TileLink bus (peripheral) clock
+	TLCLK_FREQ = CORECLK_FREQ / 2
+)
+
+// initCorePLL configures the core PLL for CORECLK_FREQ operation
+// (p47, 7.4.2 Setting coreclk frequency, FU540C00RM).
+func initCorePLL() {
+	// run from hfclk while the PLL is reconfigured
+	reg.Set(PRCI_CORECLKSEL, 0)
+
 	c := reg.Read(PRCI_COREPLLCFG)
 
-	// set COREPLL for 1 GHz operation
-	bits.Clear(&c, COREPLL_DIVR)
+	bits.SetN(&c, COREPLL_DIVR, 0x3f, 0)
 	bits.SetN(&c, COREPLL_DIVF, 0x1ff, 59)
 	bits.SetN(&c, COREPLL_DIVQ, 0b111, 2)
 
 	reg.Write(PRCI_COREPLLCFG, c)
+
+	for reg.Get(PRCI_COREPLLCFG, COREPLL_LOCK, 1) != 1 {
+		// reg.Wait cannot be used before runtime initialization
+	}
+
+	// switch to the core PLL
 	reg.Clear(PRCI_CORECLKSEL, 0)
 }
 
@@ -61,3 +80,9 @@ func Freq() (hz uint32) {
 
 	return (COREPLL * 2 * (divf + 1)) / ((divr + 1) * 1 << divq)
 }
+
+// TLFreq returns the TileLink bus frequency, used as peripheral clock.
+func TLFreq() (hz uint32) {
+	// p43, 7.1 Clocking, FU540C00RM
+	return Freq() / 2
+}
@@ -25,6 +25,9 @@ import (
 
 	"github.com/usbarmory/tamago/riscv"
 	"github.com/usbarmory/tamago/soc/sifive/clint"
+	"github.com/usbarmory/tamago/soc/sifive/gpio"
+	"github.com/usbarmory/tamago/soc/sifive/plic"
+	"github.com/usbarmory/tamago/soc/sifive/spi"
 	"github.com/usbarmory/tamago/soc/sifive/uart"
 )
 
@@ -33,11 +36,30 @@ const (
 	// Core-Local Interruptor
 	CLINT_BASE = 0x2000000
 
+	// Platform-Level Interrupt Controller
+	PLIC_BASE = 0xc000000
+
+	// L2 Cache Controller
+	L2_CACHE_BASE = 0x2010000
+
+	// General Purpose I/O
+	GPIO_BASE = 0x10060000
+
+	// QSPI flash controller and memory mapped flash
+	QSPI0_BASE       = 0x10040000
+	QSPI0_FLASH_BASE = 0x20000000
+
 	// Serial ports
 	UART0_BASE = 0x10010000
 	UART1_BASE = 0x10011000
 )
 
+// Interrupt sources (p62, Table 56: PLIC Interrupt Source Mapping, FU540C00RM)
+const (
+	UART0_IRQ = 4
+	UART1_IRQ = 5
+)
+
 // Peripheral instances
 var (
 	// RISC-V core
@@ -49,19 +71,44 @@ var (
 		RTCCLK: RTCCLK,
 	}
 
+	// Platform-Level Interrupt Controller
+	PLIC = &plic.PLIC{
+		Base:  PLIC_BASE,
+		Harts: 5,
+	}
+
+	// General Purpose I/O
+	GPIO = &gpio.GPIO{
+		Base: GPIO_BASE,
+		Pins: 16,
+	}
+
+	// QSPI flash controller
+	QSPI0 = &spi.SPI{
+		Base:      QSPI0_BASE,
+		FlashBase: QSPI0_FLASH_BASE,
+	}
+
 	// Serial port 1
 	UART0 = &uart.UART{
 		Index: 1,
 		Base:  UART0_BASE,
+		Clock: TLFreq,
 	}
 
 	// Serial port 2
 	UART1 = &uart.UART{
 		Index: 2,
 		Base:  UART1_BASE,
+		Clock: TLFreq,
 	}
 )
 
+// NewGPIO gets access to a single GPIO line.
+func NewGPIO(num int) (*gpio.Pin, error) {
+	return GPIO.Init(num)
+}
+
 // Model returns the SoC model name.
 func Model() string {
 	return "FU540"
@@ -20,9 +20,40 @@ var ramStackOffset uint64 = 0x100
 // runtime setup (e.g. runtime.hwinit).
 func Init() {
 	RV64.Init()
+
+	initCorePLL()
+	initL2Cache()
 }
 
 //go:linkname nanotime1 runtime.nanotime1
 func nanotime1() int64 {
 	return CLINT.Nanotime()
 }
+
+// Now returns the number of RTCCLK cycles counted by the CLINT machine timer
+// (mtime).
+func Now() uint64 {
+	return CLINT.Mtime()
+}
+
+// SetTimer schedules a machine timer interrupt for the argument hart, once
+// Now() reaches the argument value.
+func SetTimer(hart int, cmp uint64) {
+	CLINT.SetCompare(hart, cmp)
+}
+
+// WakeHart raises a machine software interrupt on the argument hart, which is
+// the mechanism used to release a secondary hart from its park loop.
+//
+// Note that the Go runtime schedules goroutines exclusively on the boot hart,
+// any code executed on a secondary hart must therefore be provided by its
+// park loop and must not rely on the Go runtime.
+//
+// For this reason no function is provided to start Go functions on secondary
+// harts (e.g. StartHart(hart, entry func())), as their execution cannot be
+// coordinated with the single-threaded runtime scheduler and secondary harts
+// are parked by the runtime itself rather than by a park loop defined by this
+// package.
+func WakeHart(hart int) {
+	CLINT.SetSoftwareInterrupt(hart, true)
+}
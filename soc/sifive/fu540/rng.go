@@ -22,7 +22,7 @@ func initRNG() {
 	drbg := &rng.DRBG{}
 	binary.LittleEndian.PutUint64(drbg.Seed[:], uint64(time.Now().UnixNano()))
 	rng.GetRandomDataFn = drbg.GetRandomData
-
+	rng.Insecure = true
 }
 
 // SetRNG allows to override the internal random number generator function used
@@ -32,6 +32,17 @@ func initRNG() {
 // CPU timer as the FU540 lacks an entropy source. This is unsuitable for
 // secure random number generation and must therefore be overridden to ensure
 // safe operation of Go `crypto/rand`.
+//
+// The override is assumed to be backed by a suitable entropy source, clearing
+// the insecure flag reported by InsecureRNG().
 func SetRNG(getRandomData func([]byte)) {
 	rng.GetRandomDataFn = getRandomData
+	rng.Insecure = false
+}
+
+// InsecureRNG returns whether the random number generator, backing Go
+// `crypto/rand`, lacks an entropy source and is therefore unsuitable for
+// cryptographic use, which is the case until SetRNG() is invoked.
+func InsecureRNG() bool {
+	return rng.Insecure
 }
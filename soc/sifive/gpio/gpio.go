@@ -0,0 +1,111 @@
+// SiFive GPIO controller driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package gpio implements a driver for SiFive GPIO controllers adopting the
+// following reference specifications:
+//   - FU540C00RM - SiFive FU540-C000 Manual - v1p4 2021/03/25
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=riscv64` as
+// supported by the TamaGo framework for bare metal Go on RISC-V SoCs, see
+// https://github.com/usbarmory/tamago.
+package gpio
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// GPIO registers
+// (p110, 20.3 Memory Map, FU540C00RM).
+const (
+	GPIO_INPUT_VAL  = 0x00
+	GPIO_INPUT_EN   = 0x04
+	GPIO_OUTPUT_EN  = 0x08
+	GPIO_OUTPUT_VAL = 0x0c
+	GPIO_PUE        = 0x10
+	GPIO_IOF_EN     = 0x38
+)
+
+// GPIO represents a GPIO controller instance.
+type GPIO struct {
+	sync.Mutex
+
+	// Base register
+	Base uint32
+	// Number of GPIO lines
+	Pins int
+}
+
+// Pin represents a single GPIO line.
+type Pin struct {
+	num  int
+	gpio *GPIO
+}
+
+// Init initializes a GPIO line, disabling any hardware I/O function
+// previously selected on it.
+func (hw *GPIO) Init(num int) (pin *Pin, err error) {
+	if hw.Base == 0 {
+		panic("invalid GPIO controller instance")
+	}
+
+	if num < 0 || num >= hw.Pins {
+		return nil, fmt.Errorf("invalid GPIO number %d", num)
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	// select software controlled GPIO
+	reg.Clear(hw.Base+GPIO_IOF_EN, num)
+
+	return &Pin{num: num, gpio: hw}, nil
+}
+
+func (gpio *Pin) set(off uint32, val bool) {
+	gpio.gpio.Lock()
+	defer gpio.gpio.Unlock()
+
+	reg.SetTo(gpio.gpio.Base+off, gpio.num, val)
+}
+
+// Out configures a GPIO as output.
+func (gpio *Pin) Out() {
+	gpio.set(GPIO_INPUT_EN, false)
+	gpio.set(GPIO_OUTPUT_EN, true)
+}
+
+// In configures a GPIO as input.
+func (gpio *Pin) In() {
+	gpio.set(GPIO_OUTPUT_EN, false)
+	gpio.set(GPIO_INPUT_EN, true)
+}
+
+// High configures a GPIO signal as high.
+func (gpio *Pin) High() {
+	gpio.set(GPIO_OUTPUT_VAL, true)
+}
+
+// Low configures a GPIO signal as low.
+func (gpio *Pin) Low() {
+	gpio.set(GPIO_OUTPUT_VAL, false)
+}
+
+// Value returns the GPIO signal level.
+func (gpio *Pin) Value() (high bool) {
+	return reg.Get(gpio.gpio.Base+GPIO_INPUT_VAL, gpio.num, 1) == 1
+}
+
+// PullUp enables or disables the GPIO internal pull-up, no pull-down is
+// available on this controller.
+func (gpio *Pin) PullUp(enable bool) {
+	gpio.set(GPIO_PUE, enable)
+}
@@ -0,0 +1,154 @@
+// SiFive Platform-Level Interrupt Controller (PLIC) driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package plic implements a driver for SiFive Platform-Level Interrupt
+// Controller (PLIC) block adopting the following reference specifications:
+//   - FU540C00RM - SiFive FU540-C000 Manual - v1p4 2021/03/25
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=riscv64` as
+// supported by the TamaGo framework for bare metal Go on RISC-V SoCs, see
+// https://github.com/usbarmory/tamago.
+package plic
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// PLIC registers
+// (p62, 10.3 Memory Map, FU540C00RM).
+const (
+	PLIC_PRIORITY  = 0x000000
+	PLIC_PENDING   = 0x001000
+	PLIC_ENABLE    = 0x002000
+	PLIC_THRESHOLD = 0x200000
+	PLIC_CLAIM     = 0x200004
+
+	ENABLE_CONTEXT_SIZE = 0x80
+	CONTEXT_SIZE        = 0x1000
+
+	// p62, 10.1 Interrupt Sources, FU540C00RM
+	MAX_SOURCE   = 53
+	MAX_PRIORITY = 7
+)
+
+// PLIC represents a Platform-Level Interrupt Controller (PLIC) instance.
+type PLIC struct {
+	sync.Mutex
+
+	// Base register
+	Base uint32
+	// Number of harts
+	Harts int
+}
+
+// context returns the machine mode interrupt context for the argument hart
+// (p61, 10.1 Interrupt Targets, FU540C00RM), hart 0 (E51 monitor core) has
+// only a machine mode context while harts 1-4 (U54 application cores) have
+// both machine and supervisor mode contexts.
+func context(hart int) int {
+	if hart == 0 {
+		return 0
+	}
+
+	return 2*hart - 1
+}
+
+func (hw *PLIC) valid(source int, hart int) (err error) {
+	if hw.Base == 0 {
+		return errors.New("invalid PLIC instance")
+	}
+
+	if source <= 0 || source > MAX_SOURCE {
+		return errors.New("invalid interrupt source")
+	}
+
+	if hart < 0 || hart >= hw.Harts {
+		return errors.New("invalid hart")
+	}
+
+	return
+}
+
+// EnableIRQ enables an interrupt source, with the argument priority (1-7),
+// for the argument hart machine mode context.
+func (hw *PLIC) EnableIRQ(source int, hart int, priority int) (err error) {
+	if err = hw.valid(source, hart); err != nil {
+		return
+	}
+
+	if priority <= 0 || priority > MAX_PRIORITY {
+		return errors.New("invalid priority")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	ctx := uint32(context(hart))
+
+	reg.Write(hw.Base+PLIC_PRIORITY+uint32(4*source), uint32(priority))
+	reg.Set(hw.Base+PLIC_ENABLE+ctx*ENABLE_CONTEXT_SIZE+uint32(4*(source/32)), source%32)
+	// accept all non-zero priorities
+	reg.Write(hw.Base+PLIC_THRESHOLD+ctx*CONTEXT_SIZE, 0)
+
+	return
+}
+
+// DisableIRQ disables an interrupt source for the argument hart machine mode
+// context.
+func (hw *PLIC) DisableIRQ(source int, hart int) (err error) {
+	if err = hw.valid(source, hart); err != nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	ctx := uint32(context(hart))
+	reg.Clear(hw.Base+PLIC_ENABLE+ctx*ENABLE_CONTEXT_SIZE+uint32(4*(source/32)), source%32)
+
+	return
+}
+
+// Claim returns the highest priority pending interrupt source for the
+// argument hart machine mode context, 0 is returned when no interrupt is
+// pending (p64, 10.7 Interrupt Claim Process, FU540C00RM).
+func (hw *PLIC) Claim(hart int) int {
+	ctx := uint32(context(hart))
+	return int(reg.Read(hw.Base + PLIC_CLAIM + ctx*CONTEXT_SIZE))
+}
+
+// Complete signals the completion of the argument interrupt source handling
+// for the argument hart machine mode context (p64, 10.8 Interrupt Completion,
+// FU540C00RM).
+func (hw *PLIC) Complete(hart int, source int) {
+	ctx := uint32(context(hart))
+	reg.Write(hw.Base+PLIC_CLAIM+ctx*CONTEXT_SIZE, uint32(source))
+}
+
+// ServiceInterrupt claims, and completes, all pending interrupt sources for
+// the argument hart machine mode context, invoking the argument function for
+// each of them.
+//
+// The function is meant to be passed (for the current hart) to
+// riscv.CPU.ServiceInterrupts() to dispatch machine external interrupts.
+func (hw *PLIC) ServiceInterrupt(hart int, isr func(source int)) {
+	for {
+		source := hw.Claim(hart)
+
+		if source == 0 {
+			return
+		}
+
+		isr(source)
+		hw.Complete(hart, source)
+	}
+}
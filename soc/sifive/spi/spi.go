@@ -0,0 +1,263 @@
+// SiFive SPI controller driver
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) WithSecure Corporation
+// https://foundry.withsecure.com
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package spi implements a driver for SiFive SPI controllers, with support
+// for SPI NOR flash access, adopting the following reference specifications:
+//   - FU540C00RM - SiFive FU540-C000 Manual - v1p4 2021/03/25
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=riscv64` as
+// supported by the TamaGo framework for bare metal Go on RISC-V SoCs, see
+// https://github.com/usbarmory/tamago.
+package spi
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/internal/reg"
+)
+
+// SPI registers
+// (p104, 19.3 Memory Map, FU540C00RM).
+const (
+	SPI_SCKDIV = 0x00
+	SPI_CSMODE = 0x18
+
+	CSMODE_AUTO = 0
+	CSMODE_HOLD = 2
+
+	SPI_FMT   = 0x40
+	FMT_LEN   = 16
+	FMT_DIR   = 3
+	FMT_PROTO = 0
+
+	SPI_TXDATA   = 0x48
+	TXDATA_FULL  = 31
+	SPI_RXDATA   = 0x4c
+	RXDATA_EMPTY = 31
+
+	SPI_FCTRL = 0x60
+	FCTRL_EN  = 0
+)
+
+// SPI NOR flash commands
+const (
+	CMD_WRITE_ENABLE = 0x06
+	CMD_READ_STATUS  = 0x05
+	CMD_READ         = 0x03
+	CMD_PAGE_PROGRAM = 0x02
+	CMD_SECTOR_ERASE = 0x20
+
+	STATUS_WIP = 0
+
+	PAGE_SIZE   = 256
+	SECTOR_SIZE = 4096
+
+	// flash operation timeout
+	FLASH_TIMEOUT = 5 * time.Second
+)
+
+// SPI represents a SPI controller instance.
+type SPI struct {
+	sync.Mutex
+
+	// Base register
+	Base uint32
+	// Memory mapped flash region (0 if not available)
+	FlashBase uint32
+	// Flash size
+	FlashSize int
+
+	// control registers
+	csmode uint32
+	fmt    uint32
+	txdata uint32
+	rxdata uint32
+	fctrl  uint32
+}
+
+// Init initializes the SPI controller for single (1-bit) transfers of 8-bit
+// frames.
+func (hw *SPI) Init() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 {
+		panic("invalid SPI controller instance")
+	}
+
+	hw.csmode = hw.Base + SPI_CSMODE
+	hw.fmt = hw.Base + SPI_FMT
+	hw.txdata = hw.Base + SPI_TXDATA
+	hw.rxdata = hw.Base + SPI_RXDATA
+	hw.fctrl = hw.Base + SPI_FCTRL
+
+	reg.SetN(hw.fmt, FMT_PROTO, 0b11, 0)
+	reg.SetN(hw.fmt, FMT_LEN, 0xf, 8)
+	reg.Clear(hw.fmt, FMT_DIR)
+
+	reg.Write(hw.csmode, CSMODE_AUTO)
+}
+
+// xfer performs a full duplex single frame transfer.
+func (hw *SPI) xfer(c byte) byte {
+	for reg.Get(hw.txdata, TXDATA_FULL, 1) == 1 {
+		// wait for TX FIFO to have room for a frame
+	}
+
+	reg.Write(hw.txdata, uint32(c))
+
+	for {
+		rx := reg.Read(hw.rxdata)
+
+		if (rx>>RXDATA_EMPTY)&1 == 0 {
+			return byte(rx)
+		}
+	}
+}
+
+// command performs a programmed I/O transaction, sending the argument bytes
+// followed by the reception of n bytes, while holding chip select asserted.
+func (hw *SPI) command(tx []byte, n int) (rx []byte) {
+	// direct access requires memory mapped flash mode to be disabled
+	if reg.Get(hw.fctrl, FCTRL_EN, 1) == 1 {
+		reg.Clear(hw.fctrl, FCTRL_EN)
+		defer reg.Set(hw.fctrl, FCTRL_EN)
+	}
+
+	reg.Write(hw.csmode, CSMODE_HOLD)
+	defer reg.Write(hw.csmode, CSMODE_AUTO)
+
+	for _, c := range tx {
+		hw.xfer(c)
+	}
+
+	rx = make([]byte, n)
+
+	for i := range rx {
+		rx[i] = hw.xfer(0)
+	}
+
+	return
+}
+
+func address(cmd byte, addr uint32) []byte {
+	return []byte{cmd, byte(addr >> 16), byte(addr >> 8), byte(addr)}
+}
+
+func (hw *SPI) checkRange(addr uint32, n int) (err error) {
+	if n < 0 || (hw.FlashSize > 0 && int(addr)+n > hw.FlashSize) {
+		return errors.New("invalid flash range")
+	}
+
+	if addr+uint32(n) > 1<<24 {
+		return errors.New("flash range exceeds 3-byte addressing")
+	}
+
+	return
+}
+
+// waitReady polls the flash status register until the pending write or erase
+// operation is complete.
+func (hw *SPI) waitReady() (err error) {
+	start := time.Now()
+
+	for hw.command([]byte{CMD_READ_STATUS}, 1)[0]&(1<<STATUS_WIP) != 0 {
+		if time.Since(start) > FLASH_TIMEOUT {
+			return errors.New("flash operation timeout")
+		}
+
+		runtime.Gosched()
+	}
+
+	return
+}
+
+// Read reads n bytes from the flash at the argument address. When the flash
+// is memory mapped, and memory mapped mode is enabled, data is read through
+// the memory mapped region, otherwise programmed I/O is used.
+func (hw *SPI) Read(addr uint32, n int) (buf []byte, err error) {
+	if err = hw.checkRange(addr, n); err != nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.FlashBase == 0 || reg.Get(hw.fctrl, FCTRL_EN, 1) == 0 {
+		return hw.command(address(CMD_READ, addr), n), nil
+	}
+
+	buf = make([]byte, n)
+
+	for i := range buf {
+		off := addr + uint32(i)
+		word := reg.Read(hw.FlashBase + off&^3)
+		buf[i] = byte(word >> (8 * (off & 3)))
+	}
+
+	return
+}
+
+// Write programs the argument buffer to the flash at the argument address,
+// the target region must have been previously erased.
+func (hw *SPI) Write(addr uint32, buf []byte) (err error) {
+	if err = hw.checkRange(addr, len(buf)); err != nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	for len(buf) > 0 {
+		// page program operations cannot cross page boundaries
+		n := PAGE_SIZE - int(addr%PAGE_SIZE)
+
+		if n > len(buf) {
+			n = len(buf)
+		}
+
+		hw.command([]byte{CMD_WRITE_ENABLE}, 0)
+		hw.command(append(address(CMD_PAGE_PROGRAM, addr), buf[:n]...), 0)
+
+		if err = hw.waitReady(); err != nil {
+			return
+		}
+
+		addr += uint32(n)
+		buf = buf[n:]
+	}
+
+	return
+}
+
+// Erase erases the flash sectors (4KB) covering the argument range.
+func (hw *SPI) Erase(addr uint32, n int) (err error) {
+	if err = hw.checkRange(addr, n); err != nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	end := addr + uint32(n)
+
+	for addr &^= SECTOR_SIZE - 1; addr < end; addr += SECTOR_SIZE {
+		hw.command([]byte{CMD_WRITE_ENABLE}, 0)
+		hw.command(address(CMD_SECTOR_ERASE, addr), 0)
+
+		if err = hw.waitReady(); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -36,7 +36,18 @@ const (
 	RXDATA_DATA  = 0
 
 	UARTx_TXCTRL = 0x0008
+	TXCTRL_TXCNT = 16
+	TXCTRL_TXEN  = 0
+
 	UARTx_RXCTRL = 0x000c
+	RXCTRL_RXCNT = 16
+	RXCTRL_RXEN  = 0
+
+	UARTx_IE = 0x0010
+	IE_RXWM  = 1
+	IE_TXWM  = 0
+
+	UARTx_DIV = 0x0018
 )
 
 // UART represents a serial port instance.
@@ -45,25 +56,66 @@ type UART struct {
 	Index int
 	// Base register
 	Base uint32
+	// Clock retrieval function
+	Clock func() uint32
+	// port speed
+	Baudrate uint32
 
 	// control registers
 	txdata uint32
 	rxdata uint32
 	txctrl uint32
 	rxctrl uint32
+	ie     uint32
+	div    uint32
 }
 
-// Init initializes and enables the UART for RS-232 mode,
-// p3605, 55.13.1 Programming the UART in RS-232 mode, IMX6ULLRM.
+// Divisor returns the baud rate divisor value for the argument input clock
+// frequency and baud rate (p97, 13.9 Baud Rate Divisor Register (div),
+// FU540C00RM).
+func Divisor(clock uint32, baud uint32) uint32 {
+	return (clock+baud-1)/baud - 1
+}
+
+// Init initializes and enables the UART transmitter and receiver
+// (p95, 13.5 Transmit Control Register (txctrl), FU540C00RM).
+//
+// The baud rate divisor is configured only when a clock retrieval function is
+// set, the baud rate defaults to UART_DEFAULT_BAUDRATE.
 func (hw *UART) Init() {
 	if hw.Base == 0 {
 		panic("invalid UART controller instance")
 	}
 
+	if hw.Baudrate == 0 {
+		hw.Baudrate = UART_DEFAULT_BAUDRATE
+	}
+
 	hw.txdata = hw.Base + UARTx_TXDATA
 	hw.rxdata = hw.Base + UARTx_RXDATA
 	hw.txctrl = hw.Base + UARTx_TXCTRL
 	hw.rxctrl = hw.Base + UARTx_RXCTRL
+	hw.ie = hw.Base + UARTx_IE
+	hw.div = hw.Base + UARTx_DIV
+
+	if hw.Clock != nil {
+		reg.Write(hw.div, Divisor(hw.Clock(), hw.Baudrate))
+	}
+
+	// set TX/RX FIFO watermarks
+	reg.SetN(hw.txctrl, TXCTRL_TXCNT, 0b111, 1)
+	reg.SetN(hw.rxctrl, RXCTRL_RXCNT, 0b111, 0)
+
+	reg.Set(hw.txctrl, TXCTRL_TXEN)
+	reg.Set(hw.rxctrl, RXCTRL_RXEN)
+}
+
+// EnableInterrupts configures the receive (RX FIFO above watermark) and
+// transmit (TX FIFO below watermark) interrupt sources, routed to the PLIC
+// (p96, 13.7 Interrupt Registers (ip and ie), FU540C00RM).
+func (hw *UART) EnableInterrupts(rx bool, tx bool) {
+	reg.SetTo(hw.ie, IE_RXWM, rx)
+	reg.SetTo(hw.ie, IE_TXWM, tx)
 }
 
 func (hw *UART) txFull() bool {